@@ -14,6 +14,11 @@ User-Agent 工具 (uautil 包):
   - 提供 HTTP 中间件支持
   - 支持自定义机器人特征
 
+robots.txt 工具 (robots 包):
+  - 根据 Go 配置生成 robots.txt
+  - 按 RFC 9309 匹配 Allow/Disallow 规则
+  - 对机器人请求执行相同的规则，支持按爬虫的 Crawl-delay 限制频率
+
 示例用法:
 
 	import "github.com/woodchen-ink/go-web-utils/iputil"
@@ -7,6 +7,9 @@ Package iputil 提供了 IP 地址相关的实用工具函数。
   - GetClientIP: 获取客户端真实 IP 地址
   - IsValidIP: 验证 IP 地址格式是否正确
   - IsPrivateIP: 判断是否为私有网络 IP
+  - PrefixSet: 高效匹配大量 CIDR 的 IP 段集合
+  - IsDatacenterIP: 判断是否为已注册的数据中心 IP
+  - CheckForwarding: 检查客户端 IP 请求头是否可疑
 
 GetClientIP 函数按以下优先级获取客户端 IP:
  1. CF-Connecting-IP (Cloudflare)
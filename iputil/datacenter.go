@@ -0,0 +1,109 @@
+package iputil
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 已注册的数据中心 IP 段，按服务商分组
+var (
+	datacenterMu     sync.RWMutex
+	datacenterRanges = make(map[string]*PrefixSet)
+)
+
+// AddDatacenterRanges 为服务商追加数据中心 IP 段，cidrs 可以是 CIDR 或单个 IP
+// provider 为服务商名称，如 "aws"、"gcp"
+func AddDatacenterRanges(provider string, cidrs ...string) error {
+	provider = strings.ToLower(provider)
+
+	datacenterMu.Lock()
+	defer datacenterMu.Unlock()
+
+	set := datacenterRanges[provider]
+	if set == nil {
+		set, _ = NewPrefixSet()
+	} else {
+		set = set.Clone()
+	}
+	for _, c := range cidrs {
+		if err := set.Add(c); err != nil {
+			return err
+		}
+	}
+	datacenterRanges[provider] = set
+	return nil
+}
+
+// LoadDatacenterRanges 从 r 加载服务商的数据中心 IP 段，替换该服务商已有的 IP 段
+// 接受由 CIDR 组成的 JSON 字符串数组，或每行一个 CIDR 的文本（# 开头的行为注释）
+func LoadDatacenterRanges(provider string, r io.Reader) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	var cidrs []string
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &cidrs); err != nil {
+			return fmt.Errorf("iputil: invalid datacenter range list: %w", err)
+		}
+	} else {
+		scanner := bufio.NewScanner(strings.NewReader(trimmed))
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			cidrs = append(cidrs, line)
+		}
+	}
+
+	set, err := NewPrefixSet(cidrs...)
+	if err != nil {
+		return err
+	}
+
+	datacenterMu.Lock()
+	datacenterRanges[strings.ToLower(provider)] = set
+	datacenterMu.Unlock()
+	return nil
+}
+
+// RemoveDatacenterRanges 删除服务商的全部数据中心 IP 段
+func RemoveDatacenterRanges(provider string) {
+	datacenterMu.Lock()
+	delete(datacenterRanges, strings.ToLower(provider))
+	datacenterMu.Unlock()
+}
+
+// DatacenterProvider 返回 IP 所属的数据中心服务商
+// 多个服务商都包含该 IP 时按名称顺序返回第一个，不属于任何已注册服务商或 IP 无效时返回 false
+func DatacenterProvider(ip string) (string, bool) {
+	datacenterMu.RLock()
+	defer datacenterMu.RUnlock()
+
+	providers := make([]string, 0, len(datacenterRanges))
+	for provider := range datacenterRanges {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+
+	for _, provider := range providers {
+		if datacenterRanges[provider].ContainsIP(ip) {
+			return provider, true
+		}
+	}
+	return "", false
+}
+
+// IsDatacenterIP 判断 IP 是否属于已注册的数据中心（云服务器、托管机房）
+// 默认没有注册任何 IP 段，需要先通过 AddDatacenterRanges 或 LoadDatacenterRanges 加载
+func IsDatacenterIP(ip string) bool {
+	_, ok := DatacenterProvider(ip)
+	return ok
+}
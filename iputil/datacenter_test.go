@@ -0,0 +1,66 @@
+package iputil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDatacenterRanges(t *testing.T) {
+	defer RemoveDatacenterRanges("aws")
+	defer RemoveDatacenterRanges("gcp")
+
+	if err := AddDatacenterRanges("AWS", "3.0.0.0/15"); err != nil {
+		t.Fatalf("AddDatacenterRanges() error = %v", err)
+	}
+	if err := LoadDatacenterRanges("gcp", strings.NewReader("# Google Cloud\n34.64.0.0/10\n\n2600:1900::/28\n")); err != nil {
+		t.Fatalf("LoadDatacenterRanges() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		ip           string
+		wantProvider string
+		want         bool
+	}{
+		{"AWS", "3.1.2.3", "aws", true},
+		{"GCP IPv4", "34.100.1.1", "gcp", true},
+		{"GCP IPv6", "2600:1900::1", "gcp", true},
+		{"住宅IP", "203.0.113.1", "", false},
+		{"无效IP", "invalid", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, ok := DatacenterProvider(tt.ip)
+			if provider != tt.wantProvider || ok != tt.want {
+				t.Errorf("DatacenterProvider(%q) = (%q, %v), want (%q, %v)", tt.ip, provider, ok, tt.wantProvider, tt.want)
+			}
+			if got := IsDatacenterIP(tt.ip); got != tt.want {
+				t.Errorf("IsDatacenterIP(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadDatacenterRanges(t *testing.T) {
+	defer RemoveDatacenterRanges("hosting")
+
+	if err := LoadDatacenterRanges("hosting", strings.NewReader(`["192.0.2.0/24"]`)); err != nil {
+		t.Fatalf("LoadDatacenterRanges() error = %v", err)
+	}
+	if !IsDatacenterIP("192.0.2.10") {
+		t.Error("应加载JSON数组中的IP段")
+	}
+
+	// 重新加载替换已有的IP段
+	if err := LoadDatacenterRanges("hosting", strings.NewReader("198.51.100.0/24")); err != nil {
+		t.Fatalf("LoadDatacenterRanges() error = %v", err)
+	}
+	if IsDatacenterIP("192.0.2.10") || !IsDatacenterIP("198.51.100.10") {
+		t.Error("重新加载应替换已有的IP段")
+	}
+
+	if err := LoadDatacenterRanges("hosting", strings.NewReader("not-a-cidr")); err == nil {
+		t.Error("无效的IP段应返回错误")
+	}
+}
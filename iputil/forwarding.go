@@ -0,0 +1,106 @@
+package iputil
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardingIssue 是客户端 IP 相关请求头中发现的问题
+type ForwardingIssue string
+
+const (
+	// IssueInvalidIP 客户端 IP 相关请求头的值不是有效的 IP
+	IssueInvalidIP ForwardingIssue = "invalid_ip"
+	// IssuePrivateClientIP 请求头声称的客户端 IP 是私有地址
+	IssuePrivateClientIP ForwardingIssue = "private_client_ip"
+	// IssueConflictingHeaders 多个客户端 IP 请求头的值互相矛盾
+	IssueConflictingHeaders ForwardingIssue = "conflicting_headers"
+)
+
+// clientIPHeaders 是 GetClientIP 读取的单值客户端 IP 请求头，顺序与其优先级一致
+var clientIPHeaders = []string{
+	"CF-Connecting-IP",
+	"EO-Client-IP",
+	"Ali-CDN-Real-IP",
+	"X-HW-Real-IP",
+	"Baidu-Real-IP",
+	"X-Qiniu-CDN-Real-IP",
+	"Cdn-Real-Ip",
+	"Fastly-Client-IP",
+	"CloudFront-Viewer-Address",
+	"X-Azure-ClientIP",
+	"X-Real-IP",
+}
+
+// forwardingHeaders 是 CheckForwarding 检查的请求头，按 GetClientIP 的优先级排列
+var forwardingHeaders = append(append([]string(nil), clientIPHeaders...), "X-Forwarded-For")
+
+// CheckForwarding 检查客户端 IP 相关请求头（CDN 请求头、X-Real-IP、X-Forwarded-For）是否可疑
+// 例如值不是有效的 IP、声称来自内网，或者不同请求头给出的客户端 IP 不一致，这些通常是伪造请求头的迹象
+// trusted 为部署中可信的客户端 IP 请求头（如 "CF-Connecting-IP"、"X-Forwarded-For"），只检查并互相比较这些请求头；
+// 为空时只检查 GetClientIP 实际使用的请求头，不比较不同的请求头，因为 CDN 后的代理通常会把 CDN 节点的 IP 写入 X-Real-IP
+// 没有需要检查的请求头（如直连请求）时返回 nil
+func CheckForwarding(r *http.Request, trusted ...string) []ForwardingIssue {
+	var claimed []string
+	for _, name := range forwardingHeaders {
+		if len(trusted) > 0 && !containsHeader(trusted, name) {
+			continue
+		}
+		v := strings.TrimSpace(r.Header.Get(name))
+		if v == "" {
+			continue
+		}
+		if name == "X-Forwarded-For" {
+			v = strings.TrimSpace(strings.Split(v, ",")[0])
+		} else if host, _, err := net.SplitHostPort(v); err == nil {
+			v = host // CloudFront-Viewer-Address 包含端口号
+		}
+		claimed = append(claimed, v)
+		if len(trusted) == 0 {
+			break // 按 GetClientIP 的优先级，只检查实际使用的请求头
+		}
+	}
+	if len(claimed) == 0 {
+		return nil
+	}
+
+	var issues []ForwardingIssue
+	var first net.IP
+	invalid, private, conflict := false, false, false
+	for _, ip := range claimed {
+		parsed := net.ParseIP(ip)
+		switch {
+		case parsed == nil:
+			invalid = true
+			continue
+		case parsed.IsLoopback() || parsed.IsPrivate():
+			private = true
+		}
+		if first == nil {
+			first = parsed
+		} else if !parsed.Equal(first) {
+			conflict = true
+		}
+	}
+	if invalid {
+		issues = append(issues, IssueInvalidIP)
+	}
+	if private {
+		issues = append(issues, IssuePrivateClientIP)
+	}
+	if conflict {
+		issues = append(issues, IssueConflictingHeaders)
+	}
+	return issues
+}
+
+// containsHeader 报告 names 中是否包含请求头 name（不区分大小写）
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(strings.TrimSpace(n), name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,45 @@
+package iputil
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestCheckForwarding(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		trusted []string
+		want    []ForwardingIssue
+	}{
+		{"直连", nil, nil, nil},
+		{"正常的X-Forwarded-For", map[string]string{"X-Forwarded-For": "203.0.113.1, 10.0.0.1"}, nil, nil},
+		{"CDN与代理链一致", map[string]string{"CF-Connecting-IP": "203.0.113.1", "X-Forwarded-For": "203.0.113.1"}, nil, nil},
+		{"CloudFront带端口", map[string]string{"CloudFront-Viewer-Address": "203.0.113.1:46532", "X-Real-IP": "203.0.113.1"}, nil, nil},
+		{"无效IP", map[string]string{"X-Real-IP": "unknown"}, nil, []ForwardingIssue{IssueInvalidIP}},
+		{"私有IP", map[string]string{"X-Forwarded-For": "127.0.0.1"}, nil, []ForwardingIssue{IssuePrivateClientIP}},
+		{"CDN后的Nginx写入节点IP", map[string]string{"CF-Connecting-IP": "203.0.113.1", "X-Real-IP": "172.70.1.1"}, nil, nil},
+		{"未使用的请求头不检查", map[string]string{"CF-Connecting-IP": "203.0.113.1", "X-Real-IP": "bad"}, nil, nil},
+		{"可信请求头互相矛盾", map[string]string{"X-Real-IP": "203.0.113.1", "X-Forwarded-For": "198.51.100.1"},
+			[]string{"X-Real-IP", "x-forwarded-for"}, []ForwardingIssue{IssueConflictingHeaders}},
+		{"不可信的请求头不比较", map[string]string{"CF-Connecting-IP": "203.0.113.1", "X-Real-IP": "198.51.100.1"},
+			[]string{"CF-Connecting-IP"}, nil},
+		{"多个问题", map[string]string{"CF-Connecting-IP": "203.0.113.1", "X-Forwarded-For": "192.168.1.1", "X-Real-IP": "bad"},
+			[]string{"CF-Connecting-IP", "X-Real-IP", "X-Forwarded-For"},
+			[]ForwardingIssue{IssueInvalidIP, IssuePrivateClientIP, IssueConflictingHeaders}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "192.0.2.1:8080"
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			if got := CheckForwarding(req, tt.trusted...); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("CheckForwarding() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
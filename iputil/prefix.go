@@ -0,0 +1,134 @@
+package iputil
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// PrefixSet 是按前缀长度分组的 IP 段集合
+// 查询时对每种前缀长度截取一次地址并查表，复杂度与前缀长度的种类数成正比，适合保存上万条 IP 段
+// PrefixSet 不是并发安全的，并发读写时请在修改前 Clone
+type PrefixSet struct {
+	v4, v6 prefixTable
+}
+
+type prefixTable struct {
+	bits     []int // 已存在的前缀长度，从长到短排序
+	prefixes map[netip.Prefix]struct{}
+}
+
+// NewPrefixSet 创建 IP 段集合，cidrs 可以是 CIDR 或单个 IP
+func NewPrefixSet(cidrs ...string) (*PrefixSet, error) {
+	s := &PrefixSet{
+		v4: prefixTable{prefixes: make(map[netip.Prefix]struct{})},
+		v6: prefixTable{prefixes: make(map[netip.Prefix]struct{})},
+	}
+	for _, c := range cidrs {
+		if err := s.Add(c); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Add 添加 CIDR 或单个 IP
+func (s *PrefixSet) Add(cidr string) error {
+	cidr = strings.TrimSpace(cidr)
+
+	var prefix netip.Prefix
+	if strings.Contains(cidr, "/") {
+		p, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return fmt.Errorf("iputil: invalid IP prefix %q: %w", cidr, err)
+		}
+		prefix = p
+	} else {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return fmt.Errorf("iputil: invalid IP prefix %q: %w", cidr, err)
+		}
+		prefix = netip.PrefixFrom(addr, addr.BitLen())
+	}
+
+	// IPv4 映射的 IPv6 前缀按 IPv4 处理
+	if prefix.Addr().Is4In6() && prefix.Bits() >= 96 {
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), prefix.Bits()-96)
+	}
+	prefix = prefix.Masked()
+
+	if prefix.Addr().Is4() {
+		s.v4.add(prefix)
+	} else {
+		s.v6.add(prefix)
+	}
+	return nil
+}
+
+// Contains 报告 addr 是否在集合中的某个 IP 段内
+func (s *PrefixSet) Contains(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if addr.Is4() {
+		return s.v4.contains(addr)
+	}
+	return s.v6.contains(addr.WithZone(""))
+}
+
+// ContainsIP 报告 IP 字符串是否在集合中的某个 IP 段内，无效 IP 返回 false
+func (s *PrefixSet) ContainsIP(ip string) bool {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return false
+	}
+	return s.Contains(addr)
+}
+
+// Len 返回集合中 IP 段的数量
+func (s *PrefixSet) Len() int {
+	return len(s.v4.prefixes) + len(s.v6.prefixes)
+}
+
+// Clone 返回集合的副本
+func (s *PrefixSet) Clone() *PrefixSet {
+	return &PrefixSet{v4: s.v4.clone(), v6: s.v6.clone()}
+}
+
+func (t *prefixTable) add(p netip.Prefix) {
+	if _, ok := t.prefixes[p]; ok {
+		return
+	}
+	t.prefixes[p] = struct{}{}
+
+	i := sort.Search(len(t.bits), func(i int) bool { return t.bits[i] <= p.Bits() })
+	if i < len(t.bits) && t.bits[i] == p.Bits() {
+		return
+	}
+	t.bits = append(t.bits, 0)
+	copy(t.bits[i+1:], t.bits[i:])
+	t.bits[i] = p.Bits()
+}
+
+func (t *prefixTable) contains(addr netip.Addr) bool {
+	for _, bits := range t.bits {
+		p, err := addr.Prefix(bits)
+		if err != nil {
+			continue
+		}
+		if _, ok := t.prefixes[p]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (t prefixTable) clone() prefixTable {
+	c := prefixTable{
+		bits:     append([]int(nil), t.bits...),
+		prefixes: make(map[netip.Prefix]struct{}, len(t.prefixes)),
+	}
+	for p := range t.prefixes {
+		c.prefixes[p] = struct{}{}
+	}
+	return c
+}
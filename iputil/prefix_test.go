@@ -0,0 +1,61 @@
+package iputil
+
+import "testing"
+
+func TestPrefixSet(t *testing.T) {
+	set, err := NewPrefixSet("66.249.64.0/19", "2001:4860:4801::/48", "203.0.113.7", "::ffff:198.51.100.0/120")
+	if err != nil {
+		t.Fatalf("NewPrefixSet() error = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{"IPv4段内", "66.249.66.1", true},
+		{"IPv4段外", "66.249.96.1", false},
+		{"单个IP", "203.0.113.7", true},
+		{"单个IP相邻地址", "203.0.113.8", false},
+		{"IPv6段内", "2001:4860:4801:10::1", true},
+		{"IPv6段外", "2001:4860:4802::1", false},
+		{"IPv4映射的前缀", "198.51.100.9", true},
+		{"IPv4映射的地址", "::ffff:66.249.66.1", true},
+		{"无效IP", "not-an-ip", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := set.ContainsIP(tt.ip); got != tt.want {
+				t.Errorf("ContainsIP(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	if got := set.Len(); got != 4 {
+		t.Errorf("Len() = %d, want 4", got)
+	}
+}
+
+func TestPrefixSetClone(t *testing.T) {
+	set, _ := NewPrefixSet("10.0.0.0/8")
+	clone := set.Clone()
+	if err := clone.Add("192.0.2.0/24"); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+
+	if set.ContainsIP("192.0.2.1") {
+		t.Error("修改副本不应影响原集合")
+	}
+	if !clone.ContainsIP("192.0.2.1") || !clone.ContainsIP("10.1.2.3") {
+		t.Error("副本应包含原有和新增的IP段")
+	}
+}
+
+func TestPrefixSetInvalid(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/33", "example.com", ""} {
+		if _, err := NewPrefixSet(cidr); err == nil {
+			t.Errorf("NewPrefixSet(%q) 应返回错误", cidr)
+		}
+	}
+}
@@ -0,0 +1,499 @@
+/*
+Package robots 根据 Go 配置生成 robots.txt，并对机器人请求执行相同的规则。
+
+主要功能:
+  - New: 校验配置并编译规则
+  - Robots.ServeHTTP: 返回生成的 robots.txt
+  - Robots.Allowed: 按 RFC 9309 判断爬虫是否可以访问路径
+  - Robots.Middleware: 拒绝被 uautil 识别为机器人且违反规则的请求，按 Crawl-delay 限制频率，支持 uautil 的拒绝响应选项
+
+规则匹配遵循 RFC 9309：
+  - 爬虫按 User-Agent 中包含的产品名称（不区分大小写）选择分组，没有匹配的分组时使用 "*" 分组
+  - 路径按最长匹配的规则决定，Allow 和 Disallow 同样长时 Allow 优先
+  - "*" 匹配任意字符，"$" 出现在末尾时匹配路径结尾
+  - /robots.txt 总是允许访问
+
+示例用法:
+
+	rb, err := robots.New(robots.Config{
+		Groups: []robots.Group{
+			{UserAgents: []string{"Googlebot", "Bingbot"}, Allow: []string{"/"}, Disallow: []string{"/admin/"}},
+			{UserAgents: []string{"*"}, Disallow: []string{"/admin/", "/search"}, CrawlDelay: 10 * time.Second},
+		},
+		Sitemaps: []string{"https://example.com/sitemap.xml"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	mux.Handle("/robots.txt", rb)
+	mux.Handle("/", rb.Middleware()(app))
+*/
+package robots
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+	"github.com/woodchen-ink/go-web-utils/uautil"
+)
+
+// Group 是 robots.txt 中的一个分组
+type Group struct {
+	// UserAgents 是分组适用的爬虫产品名称，如 "Googlebot"，"*" 表示其他所有爬虫
+	UserAgents []string
+	// Allow 是允许访问的路径规则
+	Allow []string
+	// Disallow 是禁止访问的路径规则
+	Disallow []string
+	// CrawlDelay 是两次请求之间的最短间隔，0 表示不限制
+	CrawlDelay time.Duration
+}
+
+// Config 是 robots.txt 的配置
+type Config struct {
+	Groups   []Group
+	Sitemaps []string // 站点地图的绝对 URL
+}
+
+// Robots 是编译后的 robots.txt 规则，可以被多个 goroutine 同时使用
+type Robots struct {
+	text   string
+	groups []group
+	delay  *delayLimiter
+}
+
+// group 是编译后的分组
+type group struct {
+	index  int      // 在 Config.Groups 中的下标
+	agents []string // 小写的产品名称
+	rules  []rule
+	delay  time.Duration
+}
+
+type rule struct {
+	pattern string
+	allow   bool
+}
+
+// New 校验配置并编译规则
+// 每个分组至少包含一个 User-Agent，路径规则必须以 "/" 或 "*" 开头，站点地图必须是绝对 URL
+func New(c Config) (*Robots, error) {
+	rb := &Robots{delay: newDelayLimiter()}
+
+	for i, g := range c.Groups {
+		if len(g.UserAgents) == 0 {
+			return nil, fmt.Errorf("robots: group %d has no user agents", i)
+		}
+		if g.CrawlDelay < 0 {
+			return nil, fmt.Errorf("robots: group %d has a negative crawl delay", i)
+		}
+
+		compiled := group{index: i, delay: g.CrawlDelay}
+		for _, ua := range g.UserAgents {
+			ua = strings.TrimSpace(ua)
+			if ua == "" || strings.ContainsAny(ua, "\r\n") {
+				return nil, fmt.Errorf("robots: invalid user agent %q in group %d", ua, i)
+			}
+			compiled.agents = append(compiled.agents, strings.ToLower(ua))
+		}
+		for _, rules := range []struct {
+			paths []string
+			allow bool
+		}{{g.Allow, true}, {g.Disallow, false}} {
+			for _, p := range rules.paths {
+				if !validPattern(p) {
+					return nil, fmt.Errorf("robots: invalid path %q in group %d", p, i)
+				}
+				compiled.rules = append(compiled.rules, rule{pattern: decodeUnreserved(p), allow: rules.allow})
+			}
+		}
+		rb.groups = append(rb.groups, compiled)
+	}
+
+	for _, s := range c.Sitemaps {
+		u, err := url.Parse(s)
+		if err != nil || !u.IsAbs() || u.Host == "" || strings.ContainsAny(s, "\r\n") {
+			return nil, fmt.Errorf("robots: invalid sitemap URL %q", s)
+		}
+	}
+
+	rb.text = c.String()
+	return rb, nil
+}
+
+// validPattern 报告路径规则是否有效
+func validPattern(p string) bool {
+	return (strings.HasPrefix(p, "/") || strings.HasPrefix(p, "*")) && !strings.ContainsAny(p, " \t\r\n#")
+}
+
+// String 返回 robots.txt 的内容
+func (c Config) String() string {
+	var b strings.Builder
+	for i, g := range c.Groups {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		for _, ua := range g.UserAgents {
+			b.WriteString("User-agent: " + strings.TrimSpace(ua) + "\n")
+		}
+		for _, p := range g.Allow {
+			b.WriteString("Allow: " + p + "\n")
+		}
+		for _, p := range g.Disallow {
+			b.WriteString("Disallow: " + p + "\n")
+		}
+		if len(g.Allow) == 0 && len(g.Disallow) == 0 {
+			// 没有规则的分组允许访问所有路径
+			b.WriteString("Disallow:\n")
+		}
+		if g.CrawlDelay > 0 {
+			b.WriteString("Crawl-delay: " + strconv.FormatFloat(g.CrawlDelay.Seconds(), 'f', -1, 64) + "\n")
+		}
+	}
+	if len(c.Sitemaps) > 0 {
+		if len(c.Groups) > 0 {
+			b.WriteString("\n")
+		}
+		for _, s := range c.Sitemaps {
+			b.WriteString("Sitemap: " + s + "\n")
+		}
+	}
+	return b.String()
+}
+
+// String 返回 robots.txt 的内容
+func (rb *Robots) String() string {
+	return rb.text
+}
+
+// ServeHTTP 返回 robots.txt
+func (rb *Robots) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	if r.Method == http.MethodHead {
+		return
+	}
+	w.Write([]byte(rb.text))
+}
+
+// Allowed 报告 User-Agent 为 userAgent 的爬虫是否可以访问 path（可以包含查询字符串）
+// path 在匹配前会被规范化，"//admin/"、"/x/../admin/" 和 "/%61dmin/" 都按 "/admin/" 匹配
+func (rb *Robots) Allowed(userAgent, path string) bool {
+	path = normalizePath(path)
+	if path == "/robots.txt" {
+		return true
+	}
+	return allowed(rb.match(userAgent), path)
+}
+
+// CrawlDelay 返回 User-Agent 为 userAgent 的爬虫的 Crawl-delay，没有限制时返回 0
+func (rb *Robots) CrawlDelay(userAgent string) time.Duration {
+	var delay time.Duration
+	for _, g := range rb.match(userAgent) {
+		if g.delay > delay {
+			delay = g.delay
+		}
+	}
+	return delay
+}
+
+// match 返回 User-Agent 适用的分组
+// 选择包含最长产品名称的分组，多个分组的产品名称同样长时合并这些分组；没有匹配时使用 "*" 分组
+func (rb *Robots) match(userAgent string) []*group {
+	ua := strings.ToLower(userAgent)
+
+	var matched []*group
+	best := 0
+	for i := range rb.groups {
+		g := &rb.groups[i]
+		n := 0
+		for _, agent := range g.agents {
+			if agent != "*" && len(agent) > n && strings.Contains(ua, agent) {
+				n = len(agent)
+			}
+		}
+		switch {
+		case n == 0 || n < best:
+		case n > best:
+			matched, best = []*group{g}, n
+		default:
+			matched = append(matched, g)
+		}
+	}
+	if len(matched) > 0 {
+		return matched
+	}
+
+	for i := range rb.groups {
+		for _, agent := range rb.groups[i].agents {
+			if agent == "*" {
+				matched = append(matched, &rb.groups[i])
+				break
+			}
+		}
+	}
+	return matched
+}
+
+// allowed 按最长匹配的规则判断路径是否允许访问，同样长时 Allow 优先
+func allowed(groups []*group, path string) bool {
+	r := longestMatch(groups, path)
+	return r == nil || r.allow
+}
+
+// longestMatch 返回路径最长匹配的规则，同样长时 Allow 优先；没有匹配时返回 nil
+func longestMatch(groups []*group, path string) *rule {
+	var result *rule
+	best := -1
+	for _, g := range groups {
+		for i := range g.rules {
+			r := &g.rules[i]
+			n := len(r.pattern)
+			if n < best || !matchPattern(r.pattern, path) {
+				continue
+			}
+			if n > best || r.allow {
+				result, best = r, n
+			}
+		}
+	}
+	return result
+}
+
+// matchPattern 按 robots.txt 规则匹配路径前缀："*" 匹配任意字符，末尾的 "$" 匹配路径结尾
+func matchPattern(pattern, path string) bool {
+	anchored := strings.HasSuffix(pattern, "$")
+	if anchored {
+		pattern = pattern[:len(pattern)-1]
+	}
+	return matchWildcard(pattern, path, anchored)
+}
+
+func matchWildcard(pattern, s string, anchored bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == '*' {
+			pattern = strings.TrimLeft(pattern, "*")
+			if pattern == "" {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if matchWildcard(pattern, s[i:], anchored) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(s) == 0 || pattern[0] != s[0] {
+			return false
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return !anchored || len(s) == 0
+}
+
+// Middleware 创建一个中间件，对被 uautil 识别为机器人（包括合法爬虫）的请求执行规则
+// 访问禁止路径的请求返回 403，请求间隔短于 Crawl-delay 的请求返回 429 和 Retry-After；Crawl-delay 按分组和客户端 IP 分别计算
+// 浏览器等非机器人请求不受影响
+// opts 与 uautil 中间件的选项相同，决定会通知 uautil 的决定钩子：禁止路径的决定来自 "RobotsMiddleware"，
+// Crawl-delay 的决定来自 "RobotsCrawlDelay"，Action 为 uautil.ActionThrottle；Crawl-delay 总是返回 429，忽略 WithStatus、WithMessage、WithRedirect、WithChallenge 和 WithTarpit
+func (rb *Robots) Middleware(opts ...uautil.Option) func(http.Handler) http.Handler {
+	disallow := uautil.DetectMiddleware("RobotsMiddleware", "Disallowed by robots.txt", rb.disallowed, rb.describeDisallow, opts...)
+	// Crawl-delay 使用单独的选项：保留仅报告模式和决定钩子，但不使用调用方的挑战和 Tarpit
+	delayOpts := append(opts[:len(opts):len(opts)],
+		uautil.WithChallenge(nil),
+		uautil.WithTarpit(nil),
+		uautil.WithDenyHandler(http.HandlerFunc(rb.serveCrawlDelay)),
+	)
+	crawlDelay := uautil.DetectMiddleware("RobotsCrawlDelay", "Crawl-delay exceeded", rb.crawlDelayExceeded, rb.describeCrawlDelay, delayOpts...)
+
+	return func(next http.Handler) http.Handler {
+		return disallow(crawlDelay(next))
+	}
+}
+
+// enforced 报告是否对请求执行规则
+func enforced(r *http.Request) bool {
+	return uautil.IsBot(r, false) && r.URL.Path != "/robots.txt"
+}
+
+// disallowed 报告机器人请求的路径是否被禁止访问
+func (rb *Robots) disallowed(r *http.Request) bool {
+	return enforced(r) && !allowed(rb.match(r.UserAgent()), requestPath(r))
+}
+
+func (rb *Robots) describeDisallow(r *http.Request, d *uautil.Decision) {
+	d.Reason = "disallowed by robots.txt"
+	if m := longestMatch(rb.match(r.UserAgent()), requestPath(r)); m != nil {
+		d.Rule = "Disallow: " + m.pattern
+	}
+	if sig, ok := uautil.ClassifyRequest(r); ok {
+		d.Bot = sig
+	}
+}
+
+// delayKey 返回请求适用的最长 Crawl-delay 和计算间隔使用的键，没有限制时 delay 为 0
+func (rb *Robots) delayKey(r *http.Request) (key string, delay time.Duration) {
+	for _, g := range rb.match(r.UserAgent()) {
+		if g.delay > delay {
+			delay, key = g.delay, strconv.Itoa(g.index)
+		}
+	}
+	return key + "|" + iputil.GetClientIP(r), delay
+}
+
+// crawlDelayExceeded 记录一次机器人请求，报告它与上一次请求的间隔是否短于 Crawl-delay
+func (rb *Robots) crawlDelayExceeded(r *http.Request) bool {
+	if !enforced(r) {
+		return false
+	}
+	key, delay := rb.delayKey(r)
+	if delay <= 0 {
+		return false
+	}
+	ok, _ := rb.delay.allow(key, delay)
+	return !ok
+}
+
+func (rb *Robots) describeCrawlDelay(r *http.Request, d *uautil.Decision) {
+	if d.Action == uautil.ActionBlock {
+		d.Action = uautil.ActionThrottle
+	}
+	d.Reason = "crawl-delay exceeded"
+	_, delay := rb.delayKey(r)
+	d.Rule = "Crawl-delay: " + strconv.FormatFloat(delay.Seconds(), 'f', -1, 64)
+	if sig, ok := uautil.ClassifyRequest(r); ok {
+		d.Bot = sig
+	}
+}
+
+// serveCrawlDelay 返回 429 和距离下一次允许请求的 Retry-After
+func (rb *Robots) serveCrawlDelay(w http.ResponseWriter, r *http.Request) {
+	key, _ := rb.delayKey(r)
+	retry := rb.delay.wait(key)
+	w.Header().Set("Retry-After", strconv.Itoa(int((retry+time.Second-1)/time.Second)))
+	http.Error(w, "Crawl-delay exceeded", http.StatusTooManyRequests)
+}
+
+// requestPath 返回用于匹配规则的规范化路径和查询字符串
+func requestPath(r *http.Request) string {
+	path := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		path += "?" + r.URL.RawQuery
+	}
+	return normalizePath(path)
+}
+
+// normalizePath 规范化路径：解码非保留字符的百分号编码，合并重复的斜杠并解析 "." 和 ".."，保留结尾的斜杠
+// 避免 "//admin/"、"/x/../admin/" 和 "/%61dmin/" 绕过 "Disallow: /admin/"；查询字符串只解码非保留字符
+func normalizePath(p string) string {
+	p, query, hasQuery := strings.Cut(decodeUnreserved(p), "?")
+	if p == "" || p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	if hasQuery {
+		cleaned += "?" + query
+	}
+	return cleaned
+}
+
+// decodeUnreserved 解码字母、数字和 "-._~" 的百分号编码，这些字符编码与否含义相同；其他编码保持不变
+func decodeUnreserved(s string) string {
+	if !strings.Contains(s, "%") {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] == '%' && i+2 < len(s) {
+			if c, err := strconv.ParseUint(s[i+1:i+3], 16, 8); err == nil && isUnreserved(byte(c)) {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+func isUnreserved(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~'
+}
+
+// delayMaxKeys 是 delayLimiter 最多记录的客户端数量，超过时先清理过期的记录，仍然过多时删除最早到期的记录
+const delayMaxKeys = 65536
+
+// delayLimiter 记录每个客户端下一次允许请求的时间
+type delayLimiter struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+	now  func() time.Time
+}
+
+func newDelayLimiter() *delayLimiter {
+	return &delayLimiter{next: make(map[string]time.Time), now: time.Now}
+}
+
+// wait 返回 key 距离下一次允许请求的时间，已经可以请求时返回 0
+func (l *delayLimiter) wait(key string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if next, ok := l.next[key]; ok {
+		if d := next.Sub(l.now()); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+// allow 报告 key 现在是否可以请求，可以时记录下一次允许的时间；不可以时返回需要等待的时间
+func (l *delayLimiter) allow(key string, delay time.Duration) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if next, ok := l.next[key]; ok && now.Before(next) {
+		return false, next.Sub(now)
+	}
+	if len(l.next) >= delayMaxKeys {
+		l.evict(now)
+	}
+	l.next[key] = now.Add(delay)
+	return true, 0
+}
+
+// evict 删除过期的记录，仍然不少于 delayMaxKeys 条时按到期时间从早到晚删除，直到剩下 7/8，需要持有 l.mu
+// 不会清空全部记录：客户端 IP 可能来自可伪造的请求头，伪造大量 IP 不能重置其他客户端的间隔
+func (l *delayLimiter) evict(now time.Time) {
+	for k, next := range l.next {
+		if !now.Before(next) {
+			delete(l.next, k)
+		}
+	}
+	if len(l.next) < delayMaxKeys {
+		return
+	}
+
+	keys := make([]string, 0, len(l.next))
+	for k := range l.next {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return l.next[keys[i]].Before(l.next[keys[j]]) })
+	for _, k := range keys[:len(keys)-delayMaxKeys*7/8] {
+		delete(l.next, k)
+	}
+}
@@ -0,0 +1,297 @@
+package robots
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/uautil"
+)
+
+const (
+	googlebotUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	imageBotUA  = "Googlebot-Image/1.0"
+	curlUA      = "curl/8.4.0"
+	chromeUA    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+)
+
+func testConfig() Config {
+	return Config{
+		Groups: []Group{
+			{UserAgents: []string{"Googlebot"}, Allow: []string{"/private/public/"}, Disallow: []string{"/private/", "/*.pdf$"}},
+			{UserAgents: []string{"Googlebot-Image"}, Disallow: []string{"/"}},
+			{UserAgents: []string{"*"}, Disallow: []string{"/admin", "/search?q="}, CrawlDelay: 10 * time.Second},
+		},
+		Sitemaps: []string{"https://example.com/sitemap.xml"},
+	}
+}
+
+func TestConfigString(t *testing.T) {
+	got := Config{
+		Groups: []Group{
+			{UserAgents: []string{"Googlebot", "Bingbot"}, Allow: []string{"/"}, Disallow: []string{"/admin/"}},
+			{UserAgents: []string{"*"}, CrawlDelay: 1500 * time.Millisecond},
+		},
+		Sitemaps: []string{"https://example.com/sitemap.xml"},
+	}.String()
+
+	want := "User-agent: Googlebot\n" +
+		"User-agent: Bingbot\n" +
+		"Allow: /\n" +
+		"Disallow: /admin/\n" +
+		"\n" +
+		"User-agent: *\n" +
+		"Disallow:\n" +
+		"Crawl-delay: 1.5\n" +
+		"\n" +
+		"Sitemap: https://example.com/sitemap.xml\n"
+	if got != want {
+		t.Errorf("String() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr bool
+	}{
+		{"有效配置", testConfig(), false},
+		{"空配置", Config{}, false},
+		{"没有User-Agent", Config{Groups: []Group{{Disallow: []string{"/"}}}}, true},
+		{"路径不以斜杠开头", Config{Groups: []Group{{UserAgents: []string{"*"}, Disallow: []string{"admin"}}}}, true},
+		{"路径包含换行", Config{Groups: []Group{{UserAgents: []string{"*"}, Disallow: []string{"/a\nAllow: /"}}}}, true},
+		{"负的Crawl-delay", Config{Groups: []Group{{UserAgents: []string{"*"}, CrawlDelay: -time.Second}}}, true},
+		{"相对的站点地图", Config{Sitemaps: []string{"/sitemap.xml"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.config)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	rb, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ua   string
+		path string
+		want bool
+	}{
+		{"Googlebot允许", googlebotUA, "/blog", true},
+		{"Googlebot禁止", googlebotUA, "/private/data", false},
+		{"更长的Allow优先", googlebotUA, "/private/public/page", true},
+		{"通配符和结尾", googlebotUA, "/files/report.pdf", false},
+		{"结尾之后还有字符", googlebotUA, "/files/report.pdf?download=1", true},
+		{"Googlebot不使用星号分组", googlebotUA, "/admin", true},
+		{"最长产品名称优先", imageBotUA, "/blog", false},
+		{"其他爬虫使用星号分组", curlUA, "/admin/users", false},
+		{"前缀匹配", curlUA, "/administrator", false},
+		{"查询字符串", curlUA, "/search?q=go", false},
+		{"其他查询字符串", curlUA, "/search?page=2", true},
+		{"robots.txt总是允许", imageBotUA, "/robots.txt", true},
+		{"不区分大小写", "GOOGLEBOT/2.1", "/private/x", false},
+		{"重复的斜杠", googlebotUA, "//private/x", false},
+		{"点点路径", googlebotUA, "/x/../private/x", false},
+		{"百分号编码", googlebotUA, "/%70rivate/x", false},
+		{"保留字符的编码不解码", curlUA, "/search%3Fq=go", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := rb.Allowed(tt.ua, tt.path); got != tt.want {
+				t.Errorf("Allowed(%q, %q) = %v, want %v", tt.ua, tt.path, got, tt.want)
+			}
+		})
+	}
+
+	if got := rb.CrawlDelay(curlUA); got != 10*time.Second {
+		t.Errorf("CrawlDelay(curl) = %v, want 10s", got)
+	}
+	if got := rb.CrawlDelay(googlebotUA); got != 0 {
+		t.Errorf("CrawlDelay(Googlebot) = %v, want 0", got)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rb, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	rb.ServeHTTP(rr, httptest.NewRequest("GET", "/robots.txt", nil))
+	if ct := rr.Header().Get("Content-Type"); ct != "text/plain; charset=utf-8" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if rr.Body.String() != testConfig().String() {
+		t.Errorf("body = %q", rr.Body.String())
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	rb, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rb.delay.now = func() time.Time { return now }
+
+	handler := rb.Middleware()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name       string
+		ua         string
+		ip         string
+		target     string
+		advance    time.Duration
+		wantStatus int
+	}{
+		{"浏览器不受限制", chromeUA, "192.0.2.1", "/admin", 0, http.StatusOK},
+		{"Googlebot访问禁止路径", googlebotUA, "192.0.2.2", "/private/x", 0, http.StatusForbidden},
+		{"Googlebot访问允许路径", googlebotUA, "192.0.2.2", "/blog", 0, http.StatusOK},
+		{"Googlebot没有Crawl-delay", googlebotUA, "192.0.2.2", "/blog", 0, http.StatusOK},
+		{"curl访问禁止路径", curlUA, "192.0.2.3", "/admin", 0, http.StatusForbidden},
+		{"规范化后的禁止路径", curlUA, "192.0.2.3", "//x/../%61dmin", 0, http.StatusForbidden},
+		{"curl第一次请求", curlUA, "192.0.2.3", "/", 0, http.StatusOK},
+		{"curl请求过快", curlUA, "192.0.2.3", "/", 5 * time.Second, http.StatusTooManyRequests},
+		{"其他IP单独计算", curlUA, "192.0.2.4", "/", 0, http.StatusOK},
+		{"等待后允许", curlUA, "192.0.2.3", "/", 5 * time.Second, http.StatusOK},
+		{"robots.txt不受限制", curlUA, "192.0.2.3", "/robots.txt", 0, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Header.Set("User-Agent", tt.ua)
+			req.RemoteAddr = tt.ip + ":1234"
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if rr.Code == http.StatusTooManyRequests && rr.Header().Get("Retry-After") != "5" {
+				t.Errorf("Retry-After = %q, want 5", rr.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestMiddlewareDecisions(t *testing.T) {
+	rb, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	rb.delay.now = func() time.Time { return now }
+
+	var denied []uautil.Decision
+	hook := uautil.DecisionHookFunc(func(r *http.Request, d uautil.Decision) {
+		if d.Action != uautil.ActionAllow {
+			denied = append(denied, d)
+		}
+	})
+	report := func(r *http.Request, d uautil.Decision) {}
+	handler := rb.Middleware(uautil.WithDecisionHook(hook), uautil.WithReportOnly(report))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		ua     string
+		target string
+		want   uautil.Decision
+	}{
+		{"禁止路径", googlebotUA, "/private/x", uautil.Decision{
+			Middleware: "RobotsMiddleware", Action: uautil.ActionBlock, Reason: "disallowed by robots.txt", Rule: "Disallow: /private/",
+		}},
+		{"第一次请求", curlUA, "/", uautil.Decision{}},
+		{"请求过快", curlUA, "/", uautil.Decision{
+			Middleware: "RobotsCrawlDelay", Action: uautil.ActionThrottle, Reason: "crawl-delay exceeded", Rule: "Crawl-delay: 10",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			denied = nil
+			req := httptest.NewRequest("GET", tt.target, nil)
+			req.Header.Set("User-Agent", tt.ua)
+
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, req)
+			if rr.Code != http.StatusOK {
+				t.Errorf("status code = %d, want %d", rr.Code, http.StatusOK)
+			}
+			if tt.want.Middleware == "" {
+				if len(denied) != 0 {
+					t.Errorf("decisions = %+v, want none", denied)
+				}
+				return
+			}
+			if len(denied) != 1 {
+				t.Fatalf("decisions = %d, want 1", len(denied))
+			}
+			got := denied[0]
+			if got.Middleware != tt.want.Middleware || got.Action != tt.want.Action || !got.ReportOnly ||
+				got.Reason != tt.want.Reason || got.Rule != tt.want.Rule {
+				t.Errorf("Decision = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddlewareCrawlDelayOptions(t *testing.T) {
+	rb, err := New(testConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := rb.Middleware(uautil.WithChallenge(passChallenger{}), uautil.WithTarpit(uautil.NewTarpit(uautil.TarpitConfig{})))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", curlUA)
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("第 %d 次请求 status code = %d, want %d", i+1, rr.Code, want)
+		}
+	}
+}
+
+// passChallenger 总是认为请求已经通过挑战
+type passChallenger struct{}
+
+func (passChallenger) Passed(r *http.Request) bool { return true }
+
+func (passChallenger) ServeChallenge(w http.ResponseWriter, r *http.Request) {}
+
+func TestDelayLimiterEvict(t *testing.T) {
+	l := newDelayLimiter()
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	l.allow("victim", time.Hour)
+	for i := 0; len(l.next) < delayMaxKeys; i++ {
+		l.allow(strconv.Itoa(i), time.Minute)
+	}
+	l.allow("new", time.Minute)
+
+	if len(l.next) > delayMaxKeys {
+		t.Errorf("len = %d, want <= %d", len(l.next), delayMaxKeys)
+	}
+	// 伪造大量客户端只会挤掉最早到期的记录
+	if ok, _ := l.allow("victim", time.Hour); ok {
+		t.Error("较晚到期的记录不应被删除")
+	}
+}
@@ -0,0 +1,30 @@
+package uautil
+
+// Action 是策略对请求的处理方式
+type Action int
+
+const (
+	// ActionAllow 放行请求
+	ActionAllow Action = iota
+	// ActionBlock 拒绝请求
+	ActionBlock
+	// ActionChallenge 要求客户端先通过验证（如 JavaScript 挑战）
+	ActionChallenge
+	// ActionThrottle 限制请求频率
+	ActionThrottle
+)
+
+// String 返回处理方式的名称
+func (a Action) String() string {
+	switch a {
+	case ActionAllow:
+		return "allow"
+	case ActionBlock:
+		return "block"
+	case ActionChallenge:
+		return "challenge"
+	case ActionThrottle:
+		return "throttle"
+	}
+	return "unknown"
+}
@@ -0,0 +1,204 @@
+package uautil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// AI 爬虫的用途
+const (
+	AIPurposeTraining = "training" // 抓取内容用于训练模型
+	AIPurposeSearch   = "search"   // 为 AI 搜索建立索引
+	AIPurposeUser     = "user"     // 代替用户实时抓取页面
+)
+
+// AICrawler 描述一个 AI 爬虫
+type AICrawler struct {
+	Pattern  string // User-Agent 特征（小写）
+	Name     string // 爬虫名称
+	Operator string // 运营方
+	Purpose  string // 用途，AIPurposeTraining、AIPurposeSearch 或 AIPurposeUser
+}
+
+// AI 训练爬虫和 LLM 代理的特征列表
+// Google-Extended 和 Applebot-Extended 只是 robots.txt 中的控制标记，
+// 实际抓取使用 Googlebot、Applebot 的 UA，列在这里是为了与 robots 规则保持一致
+var aiCrawlers = []AICrawler{
+	// OpenAI
+	{"gptbot", "GPTBot", "OpenAI", AIPurposeTraining},
+	{"oai-searchbot", "OAI-SearchBot", "OpenAI", AIPurposeSearch},
+	{"chatgpt-user", "ChatGPT-User", "OpenAI", AIPurposeUser},
+
+	// Anthropic
+	{"claudebot", "ClaudeBot", "Anthropic", AIPurposeTraining},
+	{"anthropic-ai", "anthropic-ai", "Anthropic", AIPurposeTraining},
+	{"claude-web", "Claude-Web", "Anthropic", AIPurposeTraining},
+	{"claude-searchbot", "Claude-SearchBot", "Anthropic", AIPurposeSearch},
+	{"claude-user", "Claude-User", "Anthropic", AIPurposeUser},
+
+	// Google / Apple
+	{"google-extended", "Google-Extended", "Google", AIPurposeTraining},
+	{"applebot-extended", "Applebot-Extended", "Apple", AIPurposeTraining},
+
+	// Perplexity
+	{"perplexitybot", "PerplexityBot", "Perplexity", AIPurposeSearch},
+	{"perplexity-user", "Perplexity-User", "Perplexity", AIPurposeUser},
+
+	// Meta
+	{"meta-externalagent", "Meta-ExternalAgent", "Meta", AIPurposeTraining},
+	{"meta-externalfetcher", "Meta-ExternalFetcher", "Meta", AIPurposeUser},
+
+	// 其他
+	{"ccbot", "CCBot", "Common Crawl", AIPurposeTraining},
+	{"bytespider", "Bytespider", "ByteDance", AIPurposeTraining},
+	{"amazonbot", "Amazonbot", "Amazon", AIPurposeTraining},
+	{"cohere-ai", "cohere-ai", "Cohere", AIPurposeTraining},
+	{"diffbot", "Diffbot", "Diffbot", AIPurposeTraining},
+	{"ai2bot", "AI2Bot", "Allen Institute for AI", AIPurposeTraining},
+	{"omgilibot", "omgilibot", "Webz.io", AIPurposeTraining},
+	{"timpibot", "Timpibot", "Timpi", AIPurposeTraining},
+	{"imagesiftbot", "ImagesiftBot", "ImageSift", AIPurposeTraining},
+	{"youbot", "YouBot", "You.com", AIPurposeSearch},
+	{"duckassistbot", "DuckAssistBot", "DuckDuckGo", AIPurposeUser},
+	{"mistralai-user", "MistralAI-User", "Mistral AI", AIPurposeUser},
+}
+
+// AICrawlerPolicy AI 爬虫的访问策略
+type AICrawlerPolicy struct {
+	// Action 为 AI 爬虫的默认处理方式
+	Action Action
+	// AllowPaths 为 Action 是 ActionBlock 时仍允许 AI 爬虫访问的路径，匹配方式与 PolicyRoute.Path 相同：
+	// "/public" 匹配 "/public" 和 "/public/..."，但不匹配 "/publicity"；以 "/" 结尾时按前缀匹配；支持 "*" 和 "**" 通配符
+	AllowPaths []string
+	// Allow 为始终放行的 AI 爬虫名称，如 "ChatGPT-User"，不区分大小写
+	Allow []string
+}
+
+// MatchAICrawler 返回 User-Agent 命中的 AI 爬虫
+// 与 ClassifyBot 使用相同的查找顺序：先匹配 ApplyRuleSet 加载的规则，规则的类别为 CategoryAICrawler 时视为 AI 爬虫，
+// 命中其他类别的规则时不是 AI 爬虫；然后匹配未被规则集禁用的内置特征
+func MatchAICrawler(userAgent string) (AICrawler, bool) {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return AICrawler{}, false
+	}
+
+	if rule := matchBotRule(ua); rule != nil {
+		if rule.Category != CategoryAICrawler {
+			return AICrawler{}, false
+		}
+		if c, ok := matchBuiltinAICrawler(ua); ok {
+			return c, true
+		}
+		name := rule.Name
+		if name == "" {
+			name = rule.Pattern
+		}
+		return AICrawler{Pattern: rule.pattern, Name: name}, true
+	}
+	return matchBuiltinAICrawler(ua)
+}
+
+// matchBuiltinAICrawler 返回小写 UA 命中的内置 AI 爬虫，跳过被规则集禁用的特征
+func matchBuiltinAICrawler(ua string) (AICrawler, bool) {
+	for _, c := range aiCrawlers {
+		if strings.Contains(ua, c.Pattern) && builtinEnabled(c.Pattern) {
+			return c, true
+		}
+	}
+	return AICrawler{}, false
+}
+
+// IsAICrawler 检测请求是否来自 AI 爬虫
+func IsAICrawler(r *http.Request) bool {
+	return IsAICrawlerUserAgent(r.UserAgent())
+}
+
+// IsAICrawlerUserAgent 直接检测 User-Agent 字符串是否为 AI 爬虫
+func IsAICrawlerUserAgent(userAgent string) bool {
+	_, ok := MatchAICrawler(userAgent)
+	return ok
+}
+
+// Allows 判断策略是否允许该 AI 爬虫访问 path，path 会先被规范化，"/public/../admin" 按 "/admin" 匹配
+func (p AICrawlerPolicy) Allows(crawler AICrawler, path string) bool {
+	if p.Action == ActionAllow {
+		return true
+	}
+
+	for _, name := range p.Allow {
+		if strings.EqualFold(name, crawler.Name) {
+			return true
+		}
+	}
+	path = cleanPath(path)
+	for _, pattern := range p.AllowPaths {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// AICrawlerMiddleware 创建一个中间件,按策略处理 AI 爬虫，其他请求不受影响
+// customMessage 是可选的自定义拒绝消息
+func AICrawlerMiddleware(policy AICrawlerPolicy, customMessage ...string) func(http.Handler) http.Handler {
+	var opts []Option
+	if len(customMessage) > 0 {
+		opts = append(opts, WithMessage(customMessage[0]))
+	}
+	return AICrawlerMiddlewareWithOptions(policy, opts...)
+}
+
+// AICrawlerMiddlewareWithOptions 创建一个按策略处理 AI 爬虫的中间件，通过 opts 配置拒绝的方式
+// 默认与 AICrawlerMiddleware 相同，返回 403 和 "AI crawler access denied"
+func AICrawlerMiddlewareWithOptions(policy AICrawlerPolicy, opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions("AICrawlerMiddleware", "AI crawler access denied", opts)
+	detect := func(r *http.Request) bool {
+		crawler, ok := MatchAICrawler(r.UserAgent())
+		return ok && !policy.Allows(crawler, r.URL.Path)
+	}
+	describe := func(r *http.Request, d *Decision) {
+		d.Reason = "ai crawler"
+		if crawler, ok := MatchAICrawler(r.UserAgent()); ok {
+			d.Rule = crawler.Pattern
+		}
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Bot = sig
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o.serve(w, r, next, detect, describe)
+		})
+	}
+}
+
+// BlockAICrawlerMiddleware 创建一个中间件来拦截所有 AI 爬虫
+func BlockAICrawlerMiddleware(customMessage ...string) func(http.Handler) http.Handler {
+	return AICrawlerMiddleware(AICrawlerPolicy{Action: ActionBlock}, customMessage...)
+}
+
+// AddAICrawlerPattern 添加自定义的 AI 爬虫特征
+// 返回的函数可用于移除该特征
+func AddAICrawlerPattern(pattern string) func() {
+	pattern = strings.ToLower(pattern)
+	aiCrawlers = append(aiCrawlers, AICrawler{Pattern: pattern, Name: pattern, Purpose: AIPurposeTraining})
+
+	return func() {
+		for i, c := range aiCrawlers {
+			if c.Pattern == pattern {
+				aiCrawlers = append(aiCrawlers[:i], aiCrawlers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// GetAICrawlers 获取当前的 AI 爬虫列表（副本）
+func GetAICrawlers() []AICrawler {
+	crawlers := make([]AICrawler, len(aiCrawlers))
+	copy(crawlers, aiCrawlers)
+	return crawlers
+}
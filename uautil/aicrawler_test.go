@@ -0,0 +1,151 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchAICrawler(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      string
+	}{
+		{"GPTBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)", "GPTBot"},
+		{"ChatGPT-User", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko); compatible; ChatGPT-User/1.0; +https://openai.com/bot", "ChatGPT-User"},
+		{"ClaudeBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; ClaudeBot/1.0; +claudebot@anthropic.com)", "ClaudeBot"},
+		{"CCBot", "CCBot/2.0 (https://commoncrawl.org/faq/)", "CCBot"},
+		{"Bytespider", "Mozilla/5.0 (Linux; Android 5.0) AppleWebKit/537.36 (KHTML, like Gecko) Mobile Safari/537.36 (compatible; Bytespider; spider-feedback@bytedance.com)", "Bytespider"},
+		{"PerplexityBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; PerplexityBot/1.0; +https://perplexity.ai/perplexitybot)", "PerplexityBot"},
+		{"Amazonbot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; Amazonbot/0.1; +https://developer.amazon.com/support/amazonbot) Chrome/119.0.6045.214 Safari/537.36", "Amazonbot"},
+		{"Googlebot 不是 AI 爬虫", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", ""},
+		{"浏览器", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36", ""},
+		{"空UA", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crawler, ok := MatchAICrawler(tt.userAgent)
+			if crawler.Name != tt.want || ok != (tt.want != "") {
+				t.Errorf("MatchAICrawler() = %q %v, want %q", crawler.Name, ok, tt.want)
+			}
+			if IsAICrawlerUserAgent(tt.userAgent) != ok {
+				t.Errorf("IsAICrawlerUserAgent() 与 MatchAICrawler() 结果不一致")
+			}
+		})
+	}
+}
+
+func TestAICrawlerMiddleware(t *testing.T) {
+	const (
+		gptbot      = "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)"
+		chatgptUser = "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko); compatible; ChatGPT-User/1.0; +https://openai.com/bot"
+		chrome      = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+		googlebot   = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+	)
+
+	policy := AICrawlerPolicy{
+		Action:     ActionBlock,
+		AllowPaths: []string{"/docs/", "/public"},
+		Allow:      []string{"chatgpt-user"},
+	}
+
+	tests := []struct {
+		name           string
+		middleware     func(http.Handler) http.Handler
+		path           string
+		userAgent      string
+		wantStatusCode int
+	}{
+		{"拦截GPTBot", AICrawlerMiddleware(policy), "/blog/post", gptbot, http.StatusForbidden},
+		{"允许路径内的GPTBot", AICrawlerMiddleware(policy), "/docs/api", gptbot, http.StatusOK},
+		{"允许的路径", AICrawlerMiddleware(policy), "/public", gptbot, http.StatusOK},
+		{"相同前缀的其他路径", AICrawlerMiddleware(policy), "/publicity", gptbot, http.StatusForbidden},
+		{"点点路径", AICrawlerMiddleware(policy), "/public/../admin", gptbot, http.StatusForbidden},
+		{"始终放行ChatGPT-User", AICrawlerMiddleware(policy), "/blog/post", chatgptUser, http.StatusOK},
+		{"浏览器不受影响", AICrawlerMiddleware(policy), "/blog/post", chrome, http.StatusOK},
+		{"搜索引擎不受影响", AICrawlerMiddleware(policy), "/blog/post", googlebot, http.StatusOK},
+		{"允许策略", AICrawlerMiddleware(AICrawlerPolicy{Action: ActionAllow}), "/", gptbot, http.StatusOK},
+		{"全部拦截", BlockAICrawlerMiddleware("No AI"), "/docs/api", gptbot, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("Status code = %v, want %v", rec.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestMatchAICrawlerRuleSet(t *testing.T) {
+	const gptbot = "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)"
+
+	restore, err := ApplyRuleSet(&RuleSet{Version: 1, Rules: []Rule{
+		{Pattern: "gptbot", Disabled: true},
+		{Pattern: "newllm", Name: "NewLLM", Category: CategoryAICrawler},
+		{Pattern: "ccbot", Category: CategorySEOTool},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	if _, ok := MatchAICrawler(gptbot); ok {
+		t.Error("被规则集禁用的内置特征不应该被识别为 AI 爬虫")
+	}
+	if c, ok := MatchAICrawler("NewLLM/1.0"); !ok || c.Name != "NewLLM" {
+		t.Errorf("MatchAICrawler(NewLLM) = %+v %v, want NewLLM", c, ok)
+	}
+	if _, ok := MatchAICrawler("CCBot/2.0"); ok {
+		t.Error("规则集中其他类别的规则优先于内置 AI 爬虫特征")
+	}
+	if c, ok := MatchAICrawler("ClaudeBot/1.0"); !ok || c.Operator != "Anthropic" {
+		t.Errorf("MatchAICrawler(ClaudeBot) = %+v %v", c, ok)
+	}
+}
+
+func TestAddAICrawlerPattern(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "NewLLMCrawler/1.0")
+
+	remove := AddAICrawlerPattern("NewLLMCrawler")
+	defer remove()
+
+	if !IsAICrawler(req) {
+		t.Error("自定义 AI 爬虫特征应该被识别")
+	}
+
+	remove()
+	if IsAICrawler(req) {
+		t.Error("移除后不应该被识别为 AI 爬虫")
+	}
+}
+
+func TestGetAICrawlers(t *testing.T) {
+	crawlers := GetAICrawlers()
+	if len(crawlers) == 0 {
+		t.Fatal("应该返回 AI 爬虫列表")
+	}
+
+	crawlers[0].Pattern = "modified"
+	if GetAICrawlers()[0].Pattern == "modified" {
+		t.Error("GetAICrawlers 应该返回副本，而不是原列表")
+	}
+
+	for _, c := range crawlers[1:] {
+		if c.Name == "" || c.Operator == "" || c.Purpose == "" {
+			t.Errorf("AI 爬虫数据不完整: %+v", c)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package uautil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AutomationSignal 是无头浏览器和自动化工具留下的特征
+type AutomationSignal string
+
+// 自动化特征
+const (
+	SignalHeadlessUserAgent     AutomationSignal = "headless_user_agent"     // UA 中包含 HeadlessChrome、PhantomJS 等
+	SignalHeadlessClientHint    AutomationSignal = "headless_client_hint"    // Sec-CH-UA 中包含 HeadlessChrome 品牌
+	SignalMissingAccept         AutomationSignal = "missing_accept"          // 没有 Accept
+	SignalGenericAccept         AutomationSignal = "generic_accept"          // 页面请求的 Accept 只有 */*
+	SignalMissingAcceptLanguage AutomationSignal = "missing_accept_language" // 没有 Accept-Language
+	SignalMissingAcceptEncoding AutomationSignal = "missing_accept_encoding" // 没有 Accept-Encoding 或不支持 gzip
+	SignalMissingSecFetch       AutomationSignal = "missing_sec_fetch"       // 支持 Sec-Fetch-* 的浏览器没有发送
+	SignalMissingClientHints    AutomationSignal = "missing_client_hints"    // Chromium 89+ 在安全上下文中没有发送 Sec-CH-UA
+	SignalHeaderOrder           AutomationSignal = "header_order"            // 请求头顺序与声称的浏览器不符
+	SignalHTTP1OverTLS          AutomationSignal = "http1_over_tls"          // 现代浏览器通过 TLS 使用 HTTP/1.1
+)
+
+// 各特征的分值，总分超过 100 时按 100 计算
+var automationWeights = map[AutomationSignal]int{
+	SignalHeadlessUserAgent:     100,
+	SignalHeadlessClientHint:    100,
+	SignalMissingAccept:         30,
+	SignalGenericAccept:         20,
+	SignalMissingAcceptLanguage: 30,
+	SignalMissingAcceptEncoding: 25,
+	SignalMissingSecFetch:       30,
+	SignalMissingClientHints:    25,
+	SignalHeaderOrder:           30,
+	SignalHTTP1OverTLS:          20,
+}
+
+// 会发送 Sec-CH-UA 和 Sec-Fetch-* 的 Chromium 浏览器
+var chromiumBrowsers = map[string]bool{
+	BrowserChrome:  true,
+	BrowserEdge:    true,
+	BrowserOpera:   true,
+	BrowserBrave:   true,
+	BrowserVivaldi: true,
+	BrowserYandex:  true,
+	BrowserSamsung: true,
+}
+
+// 常见浏览器请求头的相对顺序，每一对中前者应该出现在后者之前
+var headerOrderRules = map[string][][2]string{
+	EngineBlink: {
+		{"user-agent", "accept"},
+		{"accept", "accept-encoding"},
+		{"accept-encoding", "accept-language"},
+	},
+	EngineGecko: {
+		{"user-agent", "accept"},
+		{"accept", "accept-language"},
+		{"accept-language", "accept-encoding"},
+	},
+}
+
+// AutomationResult 是自动化检测的结果
+type AutomationResult struct {
+	Score   int                // 0-100，越高越可能是自动化工具
+	Signals []AutomationSignal // 命中的特征
+}
+
+// Has 报告结果中是否包含特征 s
+func (r AutomationResult) Has(s AutomationSignal) bool {
+	for _, signal := range r.Signals {
+		if signal == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *AutomationResult) add(s AutomationSignal) {
+	r.Signals = append(r.Signals, s)
+	r.Score += automationWeights[s]
+	if r.Score > 100 {
+		r.Score = 100
+	}
+}
+
+// DetectAutomation 根据整个请求（而不只是 UA）判断请求是否来自无头浏览器或自动化工具
+// 只检查声称是浏览器的请求：Puppeteer、Playwright 等工具通常会伪装成普通 Chrome 的 UA，
+// 但缺少真实浏览器会发送的请求头，或请求头的顺序和协议版本与真实浏览器不同
+// 不声称是浏览器的请求（如 curl）返回 0 分，这类请求由 IsBot 识别
+func DetectAutomation(r *http.Request) AutomationResult {
+	var result AutomationResult
+
+	raw := strings.ToLower(r.UserAgent())
+	if containsAny("headlesschrome", "phantomjs", "slimerjs")(raw) {
+		result.add(SignalHeadlessUserAgent)
+	}
+	if strings.Contains(strings.ToLower(r.Header.Get(HeaderSecCHUA)), "headless") {
+		result.add(SignalHeadlessClientHint)
+	}
+
+	ua := Parse(r.UserAgent())
+	if !claimsBrowser(ua) {
+		return result
+	}
+
+	headers := checkBrowserHeaders(r, ua)
+	for _, c := range []struct {
+		missing bool
+		signal  AutomationSignal
+	}{
+		{headers.missingAccept, SignalMissingAccept},
+		{headers.genericAccept, SignalGenericAccept},
+		{headers.missingAcceptLanguage, SignalMissingAcceptLanguage},
+		{headers.missingGzip, SignalMissingAcceptEncoding},
+		{headers.missingSecFetch, SignalMissingSecFetch},
+		{headers.missingClientHints, SignalMissingClientHints},
+	} {
+		if c.missing {
+			result.add(c.signal)
+		}
+	}
+
+	if order, ok := HeaderOrder(r); ok && !headerOrderMatches(ua.Engine, order) {
+		result.add(SignalHeaderOrder)
+	}
+
+	// 服务器直接处理 TLS 并启用 HTTP/2 时，现代浏览器总会协商使用 HTTP/2
+	if r.TLS != nil && r.ProtoMajor == 1 && r.TLS.NegotiatedProtocol != "h2" && (sendsSecFetch(ua) || ua.Engine == EngineGecko) {
+		result.add(SignalHTTP1OverTLS)
+	}
+
+	return result
+}
+
+// IsAutomated 报告请求的自动化分值是否达到 threshold
+func IsAutomated(r *http.Request, threshold int) bool {
+	return DetectAutomation(r).Score >= threshold
+}
+
+// AutomationMiddleware 创建一个中间件，拦截自动化分值达到 threshold 的请求
+// customMessage 是可选的自定义拒绝消息
+func AutomationMiddleware(threshold int, customMessage ...string) func(http.Handler) http.Handler {
+	var opts []Option
+	if len(customMessage) > 0 {
+		opts = append(opts, WithMessage(customMessage[0]))
+	}
+	return AutomationMiddlewareWithOptions(threshold, opts...)
+}
+
+// AutomationMiddlewareWithOptions 创建一个拦截自动化分值达到 threshold 的请求的中间件，通过 opts 配置拒绝的方式
+// 默认与 AutomationMiddleware 相同，返回 403 和 "Automated access denied"
+func AutomationMiddlewareWithOptions(threshold int, opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions("AutomationMiddleware", "Automated access denied", opts)
+	detect := func(r *http.Request) bool {
+		return IsAutomated(r, threshold)
+	}
+	describe := func(r *http.Request, d *Decision) {
+		result := DetectAutomation(r)
+		d.Reason = "automation score " + strconv.Itoa(result.Score)
+		signals := make([]string, len(result.Signals))
+		for i, signal := range result.Signals {
+			signals[i] = string(signal)
+		}
+		d.Rule = strings.Join(signals, ",")
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Bot = sig
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o.serve(w, r, next, detect, describe)
+		})
+	}
+}
+
+type headerOrderKey struct{}
+
+// WithHeaderOrder 记录请求头的原始顺序，供 DetectAutomation 检查
+// net/http 把请求头解析为 map，丢失了顺序，需要由自定义的 Listener 或前置代理记录后传入
+func WithHeaderOrder(r *http.Request, names []string) *http.Request {
+	order := make([]string, len(names))
+	for i, name := range names {
+		order[i] = strings.ToLower(name)
+	}
+	return r.WithContext(context.WithValue(r.Context(), headerOrderKey{}, order))
+}
+
+// HeaderOrder 返回通过 WithHeaderOrder 记录的请求头顺序（小写）
+func HeaderOrder(r *http.Request) ([]string, bool) {
+	order, ok := r.Context().Value(headerOrderKey{}).([]string)
+	return order, ok
+}
+
+// headerOrderMatches 检查请求头顺序是否符合引擎的习惯，没有规则的引擎总是返回 true
+func headerOrderMatches(engine string, order []string) bool {
+	pos := make(map[string]int, len(order))
+	for i, name := range order {
+		if _, ok := pos[name]; !ok {
+			pos[name] = i
+		}
+	}
+	for _, pair := range headerOrderRules[engine] {
+		a, okA := pos[pair[0]]
+		b, okB := pos[pair[1]]
+		if okA && okB && a > b {
+			return false
+		}
+	}
+	return true
+}
+
+// browserHeaders 是真实浏览器总会发送的请求头的检查结果
+// DetectAutomation 和 CheckConsistency 共用这些检查，两者对同一请求的判断保持一致
+type browserHeaders struct {
+	missingAccept         bool // 没有 Accept
+	genericAccept         bool // 页面请求的 Accept 只有 */*
+	missingAcceptLanguage bool // 没有 Accept-Language
+	missingGzip           bool // 没有 Accept-Encoding 或不支持 gzip
+	missingSecFetch       bool // 支持 Sec-Fetch-* 的浏览器在安全上下文中没有发送 Sec-Fetch-Mode 和 Sec-Fetch-Site
+	missingClientHints    bool // Chromium 89+ 在安全上下文中没有发送 Sec-CH-UA
+}
+
+// checkBrowserHeaders 检查声称是 ua 的请求是否缺少真实浏览器总会发送的请求头
+func checkBrowserHeaders(r *http.Request, ua UserAgent) browserHeaders {
+	h := r.Header
+	accept := h.Get("Accept")
+	secure := isSecureRequest(r)
+	return browserHeaders{
+		missingAccept:         accept == "",
+		genericAccept:         accept == "*/*" && isDocumentRequest(r),
+		missingAcceptLanguage: h.Get("Accept-Language") == "",
+		missingGzip:           !strings.Contains(strings.ToLower(h.Get("Accept-Encoding")), "gzip"),
+		missingSecFetch:       secure && sendsSecFetch(ua) && h.Get("Sec-Fetch-Mode") == "" && h.Get("Sec-Fetch-Site") == "",
+		missingClientHints:    secure && sendsClientHints(ua) && h.Get(HeaderSecCHUA) == "",
+	}
+}
+
+// claimsBrowser 报告 UA 是否声称是现代浏览器
+func claimsBrowser(ua UserAgent) bool {
+	if ua.Browser == "" {
+		return false
+	}
+	switch ua.Engine {
+	case EngineBlink, EngineGecko, EngineWebKit:
+		return true
+	}
+	return false
+}
+
+// sendsSecFetch 报告浏览器是否会在安全上下文中发送 Sec-Fetch-* 请求头
+// Chromium 76、Firefox 90、Safari 16.4 开始支持
+func sendsSecFetch(ua UserAgent) bool {
+	switch {
+	case chromiumBrowsers[ua.Browser] && ua.Engine == EngineBlink:
+		return CompareVersions(ua.EngineVersion, "76") >= 0
+	case ua.Browser == BrowserFirefox && ua.Engine == EngineGecko:
+		return CompareVersions(ua.BrowserVersion, "90") >= 0
+	case ua.Browser == BrowserSafari:
+		return CompareVersions(ua.BrowserVersion, "16.4") >= 0
+	}
+	return false
+}
+
+// sendsClientHints 报告浏览器是否会在安全上下文中发送 Sec-CH-UA，Chromium 89 开始默认发送
+func sendsClientHints(ua UserAgent) bool {
+	return chromiumBrowsers[ua.Browser] && ua.Engine == EngineBlink && CompareVersions(ua.EngineVersion, "89") >= 0
+}
+
+// isDocumentRequest 判断请求是否是页面导航
+func isDocumentRequest(r *http.Request) bool {
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode == "navigate"
+	}
+	return r.Method == http.MethodGet && r.Header.Get("X-Requested-With") == ""
+}
+
+// isSecureRequest 判断请求是否来自安全上下文（HTTPS 或本机），浏览器只在安全上下文中发送 Sec-Fetch-* 和 Client Hints
+func isSecureRequest(r *http.Request) bool {
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		return true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
@@ -0,0 +1,160 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const (
+	chrome120UA  = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	firefox121UA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0"
+	safari17UA   = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1 Safari/605.1.15"
+)
+
+// browserRequest 构造真实浏览器发出的 HTTPS 页面请求
+func browserRequest(ua string) *http.Request {
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	req.Proto, req.ProtoMajor, req.ProtoMinor = "HTTP/2.0", 2, 0
+	req.Header.Set("User-Agent", ua)
+	req.Header.Set("Accept-Language", "zh-CN,zh;q=0.9,en;q=0.8")
+
+	switch ua {
+	case chrome120UA:
+		req.Header.Set("Sec-CH-UA", `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`)
+		req.Header.Set("Sec-CH-UA-Mobile", "?0")
+		req.Header.Set("Sec-CH-UA-Platform", `"Windows"`)
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+		req.Header.Set("Sec-Fetch-Site", "none")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.Header.Set("Sec-Fetch-User", "?1")
+		req.Header.Set("Sec-Fetch-Dest", "document")
+	case firefox121UA:
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,*/*;q=0.8")
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+		req.Header.Set("Sec-Fetch-Site", "none")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.Header.Set("Sec-Fetch-User", "?1")
+		req.Header.Set("Sec-Fetch-Dest", "document")
+	case safari17UA:
+		req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8")
+		req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+		req.Header.Set("Sec-Fetch-Site", "none")
+		req.Header.Set("Sec-Fetch-Mode", "navigate")
+		req.Header.Set("Sec-Fetch-Dest", "document")
+	}
+	return req
+}
+
+func TestDetectAutomation(t *testing.T) {
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		want    []AutomationSignal
+	}{
+		{"真实Chrome", func() *http.Request { return browserRequest(chrome120UA) }, nil},
+		{"真实Firefox", func() *http.Request { return browserRequest(firefox121UA) }, nil},
+		{"真实Safari", func() *http.Request { return browserRequest(safari17UA) }, nil},
+		{"HeadlessChrome UA", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36")
+			return req
+		}, []AutomationSignal{SignalHeadlessUserAgent}},
+		{"伪装UA但品牌为HeadlessChrome", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.Header.Set("Sec-CH-UA", `"Not_A Brand";v="8", "Chromium";v="120", "HeadlessChrome";v="120"`)
+			req.Header.Del("Accept-Language")
+			return req
+		}, []AutomationSignal{SignalHeadlessClientHint, SignalMissingAcceptLanguage}},
+		{"伪装Chrome的脚本", func() *http.Request {
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Header.Set("User-Agent", chrome120UA)
+			req.Header.Set("Accept", "*/*")
+			return req
+		}, []AutomationSignal{
+			SignalGenericAccept, SignalMissingAcceptLanguage, SignalMissingAcceptEncoding,
+			SignalMissingSecFetch, SignalMissingClientHints, SignalHTTP1OverTLS,
+		}},
+		{"HTTP明文不检查Sec-Fetch", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.TLS = nil
+			for _, h := range []string{"Sec-CH-UA", "Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-Dest"} {
+				req.Header.Del(h)
+			}
+			return req
+		}, nil},
+		{"curl不是浏览器", func() *http.Request {
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Header.Set("User-Agent", "curl/8.0")
+			return req
+		}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectAutomation(tt.request())
+			if len(got.Signals) != len(tt.want) {
+				t.Fatalf("Signals = %v, want %v", got.Signals, tt.want)
+			}
+			for _, s := range tt.want {
+				if !got.Has(s) {
+					t.Errorf("Signals = %v, missing %v", got.Signals, s)
+				}
+			}
+			if len(tt.want) == 0 && got.Score != 0 {
+				t.Errorf("Score = %d, want 0", got.Score)
+			}
+			if got.Score > 100 {
+				t.Errorf("Score = %d, want <= 100", got.Score)
+			}
+		})
+	}
+}
+
+func TestDetectAutomationHeaderOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		ua    string
+		order []string
+		want  bool
+	}{
+		{"Chrome顺序", chrome120UA, []string{"Host", "Connection", "sec-ch-ua", "User-Agent", "Accept", "Sec-Fetch-Site", "Accept-Encoding", "Accept-Language"}, false},
+		{"Firefox顺序", firefox121UA, []string{"Host", "User-Agent", "Accept", "Accept-Language", "Accept-Encoding", "Connection"}, false},
+		{"Python requests伪装Chrome", chrome120UA, []string{"Host", "User-Agent", "Accept-Encoding", "Accept", "Connection", "Accept-Language"}, true},
+		{"Chrome顺序伪装Firefox", firefox121UA, []string{"Host", "User-Agent", "Accept", "Accept-Encoding", "Accept-Language"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := WithHeaderOrder(browserRequest(tt.ua), tt.order)
+			if got := DetectAutomation(req).Has(SignalHeaderOrder); got != tt.want {
+				t.Errorf("Has(SignalHeaderOrder) = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := HeaderOrder(browserRequest(chrome120UA)); ok {
+		t.Error("没有记录顺序时 HeaderOrder 应该返回 false")
+	}
+}
+
+func TestAutomationMiddleware(t *testing.T) {
+	handler := AutomationMiddleware(50)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, browserRequest(chrome120UA))
+	if rr.Code != http.StatusOK {
+		t.Errorf("真实浏览器 status code = %d, want %d", rr.Code, http.StatusOK)
+	}
+
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	req.Header.Set("User-Agent", chrome120UA)
+	rr = httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("自动化工具 status code = %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
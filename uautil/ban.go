@@ -0,0 +1,494 @@
+package uautil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// banMiddlewareName 是 BanManager 中间件在 Decision 中的名称
+const banMiddlewareName = "BanMiddleware"
+
+// banMaxStrikeKeys 是 BanManager 最多记录违规次数的客户端数量，超过时清理过期的记录
+const banMaxStrikeKeys = 65536
+
+// banMaxBans 是 BanManager 最多保存的客户端封禁记录数，超过时删除最早到期的记录，按网段封禁的记录不受影响
+const banMaxBans = 65536
+
+// banSaveDelay 是封禁列表变化后自动保存的延迟，期间的多次变化合并为一次写入
+const banSaveDelay = time.Second
+
+// BanConfig 配置 BanManager，为 0 的字段使用默认值
+type BanConfig struct {
+	// Strikes 是封禁前允许的违规次数，达到时封禁，默认 5
+	Strikes int
+	// StrikeWindow 是违规计数的时间窗口，默认 10 分钟
+	StrikeWindow time.Duration
+	// BanDuration 是首次封禁的时长，默认 10 分钟；每次再封禁时长翻倍
+	BanDuration time.Duration
+	// MaxBanDuration 是封禁时长的上限，默认 24 小时；封禁到期后 MaxBanDuration 内再次封禁会继续升级
+	MaxBanDuration time.Duration
+	// NotFoundLimit 是 StrikeWindow 内返回 404 的次数，达到时记一次违规，默认 20，为负数时不统计 404
+	NotFoundLimit int
+	// IPv6PrefixBits 是 IPv6 客户端按前缀封禁的长度，默认 64；IPv4 客户端按单个 IP 封禁
+	IPv6PrefixBits int
+	// File 是保存封禁列表的文件，为空时不持久化；封禁列表变化后在后台自动保存，创建时从文件恢复
+	File string
+}
+
+// Ban 是一条封禁记录
+type Ban struct {
+	Prefix netip.Prefix `json:"prefix"`           // 封禁的 IP 或 IPv6 前缀
+	Reason string       `json:"reason"`           // 封禁原因，如 "trap"、"not found"、"blocked by BlockBotMiddleware"
+	Until  time.Time    `json:"until"`            // 封禁的结束时间
+	Level  int          `json:"level"`            // 第几次连续封禁，用于计算升级的时长
+	Manual bool         `json:"manual,omitempty"` // 是否为手动封禁
+}
+
+// BanManager 在客户端多次违规后临时封禁其 IP 或 IPv6 前缀，类似 fail2ban
+// 违规包括：被其他中间件拒绝（通过 AddDecisionHook 接收决定）、短时间内大量 404；访问 Honeypot 的陷阱路径时立即封禁
+// BanManager 可以被多个 goroutine 同时使用
+type BanManager struct {
+	cfg BanConfig
+
+	mu      sync.Mutex
+	bans    map[netip.Prefix]*Ban // 按客户端前缀封禁的记录
+	wide    map[netip.Prefix]*Ban // 前缀长度与客户端前缀不同的记录，通常是少量手动封禁的网段
+	strikes map[netip.Prefix]*strikeState
+	now     func() time.Time
+
+	saveMu      sync.Mutex
+	savePending atomic.Bool
+}
+
+type strikeState struct {
+	start    time.Time
+	count    int
+	notFound int
+}
+
+// NewBanManager 创建封禁管理器，配置了 File 时从文件恢复封禁列表，文件不存在时忽略
+func NewBanManager(cfg BanConfig) (*BanManager, error) {
+	if cfg.Strikes <= 0 {
+		cfg.Strikes = 5
+	}
+	if cfg.StrikeWindow <= 0 {
+		cfg.StrikeWindow = 10 * time.Minute
+	}
+	if cfg.BanDuration <= 0 {
+		cfg.BanDuration = 10 * time.Minute
+	}
+	if cfg.MaxBanDuration <= 0 {
+		cfg.MaxBanDuration = 24 * time.Hour
+	}
+	if cfg.NotFoundLimit == 0 {
+		cfg.NotFoundLimit = 20
+	}
+	if cfg.IPv6PrefixBits <= 0 || cfg.IPv6PrefixBits > 128 {
+		cfg.IPv6PrefixBits = 64
+	}
+
+	b := &BanManager{
+		cfg:     cfg,
+		bans:    make(map[netip.Prefix]*Ban),
+		wide:    make(map[netip.Prefix]*Ban),
+		strikes: make(map[netip.Prefix]*strikeState),
+		now:     time.Now,
+	}
+	if cfg.File != "" {
+		if err := b.load(); err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// Banned 报告 IP 是否被封禁，被封禁时同时返回封禁记录
+func (b *BanManager) Banned(ip string) (Ban, bool) {
+	addr, ok := parseClientAddr(ip)
+	if !ok {
+		return Ban{}, false
+	}
+	prefix, ok := b.addrPrefix(addr)
+	if !ok {
+		return Ban{}, false
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if ban := b.bans[prefix]; ban != nil && now.Before(ban.Until) {
+		return *ban, true
+	}
+	for _, ban := range b.wide {
+		if ban.Prefix.Contains(addr) && now.Before(ban.Until) {
+			return *ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+// Bans 返回仍然有效的封禁记录，按前缀排序
+func (b *BanManager) Bans() []Ban {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	var bans []Ban
+	for _, table := range []map[netip.Prefix]*Ban{b.bans, b.wide} {
+		for _, ban := range table {
+			if now.Before(ban.Until) {
+				bans = append(bans, *ban)
+			}
+		}
+	}
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Prefix.String() < bans[j].Prefix.String() })
+	return bans
+}
+
+// Strike 为 IP 记一次违规，达到 Strikes 次时封禁并返回封禁记录
+func (b *BanManager) Strike(ip, reason string) (Ban, bool) {
+	prefix, ok := b.clientPrefix(ip)
+	if !ok {
+		return Ban{}, false
+	}
+	return b.strike(prefix, reason, 1, false)
+}
+
+// Ban 手动封禁 IP 或 CIDR（如 "203.0.113.0/24"），duration 为 0 时按升级规则计算时长
+func (b *BanManager) Ban(target string, duration time.Duration, reason string) (Ban, error) {
+	prefix, err := b.parseTarget(target)
+	if err != nil {
+		return Ban{}, err
+	}
+
+	b.mu.Lock()
+	ban := b.ban(prefix, reason, duration, true)
+	b.mu.Unlock()
+
+	b.persist()
+	return ban, nil
+}
+
+// Unban 解除 IP 或 CIDR 的封禁并清除其违规记录，没有对应的封禁时返回 false
+func (b *BanManager) Unban(target string) bool {
+	prefix, err := b.parseTarget(target)
+	if err != nil {
+		return false
+	}
+
+	b.mu.Lock()
+	ban, ok := b.table(prefix)[prefix]
+	if ok {
+		b.remove(ban)
+	}
+	delete(b.strikes, prefix)
+	b.mu.Unlock()
+
+	if ok {
+		b.persist()
+	}
+	return ok
+}
+
+// OnDecision 把其他中间件拒绝的请求记为一次违规，Honeypot 的陷阱命中立即封禁，仅报告模式的决定不计入
+func (b *BanManager) OnDecision(r *http.Request, d Decision) {
+	if d.Action != ActionBlock || d.ReportOnly || d.Middleware == banMiddlewareName {
+		return
+	}
+	prefix, ok := b.clientPrefix(d.ClientIP)
+	if !ok {
+		return
+	}
+	if d.Middleware == honeypotMiddlewareName {
+		b.strike(prefix, "trap", b.cfg.Strikes, false)
+		return
+	}
+	b.strike(prefix, "blocked by "+d.Middleware, 1, false)
+}
+
+// Middleware 创建一个中间件，拒绝被封禁的客户端，并统计 404
+// 应放在其他中间件之前，使被封禁的请求不再经过其他检测；opts 配置拒绝的方式，默认返回 403 和 Retry-After
+func (b *BanManager) Middleware(opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions(banMiddlewareName, "Access denied", opts)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := iputil.GetClientIP(r)
+			ban, banned := b.Banned(ip)
+
+			h := next
+			if b.cfg.NotFoundLimit > 0 {
+				h = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					next.ServeHTTP(&banStatusWriter{ResponseWriter: w, onNotFound: func() { b.notFound(ip) }}, r)
+				})
+			}
+
+			// 被封禁时在选项的副本中按剩余时长设置 Retry-After，WithRetryAfter 设置的值优先
+			t := o
+			if retry := ban.Until.Sub(b.now()); banned && retry > 0 && o.retryAfter == 0 {
+				c := *o
+				c.retryAfter = retry
+				t = &c
+			}
+			t.serve(w, r, h, func(*http.Request) bool { return banned }, func(_ *http.Request, d *Decision) {
+				d.Reason = "banned"
+				d.Rule = ban.Prefix.String()
+			})
+		})
+	}
+}
+
+// Save 立即把封禁列表保存到 BanConfig.File
+// 封禁列表变化后会在后台自动保存，程序退出前应调用 Save 确保最后的变化写入文件
+func (b *BanManager) Save() error {
+	if b.cfg.File == "" {
+		return nil
+	}
+
+	// 在 saveMu 内复制封禁列表，避免较早的快照覆盖较新的文件
+	b.saveMu.Lock()
+	defer b.saveMu.Unlock()
+
+	b.mu.Lock()
+	bans := make([]Ban, 0, len(b.bans)+len(b.wide))
+	for _, table := range []map[netip.Prefix]*Ban{b.bans, b.wide} {
+		for _, ban := range table {
+			bans = append(bans, *ban)
+		}
+	}
+	b.mu.Unlock()
+	sort.Slice(bans, func(i, j int) bool { return bans[i].Prefix.String() < bans[j].Prefix.String() })
+
+	data, err := json.MarshalIndent(bans, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp := b.cfg.File + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, b.cfg.File)
+}
+
+// persist 在 banSaveDelay 后于后台保存封禁列表，避免在处理请求的 goroutine 中写文件
+func (b *BanManager) persist() {
+	if b.cfg.File == "" || !b.savePending.CompareAndSwap(false, true) {
+		return
+	}
+	time.AfterFunc(banSaveDelay, func() {
+		b.savePending.Store(false)
+		if err := b.Save(); err != nil {
+			log.Printf("uautil: failed to save bans: %v", err)
+		}
+	})
+}
+
+// load 从 BanConfig.File 恢复封禁列表
+func (b *BanManager) load() error {
+	data, err := os.ReadFile(b.cfg.File)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var bans []Ban
+	if err := json.Unmarshal(data, &bans); err != nil {
+		return fmt.Errorf("%s: %w", b.cfg.File, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for i := range bans {
+		if !bans[i].Prefix.IsValid() {
+			return fmt.Errorf("%s: invalid ban prefix", b.cfg.File)
+		}
+		b.add(&bans[i])
+	}
+	return nil
+}
+
+// strike 为前缀记 n 次违规，达到 Strikes 次时封禁
+func (b *BanManager) strike(prefix netip.Prefix, reason string, n int, notFound bool) (Ban, bool) {
+	b.mu.Lock()
+
+	now := b.now()
+	s := b.strikes[prefix]
+	if s == nil || now.Sub(s.start) >= b.cfg.StrikeWindow {
+		if s == nil && len(b.strikes) >= banMaxStrikeKeys {
+			evictOldest(b.strikes, banMaxStrikeKeys, now, func(s *strikeState) time.Time {
+				return s.start.Add(b.cfg.StrikeWindow)
+			})
+		}
+		s = &strikeState{start: now}
+		b.strikes[prefix] = s
+	}
+
+	if notFound {
+		s.notFound++
+		if s.notFound < b.cfg.NotFoundLimit {
+			b.mu.Unlock()
+			return Ban{}, false
+		}
+		s.notFound = 0
+	}
+	s.count += n
+	if s.count < b.cfg.Strikes {
+		b.mu.Unlock()
+		return Ban{}, false
+	}
+
+	delete(b.strikes, prefix)
+	ban := b.ban(prefix, reason, 0, false)
+	b.mu.Unlock()
+
+	b.persist()
+	return ban, true
+}
+
+// notFound 记录一次 404，达到 NotFoundLimit 次时记一次违规
+func (b *BanManager) notFound(ip string) {
+	if prefix, ok := b.clientPrefix(ip); ok {
+		b.strike(prefix, "not found", 1, true)
+	}
+}
+
+// ban 封禁前缀，需要持有 b.mu
+// 前缀之前被封禁过且尚未被遗忘时封禁级别加一，duration 为 0 时使用 BanDuration 按级别翻倍的时长
+func (b *BanManager) ban(prefix netip.Prefix, reason string, duration time.Duration, manual bool) Ban {
+	now := b.now()
+	b.forget(now)
+
+	level := 1
+	if prev := b.table(prefix)[prefix]; prev != nil {
+		level = prev.Level + 1
+		b.remove(prev)
+	}
+	if duration <= 0 {
+		duration = b.cfg.BanDuration
+		for i := 1; i < level && duration < b.cfg.MaxBanDuration; i++ {
+			duration *= 2
+		}
+		if duration > b.cfg.MaxBanDuration {
+			duration = b.cfg.MaxBanDuration
+		}
+	}
+
+	ban := &Ban{Prefix: prefix, Reason: reason, Until: now.Add(duration), Level: level, Manual: manual}
+	b.add(ban)
+	return *ban
+}
+
+// table 返回保存前缀封禁记录的 map：客户端前缀在 b.bans 中，其他网段在 b.wide 中
+func (b *BanManager) table(prefix netip.Prefix) map[netip.Prefix]*Ban {
+	if b.isClientPrefix(prefix) {
+		return b.bans
+	}
+	return b.wide
+}
+
+// add 添加封禁记录，需要持有 b.mu
+// 客户端封禁记录达到 banMaxBans 时先删除最早到期的记录，伪造大量客户端 IP 不能让封禁列表无限增长
+func (b *BanManager) add(ban *Ban) {
+	table := b.table(ban.Prefix)
+	if table[ban.Prefix] == nil && len(b.bans) >= banMaxBans && b.isClientPrefix(ban.Prefix) {
+		evictOldest(b.bans, banMaxBans, b.now(), func(ban *Ban) time.Time { return ban.Until })
+	}
+	table[ban.Prefix] = ban
+}
+
+// remove 删除封禁记录，需要持有 b.mu
+func (b *BanManager) remove(ban *Ban) {
+	delete(b.table(ban.Prefix), ban.Prefix)
+}
+
+// forget 删除到期超过 MaxBanDuration 的封禁记录，需要持有 b.mu
+func (b *BanManager) forget(now time.Time) {
+	for _, table := range []map[netip.Prefix]*Ban{b.bans, b.wide} {
+		for prefix, ban := range table {
+			if now.Sub(ban.Until) > b.cfg.MaxBanDuration {
+				delete(table, prefix)
+			}
+		}
+	}
+}
+
+// clientPrefix 返回 IP 对应的封禁前缀：IPv4 为单个 IP，IPv6 为 IPv6PrefixBits 位前缀
+func (b *BanManager) clientPrefix(ip string) (netip.Prefix, bool) {
+	addr, ok := parseClientAddr(ip)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	return b.addrPrefix(addr)
+}
+
+// addrPrefix 返回地址对应的封禁前缀
+func (b *BanManager) addrPrefix(addr netip.Addr) (netip.Prefix, bool) {
+	return clientPrefix(addr, 32, b.cfg.IPv6PrefixBits)
+}
+
+// isClientPrefix 报告前缀的长度是否与 clientPrefix 返回的一致
+func (b *BanManager) isClientPrefix(prefix netip.Prefix) bool {
+	if prefix.Addr().Is4() {
+		return prefix.Bits() == 32
+	}
+	return prefix.Bits() == b.cfg.IPv6PrefixBits
+}
+
+// parseTarget 把 IP 或 CIDR 解析为封禁前缀
+func (b *BanManager) parseTarget(target string) (netip.Prefix, error) {
+	target = strings.TrimSpace(target)
+	if strings.Contains(target, "/") {
+		prefix, err := netip.ParsePrefix(target)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("uautil: invalid ban target %q", target)
+		}
+		return prefix.Masked(), nil
+	}
+	if prefix, ok := b.clientPrefix(target); ok {
+		return prefix, nil
+	}
+	return netip.Prefix{}, fmt.Errorf("uautil: invalid ban target %q", target)
+}
+
+// banStatusWriter 在处理器返回 404 时通知 BanManager
+type banStatusWriter struct {
+	http.ResponseWriter
+	onNotFound  func()
+	wroteHeader bool
+}
+
+func (w *banStatusWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		if code == http.StatusNotFound {
+			w.onNotFound()
+		}
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *banStatusWriter) Write(p []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap 返回原始的 ResponseWriter，供 http.ResponseController 使用
+func (w *banStatusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
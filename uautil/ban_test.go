@@ -0,0 +1,281 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func newTestBanManager(t *testing.T, cfg BanConfig) (*BanManager, *time.Time) {
+	t.Helper()
+	b, err := NewBanManager(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1700000000, 0)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+func TestBanManagerStrikes(t *testing.T) {
+	b, now := newTestBanManager(t, BanConfig{Strikes: 3, BanDuration: time.Minute, MaxBanDuration: 5 * time.Minute})
+
+	tests := []struct {
+		name       string
+		advance    time.Duration
+		ip         string
+		wantBanned bool
+		wantLevel  int
+		wantUntil  time.Duration
+	}{
+		{"第一次违规", 0, "203.0.113.9", false, 0, 0},
+		{"第二次违规", 0, "203.0.113.9", false, 0, 0},
+		{"第三次违规封禁", 0, "203.0.113.9", true, 1, time.Minute},
+		{"到期后重新计数", 2 * time.Minute, "203.0.113.9", false, 0, 0},
+		{"第二次违规", 0, "203.0.113.9", false, 0, 0},
+		{"再次封禁时长翻倍", 0, "203.0.113.9", true, 2, 2 * time.Minute},
+		{"同一IPv6前缀", 3 * time.Minute, "2001:db8::1", false, 0, 0},
+		{"同一IPv6前缀第二次", 0, "2001:db8::2", false, 0, 0},
+		{"同一IPv6前缀封禁", 0, "2001:db8::3", true, 1, time.Minute},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			*now = now.Add(tt.advance)
+			ban, banned := b.Strike(tt.ip, "test")
+			if banned != tt.wantBanned {
+				t.Fatalf("Strike() banned = %v, want %v", banned, tt.wantBanned)
+			}
+			if !banned {
+				return
+			}
+			if ban.Level != tt.wantLevel || ban.Until.Sub(*now) != tt.wantUntil {
+				t.Errorf("ban = %+v, want level %d for %v", ban, tt.wantLevel, tt.wantUntil)
+			}
+			if _, ok := b.Banned(tt.ip); !ok {
+				t.Errorf("Banned(%q) = false", tt.ip)
+			}
+		})
+	}
+
+	if _, ok := b.Banned("2001:db8::ffff"); !ok {
+		t.Error("同一 /64 前缀的其他地址应被封禁")
+	}
+	if _, ok := b.Banned("2001:db8:0:1::1"); ok {
+		t.Error("其他 /64 前缀不应被封禁")
+	}
+}
+
+func TestBanManagerManual(t *testing.T) {
+	b, now := newTestBanManager(t, BanConfig{})
+
+	if _, err := b.Ban("not-an-ip", time.Hour, "manual"); err == nil {
+		t.Error("无效目标应返回错误")
+	}
+	if _, err := b.Ban("198.51.100.0/24", time.Hour, "abuse"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Ban("192.0.2.1", 0, "manual"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Ban("2001:db8:1::5/128", time.Hour, "abuse"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		want bool
+	}{
+		{"CIDR内", "198.51.100.77", true},
+		{"单个IP", "192.0.2.1", true},
+		{"未封禁", "192.0.2.2", false},
+		{"IPv4映射地址", "::ffff:198.51.100.1", true},
+		{"比客户端前缀更窄的IPv6封禁", "2001:db8:1::5", true},
+		{"同一/64的其他地址", "2001:db8:1::6", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, got := b.Banned(tt.ip); got != tt.want {
+				t.Errorf("Banned(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	if got := len(b.Bans()); got != 3 {
+		t.Errorf("Bans() = %d, want 3", got)
+	}
+	if !b.Unban("198.51.100.0/24") || b.Unban("198.51.100.0/24") {
+		t.Error("Unban() 应只成功一次")
+	}
+	if _, ok := b.Banned("198.51.100.77"); ok {
+		t.Error("解封后仍被封禁")
+	}
+
+	*now = now.Add(11 * time.Minute)
+	if _, ok := b.Banned("192.0.2.1"); ok {
+		t.Error("默认封禁时长后仍被封禁")
+	}
+}
+
+func TestBanManagerPersistence(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "bans.json")
+	b, err := NewBanManager(BanConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.Ban("2001:db8::1", time.Hour, "manual"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Error("封禁列表应在后台保存，而不是在调用 Ban 时同步写入")
+	}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := NewBanManager(BanConfig{File: file})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ban, ok := restored.Banned("2001:db8::abcd")
+	if !ok || ban.Prefix.String() != "2001:db8::/64" || ban.Reason != "manual" || !ban.Manual {
+		t.Errorf("restored ban = %+v, %v", ban, ok)
+	}
+}
+
+func TestBanManagerMiddleware(t *testing.T) {
+	b, _ := newTestBanManager(t, BanConfig{Strikes: 2, NotFoundLimit: 3})
+	hp := NewHoneypot(0, "/wp-login.php", "/.env")
+	remove := AddDecisionHook(b)
+	defer remove()
+
+	app := http.NewServeMux()
+	app.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+		}
+	})
+	handler := b.Middleware()(hp.Middleware()(BlockBotMiddleware(true)(app)))
+
+	request := func(ip, path, ua string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		req.RemoteAddr = ip + ":1234"
+		req.Header.Set("User-Agent", ua)
+		req.Header.Set("Accept", "text/html")
+		req.Header.Set("Accept-Language", "en-US")
+		req.Header.Set("Accept-Encoding", "gzip")
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		ip         string
+		path       string
+		ua         string
+		wantStatus int
+	}{
+		{"正常访问", "192.0.2.1", "/", chrome120UA, http.StatusOK},
+		{"蜜罐陷阱立即封禁", "192.0.2.2", "/wp-login.php", chrome120UA, http.StatusNotFound},
+		{"封禁后正常路径也被拒绝", "192.0.2.2", "/", chrome120UA, http.StatusForbidden},
+		{"扫描器第一次被拦截", "192.0.2.3", "/", "sqlmap/1.7", http.StatusForbidden},
+		{"扫描器第二次被拦截", "192.0.2.3", "/", "sqlmap/1.7", http.StatusForbidden},
+		{"扫描器换UA后仍被封禁", "192.0.2.3", "/", chrome120UA, http.StatusForbidden},
+		{"404", "192.0.2.4", "/a", chrome120UA, http.StatusNotFound},
+		{"404", "192.0.2.4", "/b", chrome120UA, http.StatusNotFound},
+		{"404达到限制记一次违规", "192.0.2.4", "/c", chrome120UA, http.StatusNotFound},
+		{"一次违规未封禁", "192.0.2.4", "/", chrome120UA, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request(tt.ip, tt.path, tt.ua))
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if ban, ok := b.Banned("192.0.2.2"); !ok || ban.Reason != "trap" {
+		t.Errorf("Banned() = %+v, %v, want trap ban", ban, ok)
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, request("192.0.2.2", "/", chrome120UA))
+	if rr.Header().Get("Retry-After") != "600" {
+		t.Errorf("Retry-After = %q, want 600", rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestBanManagerMiddlewareOptions(t *testing.T) {
+	b, _ := newTestBanManager(t, BanConfig{})
+	if _, err := b.Ban("192.0.2.1", time.Minute, "test"); err != nil {
+		t.Fatal(err)
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	request := func() *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		return req
+	}
+
+	var reports []Decision
+	rr := httptest.NewRecorder()
+	b.Middleware(WithReportOnly(func(r *http.Request, d Decision) { reports = append(reports, d) }))(handler).ServeHTTP(rr, request())
+	if rr.Code != http.StatusOK {
+		t.Errorf("仅报告模式 status code = %d, want %d", rr.Code, http.StatusOK)
+	}
+	if len(reports) != 1 || reports[0].Reason != "banned" || reports[0].Rule != "192.0.2.1/32" {
+		t.Errorf("reports = %+v", reports)
+	}
+
+	rr = httptest.NewRecorder()
+	b.Middleware(WithChallenge(cookieChallenger{}))(handler).ServeHTTP(rr, request())
+	if rr.Code != http.StatusServiceUnavailable {
+		t.Errorf("挑战 status code = %d, want %d", rr.Code, http.StatusServiceUnavailable)
+	}
+
+	rr = httptest.NewRecorder()
+	b.Middleware(WithStatus(http.StatusTooManyRequests))(handler).ServeHTTP(rr, request())
+	if rr.Code != http.StatusTooManyRequests || rr.Header().Get("Retry-After") != "60" {
+		t.Errorf("status code = %d, Retry-After = %q, want 429 and 60", rr.Code, rr.Header().Get("Retry-After"))
+	}
+}
+
+func TestBanManagerLimit(t *testing.T) {
+	b, now := newTestBanManager(t, BanConfig{})
+	if _, err := b.Ban("198.51.100.0/24", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 伪造大量客户端 IP 只会挤掉最早到期的封禁，不会清空封禁列表
+	b.mu.Lock()
+	for i := 0; i < banMaxBans; i++ {
+		prefix := netip.PrefixFrom(netip.AddrFrom4([4]byte{10, byte(i >> 16), byte(i >> 8), byte(i)}), 32)
+		b.add(&Ban{Prefix: prefix, Until: now.Add(time.Minute + time.Duration(i)*time.Millisecond)})
+	}
+	b.mu.Unlock()
+	if _, err := b.Ban("192.0.2.1", time.Hour, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(b.bans) > banMaxBans {
+		t.Errorf("len(bans) = %d, want <= %d", len(b.bans), banMaxBans)
+	}
+	if _, ok := b.Banned("192.0.2.1"); !ok {
+		t.Error("新的封禁不应被删除")
+	}
+	if _, ok := b.Banned("10.0.255.255"); !ok {
+		t.Error("最晚到期的封禁不应被删除")
+	}
+	if _, ok := b.Banned("10.0.0.0"); ok {
+		t.Error("最早到期的封禁应被删除")
+	}
+	if _, ok := b.Banned("198.51.100.7"); !ok {
+		t.Error("网段封禁不应被删除")
+	}
+}
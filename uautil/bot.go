@@ -48,6 +48,7 @@ var legitimateBotPatterns = []string{
 	"duckduckbot",     // DuckDuckGo
 	"baiduspider",     // Baidu
 	"yandexbot",       // Yandex
+	"applebot",        // Apple
 	"facebookexternalhit",
 	"twitterbot",
 	"linkedinbot",
@@ -56,8 +57,14 @@ var legitimateBotPatterns = []string{
 	"telegrambot",
 }
 
+// 公布了 IP 段、但默认仍按机器人处理的爬虫，只有通过 SetBotVerifier 设置的验证器验证后才视为合法爬虫
+var verifiedBotPatterns = []string{
+	"gptbot", // OpenAI
+}
+
 // IsBot 检测请求是否来自机器人
 // allowLegitimate 为 true 时允许合法的搜索引擎爬虫
+// 通过 SetBotVerifier 设置验证器后，合法爬虫还需要通过验证；AddBotSignal 添加的信号命中时总是视为机器人
 func IsBot(r *http.Request, allowLegitimate bool) bool {
 	userAgent := strings.ToLower(r.UserAgent())
 
@@ -66,18 +73,33 @@ func IsBot(r *http.Request, allowLegitimate bool) bool {
 		return true
 	}
 
+	// 外部信号（如蜜罐）标记的客户端无论 User-Agent 如何都视为机器人
+	if matchBotSignal(r) {
+		return true
+	}
+
 	// 如果允许合法爬虫，先检查是否是合法爬虫
 	if allowLegitimate {
-		for _, pattern := range legitimateBotPatterns {
-			if strings.Contains(userAgent, pattern) {
-				return false // 是合法爬虫，不拦截
+		if bot := matchLegitimateBot(userAgent); bot != "" {
+			// 冒充合法爬虫的请求视为机器人
+			if botVerifier != nil && botVerifier.VerifyBot(r, bot) == VerifyFailed {
+				return true
 			}
+			return false // 是合法爬虫，不拦截
+		}
+		if bot := matchVerifiedOnlyBot(userAgent); bot != "" && botVerifier != nil && botVerifier.VerifyBot(r, bot) == VerifyPassed {
+			return false
 		}
 	}
 
+	// 检查是否匹配规则集中的机器人规则
+	if matchBotRule(userAgent) != nil {
+		return true
+	}
+
 	// 检查是否匹配常见机器人特征
 	for _, pattern := range commonBotPatterns {
-		if strings.Contains(userAgent, pattern) {
+		if strings.Contains(userAgent, pattern) && builtinEnabled(pattern) {
 			return true
 		}
 	}
@@ -94,16 +116,16 @@ func IsBotUserAgent(userAgent string, allowLegitimate bool) bool {
 		return true
 	}
 
-	if allowLegitimate {
-		for _, pattern := range legitimateBotPatterns {
-			if strings.Contains(ua, pattern) {
-				return false
-			}
-		}
+	if allowLegitimate && matchLegitimateBot(ua) != "" {
+		return false
+	}
+
+	if matchBotRule(ua) != nil {
+		return true
 	}
 
 	for _, pattern := range commonBotPatterns {
-		if strings.Contains(ua, pattern) {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) {
 			return true
 		}
 	}
@@ -115,18 +137,34 @@ func IsBotUserAgent(userAgent string, allowLegitimate bool) bool {
 // allowLegitimate 为 true 时允许合法的搜索引擎爬虫
 // customMessage 是可选的自定义拒绝消息
 func BlockBotMiddleware(allowLegitimate bool, customMessage ...string) func(http.Handler) http.Handler {
-	message := "Bot access denied"
-	if len(customMessage) > 0 && customMessage[0] != "" {
-		message = customMessage[0]
+	var opts []Option
+	if len(customMessage) > 0 {
+		opts = append(opts, WithMessage(customMessage[0]))
+	}
+	return BlockBotMiddlewareWithOptions(allowLegitimate, opts...)
+}
+
+// BlockBotMiddlewareWithOptions 创建一个拦截机器人请求的中间件，通过 opts 配置拒绝的方式
+// 默认与 BlockBotMiddleware 相同，返回 403 和 "Bot access denied"
+func BlockBotMiddlewareWithOptions(allowLegitimate bool, opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions("BlockBotMiddleware", "Bot access denied", opts)
+	detect := func(r *http.Request) bool {
+		return IsBot(r, allowLegitimate)
+	}
+	describe := func(r *http.Request, d *Decision) {
+		d.Reason = "bot"
+		if r.UserAgent() == "" {
+			d.Reason = "empty user agent"
+		}
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Bot = sig
+			d.Rule = sig.Pattern
+		}
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if IsBot(r, allowLegitimate) {
-				http.Error(w, message, http.StatusForbidden)
-				return
-			}
-			next.ServeHTTP(w, r)
+			o.serve(w, r, next, detect, describe)
 		})
 	}
 }
@@ -163,6 +201,35 @@ func AddLegitimateBot(pattern string) func() {
 	}
 }
 
+// 外部的机器人信号，由 IsBot 检查
+var botSignals []*func(r *http.Request) bool
+
+// AddBotSignal 添加 IsBot 检查的机器人信号，如 Honeypot.Flagged，signal 返回 true 时请求被视为机器人
+// 应在处理请求之前调用，返回的函数可用于移除该信号
+func AddBotSignal(signal func(r *http.Request) bool) func() {
+	entry := &signal
+	botSignals = append(botSignals, entry)
+
+	return func() {
+		for i, e := range botSignals {
+			if e == entry {
+				botSignals = append(botSignals[:i], botSignals[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// matchBotSignal 报告请求是否命中任一机器人信号
+func matchBotSignal(r *http.Request) bool {
+	for _, signal := range botSignals {
+		if (*signal)(r) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetBotPatterns 获取当前的机器人特征列表（副本）
 func GetBotPatterns() []string {
 	patterns := make([]string, len(commonBotPatterns))
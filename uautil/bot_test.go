@@ -279,6 +279,30 @@ func TestAddLegitimateBot(t *testing.T) {
 	}
 }
 
+func TestAddBotSignal(t *testing.T) {
+	// 标记特定 IP 的信号
+	remove := AddBotSignal(func(r *http.Request) bool {
+		return r.RemoteAddr == "203.0.113.9:1234"
+	})
+	defer remove()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	req.RemoteAddr = "203.0.113.9:1234"
+
+	// 命中信号时即使是合法爬虫也视为机器人
+	if !IsBot(req, true) {
+		t.Error("命中信号的请求应该被识别为机器人")
+	}
+
+	// 移除信号
+	remove()
+
+	if IsBot(req, true) {
+		t.Error("移除后合法爬虫不应该被识别为机器人")
+	}
+}
+
 func TestGetPatterns(t *testing.T) {
 	botPatterns := GetBotPatterns()
 	if len(botPatterns) == 0 {
@@ -2,6 +2,7 @@ package uautil
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -34,16 +35,24 @@ func IsBrowserUserAgent(userAgent string) bool {
 		return false
 	}
 
+	// 规则集中的规则优先
+	if matchBotRule(ua) != nil {
+		return false
+	}
+	if rule := matchBrowserRule(ua); rule != nil {
+		return rule.allow
+	}
+
 	// 如果匹配到机器人特征,不是浏览器
 	for _, pattern := range commonBotPatterns {
-		if strings.Contains(ua, pattern) {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) {
 			return false
 		}
 	}
 
 	// 检查是否包含浏览器特征
 	for _, pattern := range browserPatterns {
-		if strings.Contains(ua, pattern) {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) {
 			return true
 		}
 	}
@@ -54,18 +63,44 @@ func IsBrowserUserAgent(userAgent string) bool {
 // BrowserOnlyMiddleware 创建一个中间件,仅允许浏览器访问
 // customMessage 是可选的自定义拒绝消息
 func BrowserOnlyMiddleware(customMessage ...string) func(http.Handler) http.Handler {
-	message := "Browser access only"
-	if len(customMessage) > 0 && customMessage[0] != "" {
-		message = customMessage[0]
+	var opts []Option
+	if len(customMessage) > 0 {
+		opts = append(opts, WithMessage(customMessage[0]))
+	}
+	return BrowserOnlyMiddlewareWithOptions(opts...)
+}
+
+// BrowserOnlyMiddlewareWithOptions 创建一个仅允许浏览器访问的中间件，通过 opts 配置拒绝的方式
+// 默认与 BrowserOnlyMiddleware 相同，返回 403 和 "Browser access only"
+func BrowserOnlyMiddlewareWithOptions(opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions("BrowserOnlyMiddleware", "Browser access only", opts)
+	detect := func(r *http.Request) bool {
+		if !IsBrowser(r) {
+			return true
+		}
+		return o.minConsistency > 0 && !IsConsistentBrowser(r, o.minConsistency)
+	}
+	describe := func(r *http.Request, d *Decision) {
+		d.Reason = "not a browser"
+		if IsBrowser(r) {
+			d.Reason = "inconsistent browser"
+			d.Rule = "min consistency " + strconv.Itoa(o.minConsistency)
+		}
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Bot = sig
+			if d.Rule == "" {
+				d.Rule = sig.Pattern
+			}
+		}
+		if o.minConsistency > 0 {
+			c := CheckConsistency(r)
+			d.Consistency = &c
+		}
 	}
 
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if !IsBrowser(r) {
-				http.Error(w, message, http.StatusForbidden)
-				return
-			}
-			next.ServeHTTP(w, r)
+			o.serve(w, r, next, detect, describe)
 		})
 	}
 }
@@ -0,0 +1,324 @@
+package uautil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// BotCategory 机器人类别
+type BotCategory string
+
+// 机器人类别
+const (
+	CategorySearchEngine    BotCategory = "search_engine"    // 搜索引擎
+	CategorySocialPreview   BotCategory = "social_preview"   // 社交媒体链接预览
+	CategorySEOTool         BotCategory = "seo_tool"         // SEO 工具
+	CategoryMonitoring      BotCategory = "monitoring"       // 可用性监控
+	CategoryFeedReader      BotCategory = "feed_reader"      // RSS 阅读器
+	CategoryAICrawler       BotCategory = "ai_crawler"       // AI 爬虫
+	CategoryHTTPLibrary     BotCategory = "http_library"     // HTTP 库和命令行工具
+	CategoryHeadlessBrowser BotCategory = "headless_browser" // 无头浏览器和自动化工具
+	CategorySecurityScanner BotCategory = "security_scanner" // 安全扫描器
+	CategoryUnknown         BotCategory = "unknown"          // 无法归类的机器人
+)
+
+// BotCategories 返回全部机器人类别
+func BotCategories() []BotCategory {
+	return []BotCategory{
+		CategorySearchEngine, CategorySocialPreview, CategorySEOTool, CategoryMonitoring, CategoryFeedReader,
+		CategoryAICrawler, CategoryHTTPLibrary, CategoryHeadlessBrowser, CategorySecurityScanner, CategoryUnknown,
+	}
+}
+
+func isValidCategory(category BotCategory) bool {
+	for _, c := range BotCategories() {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// BotSignature 是带类别的机器人特征
+type BotSignature struct {
+	Pattern  string      // User-Agent 特征（小写）
+	Name     string      // 机器人名称
+	Category BotCategory // 类别
+}
+
+// 带类别的机器人特征，commonBotPatterns 和 legitimateBotPatterns 中的每个特征都在这里标注了类别
+// 通用特征（bot、crawler、spider、scraper）最后匹配，见 genericBotSignatures
+var botSignatures = []BotSignature{
+	// 搜索引擎
+	{"googlebot", "Googlebot", CategorySearchEngine},
+	{"bingbot", "Bingbot", CategorySearchEngine},
+	{"slurp", "Yahoo! Slurp", CategorySearchEngine},
+	{"duckduckbot", "DuckDuckBot", CategorySearchEngine},
+	{"baiduspider", "Baiduspider", CategorySearchEngine},
+	{"yandexbot", "YandexBot", CategorySearchEngine},
+	{"applebot", "Applebot", CategorySearchEngine},
+	{"petalbot", "PetalBot", CategorySearchEngine},
+	{"sogou web spider", "Sogou Spider", CategorySearchEngine},
+	{"360spider", "360Spider", CategorySearchEngine},
+	{"yisouspider", "YisouSpider", CategorySearchEngine},
+	{"seznambot", "SeznamBot", CategorySearchEngine},
+	{"yeti/", "Naver Yeti", CategorySearchEngine},
+
+	// 社交媒体链接预览
+	{"facebookexternalhit", "Facebook", CategorySocialPreview},
+	{"twitterbot", "Twitterbot", CategorySocialPreview},
+	{"linkedinbot", "LinkedInBot", CategorySocialPreview},
+	{"slackbot", "Slackbot", CategorySocialPreview},
+	{"discordbot", "Discordbot", CategorySocialPreview},
+	{"telegrambot", "TelegramBot", CategorySocialPreview},
+	{"whatsapp", "WhatsApp", CategorySocialPreview},
+	{"skypeuripreview", "Skype", CategorySocialPreview},
+	{"redditbot", "Redditbot", CategorySocialPreview},
+	{"pinterestbot", "Pinterestbot", CategorySocialPreview},
+	{"embedly", "Embedly", CategorySocialPreview},
+	{"iframely", "Iframely", CategorySocialPreview},
+	{"mastodon", "Mastodon", CategorySocialPreview},
+
+	// SEO 工具
+	{"ahrefsbot", "AhrefsBot", CategorySEOTool},
+	{"semrushbot", "SemrushBot", CategorySEOTool},
+	{"mj12bot", "MJ12bot", CategorySEOTool},
+	{"dotbot", "DotBot", CategorySEOTool},
+	{"rogerbot", "Rogerbot", CategorySEOTool},
+	{"screaming frog", "Screaming Frog", CategorySEOTool},
+	{"serpstatbot", "SerpstatBot", CategorySEOTool},
+	{"blexbot", "BLEXBot", CategorySEOTool},
+	{"dataforseobot", "DataForSeoBot", CategorySEOTool},
+
+	// 可用性监控
+	{"uptimerobot", "UptimeRobot", CategoryMonitoring},
+	{"pingdom", "Pingdom", CategoryMonitoring},
+	{"statuscake", "StatusCake", CategoryMonitoring},
+	{"site24x7", "Site24x7", CategoryMonitoring},
+	{"newrelicpinger", "New Relic", CategoryMonitoring},
+	{"datadogsynthetics", "Datadog Synthetics", CategoryMonitoring},
+	{"betteruptime", "Better Uptime", CategoryMonitoring},
+	{"checkly", "Checkly", CategoryMonitoring},
+	{"uptime-kuma", "Uptime Kuma", CategoryMonitoring},
+
+	// RSS 阅读器
+	{"feedfetcher-google", "Feedfetcher", CategoryFeedReader},
+	{"feedly", "Feedly", CategoryFeedReader},
+	{"inoreader", "Inoreader", CategoryFeedReader},
+	{"newsblur", "NewsBlur", CategoryFeedReader},
+	{"feedbin", "Feedbin", CategoryFeedReader},
+	{"miniflux", "Miniflux", CategoryFeedReader},
+	{"tiny tiny rss", "Tiny Tiny RSS", CategoryFeedReader},
+	{"theoldreader", "The Old Reader", CategoryFeedReader},
+
+	// HTTP 库和命令行工具
+	{"python-requests", "Python Requests", CategoryHTTPLibrary},
+	{"python-urllib", "Python urllib", CategoryHTTPLibrary},
+	{"python-httpx", "HTTPX", CategoryHTTPLibrary},
+	{"aiohttp", "aiohttp", CategoryHTTPLibrary},
+	{"curl", "curl", CategoryHTTPLibrary},
+	{"wget", "Wget", CategoryHTTPLibrary},
+	{"java/", "Java", CategoryHTTPLibrary},
+	{"okhttp", "OkHttp", CategoryHTTPLibrary},
+	{"go-http-client", "Go http client", CategoryHTTPLibrary},
+	{"apache-httpclient", "Apache HttpClient", CategoryHTTPLibrary},
+	{"node-fetch", "node-fetch", CategoryHTTPLibrary},
+	{"axios/", "axios", CategoryHTTPLibrary},
+	{"libwww-perl", "libwww-perl", CategoryHTTPLibrary},
+	{"guzzlehttp", "Guzzle", CategoryHTTPLibrary},
+	{"scrapy", "Scrapy", CategoryHTTPLibrary},
+
+	// 无头浏览器和自动化工具
+	{"headless", "Headless Browser", CategoryHeadlessBrowser},
+	{"selenium", "Selenium", CategoryHeadlessBrowser},
+	{"phantomjs", "PhantomJS", CategoryHeadlessBrowser},
+	{"puppeteer", "Puppeteer", CategoryHeadlessBrowser},
+	{"playwright", "Playwright", CategoryHeadlessBrowser},
+
+	// 安全扫描器
+	{"nmap", "Nmap", CategorySecurityScanner},
+	{"masscan", "Masscan", CategorySecurityScanner},
+	{"nikto", "Nikto", CategorySecurityScanner},
+	{"sqlmap", "sqlmap", CategorySecurityScanner},
+	{"nessus", "Nessus", CategorySecurityScanner},
+	{"openvas", "OpenVAS", CategorySecurityScanner},
+	{"acunetix", "Acunetix", CategorySecurityScanner},
+	{"zgrab", "ZGrab", CategorySecurityScanner},
+	{"nuclei", "Nuclei", CategorySecurityScanner},
+	{"wpscan", "WPScan", CategorySecurityScanner},
+	{"dirbuster", "DirBuster", CategorySecurityScanner},
+	{"gobuster", "Gobuster", CategorySecurityScanner},
+}
+
+// 通用机器人特征，只在其他特征都未命中时使用
+var genericBotSignatures = []BotSignature{
+	{"bot", "Generic Bot", CategoryUnknown},
+	{"crawler", "Generic Crawler", CategoryUnknown},
+	{"spider", "Generic Spider", CategoryUnknown},
+	{"scraper", "Generic Scraper", CategoryUnknown},
+}
+
+// ClassifyBot 判断 User-Agent 属于哪类机器人，不是机器人时第二个返回值为 false
+// 匹配顺序为：规则集、AI 爬虫、带类别的特征、自定义特征、通用特征（bot、crawler 等）
+// 空 User-Agent 归为 CategoryUnknown
+func ClassifyBot(userAgent string) (BotSignature, bool) {
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		return BotSignature{Name: "Empty User-Agent", Category: CategoryUnknown}, true
+	}
+
+	if rule := matchBotRule(ua); rule != nil {
+		sig := BotSignature{Pattern: rule.pattern, Name: rule.Name, Category: rule.Category}
+		if sig.Name == "" {
+			sig.Name = rule.Pattern
+		}
+		if sig.Category == "" {
+			sig.Category = CategoryUnknown
+		}
+		return sig, true
+	}
+
+	if c, ok := matchBuiltinAICrawler(ua); ok {
+		return BotSignature{Pattern: c.Pattern, Name: c.Name, Category: CategoryAICrawler}, true
+	}
+
+	for _, sig := range botSignatures {
+		if strings.Contains(ua, sig.Pattern) && builtinEnabled(sig.Pattern) {
+			return sig, true
+		}
+	}
+
+	// 通过 AddCustomBotPattern、AddLegitimateBot 添加的特征
+	for _, pattern := range legitimateBotPatterns {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) {
+			return BotSignature{Pattern: pattern, Name: pattern, Category: CategorySearchEngine}, true
+		}
+	}
+	for _, pattern := range commonBotPatterns {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) && !isGenericBotPattern(pattern) {
+			return BotSignature{Pattern: pattern, Name: pattern, Category: CategoryUnknown}, true
+		}
+	}
+
+	for _, sig := range genericBotSignatures {
+		if strings.Contains(ua, sig.Pattern) && builtinEnabled(sig.Pattern) {
+			return sig, true
+		}
+	}
+	return BotSignature{}, false
+}
+
+// ClassifyRequest 判断请求属于哪类机器人
+// 通过 SetBotVerifier 设置验证器后，冒充合法爬虫的请求归为 CategoryUnknown
+func ClassifyRequest(r *http.Request) (BotSignature, bool) {
+	sig, ok := ClassifyBot(r.UserAgent())
+	if !ok || botVerifier == nil {
+		return sig, ok
+	}
+
+	if bot := matchLegitimateBot(strings.ToLower(r.UserAgent())); bot != "" {
+		if botVerifier.VerifyBot(r, bot) == VerifyFailed {
+			sig.Category = CategoryUnknown
+		}
+	}
+	return sig, ok
+}
+
+func isGenericBotPattern(pattern string) bool {
+	for _, sig := range genericBotSignatures {
+		if sig.Pattern == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// BotPolicy 按机器人类别决定放行或拦截
+type BotPolicy struct {
+	// Default 为未在 Categories 中列出的类别的处理方式
+	Default Action
+	// Categories 为各类别的处理方式
+	Categories map[BotCategory]Action
+}
+
+// Decide 返回策略对该类别机器人的处理方式
+func (p BotPolicy) Decide(category BotCategory) Action {
+	if action, ok := p.Categories[category]; ok {
+		return action
+	}
+	return p.Default
+}
+
+// AllowCategories 创建只放行指定类别的策略，其他机器人一律拦截
+func AllowCategories(categories ...BotCategory) BotPolicy {
+	policy := BotPolicy{Default: ActionBlock, Categories: make(map[BotCategory]Action, len(categories))}
+	for _, c := range categories {
+		policy.Categories[c] = ActionAllow
+	}
+	return policy
+}
+
+// BlockCategories 创建只拦截指定类别的策略，其他机器人一律放行
+func BlockCategories(categories ...BotCategory) BotPolicy {
+	policy := BotPolicy{Default: ActionAllow, Categories: make(map[BotCategory]Action, len(categories))}
+	for _, c := range categories {
+		policy.Categories[c] = ActionBlock
+	}
+	return policy
+}
+
+// BotPolicyMiddleware 创建一个中间件,按机器人类别放行或拦截请求，非机器人请求不受影响
+// customMessage 是可选的自定义拒绝消息
+func BotPolicyMiddleware(policy BotPolicy, customMessage ...string) func(http.Handler) http.Handler {
+	var opts []Option
+	if len(customMessage) > 0 {
+		opts = append(opts, WithMessage(customMessage[0]))
+	}
+	return BotPolicyMiddlewareWithOptions(policy, opts...)
+}
+
+// BotPolicyMiddlewareWithOptions 创建一个按机器人类别放行或拦截请求的中间件，通过 opts 配置拒绝的方式
+// 默认与 BotPolicyMiddleware 相同，返回 403 和 "Bot access denied"
+func BotPolicyMiddlewareWithOptions(policy BotPolicy, opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions("BotPolicyMiddleware", "Bot access denied", opts)
+	detect := func(r *http.Request) bool {
+		sig, ok := ClassifyRequest(r)
+		return ok && policy.Decide(sig.Category) == ActionBlock
+	}
+	describe := func(r *http.Request, d *Decision) {
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Reason = "bot category " + string(sig.Category)
+			d.Rule = sig.Pattern
+			d.Bot = sig
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o.serve(w, r, next, detect, describe)
+		})
+	}
+}
+
+// AddBotSignature 添加自定义的带类别机器人特征，优先于内置特征匹配
+// 返回的函数可用于移除该特征
+func AddBotSignature(sig BotSignature) func() {
+	sig.Pattern = strings.ToLower(sig.Pattern)
+	botSignatures = append([]BotSignature{sig}, botSignatures...)
+
+	return func() {
+		for i, s := range botSignatures {
+			if s == sig {
+				botSignatures = append(botSignatures[:i], botSignatures[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// GetBotSignatures 获取当前带类别的机器人特征列表（副本），不包括 AI 爬虫和通用特征
+func GetBotSignatures() []BotSignature {
+	signatures := make([]BotSignature, len(botSignatures))
+	copy(signatures, botSignatures)
+	return signatures
+}
@@ -0,0 +1,177 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestBuiltinPatternsHaveCategory(t *testing.T) {
+	categorized := make(map[string]BotCategory)
+	for _, sig := range botSignatures {
+		if sig.Category == "" || sig.Category == CategoryUnknown {
+			t.Errorf("特征 %q 缺少具体类别", sig.Pattern)
+		}
+		categorized[sig.Pattern] = sig.Category
+	}
+	for _, sig := range genericBotSignatures {
+		categorized[sig.Pattern] = sig.Category
+	}
+
+	for _, pattern := range commonBotPatterns {
+		if _, ok := categorized[pattern]; !ok {
+			t.Errorf("commonBotPatterns 中的 %q 没有类别", pattern)
+		}
+	}
+	for _, pattern := range legitimateBotPatterns {
+		category, ok := categorized[pattern]
+		if !ok {
+			t.Errorf("legitimateBotPatterns 中的 %q 没有类别", pattern)
+		}
+		if category != CategorySearchEngine && category != CategorySocialPreview {
+			t.Errorf("合法爬虫 %q 的类别 = %q", pattern, category)
+		}
+	}
+}
+
+func TestClassifyBot(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      BotCategory
+	}{
+		{"Googlebot", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", CategorySearchEngine},
+		{"Baiduspider", "Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)", CategorySearchEngine},
+		{"Facebook", "facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", CategorySocialPreview},
+		{"Slackbot", "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", CategorySocialPreview},
+		{"AhrefsBot", "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)", CategorySEOTool},
+		{"SemrushBot", "Mozilla/5.0 (compatible; SemrushBot/7~bl; +http://www.semrush.com/bot.html)", CategorySEOTool},
+		{"UptimeRobot", "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", CategoryMonitoring},
+		{"Pingdom", "Pingdom.com_bot_version_1.4_(http://www.pingdom.com/)", CategoryMonitoring},
+		{"Feedly", "Feedly/1.0 (+http://www.feedly.com/fetcher.html; 16 subscribers)", CategoryFeedReader},
+		{"GPTBot", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.2; +https://openai.com/gptbot)", CategoryAICrawler},
+		{"curl", "curl/7.68.0", CategoryHTTPLibrary},
+		{"Python Requests", "python-requests/2.25.1", CategoryHTTPLibrary},
+		{"HeadlessChrome", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36", CategoryHeadlessBrowser},
+		{"sqlmap", "sqlmap/1.5#stable (http://sqlmap.org)", CategorySecurityScanner},
+		{"Nuclei", "Mozilla/5.0 (compatible; Nuclei - Open-source project (github.com/projectdiscovery/nuclei))", CategorySecurityScanner},
+		{"通用爬虫", "SomeCrawler/1.0", CategoryUnknown},
+		{"通用Bot", "MyCustomBot/1.0", CategoryUnknown},
+		{"空UA", "", CategoryUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sig, ok := ClassifyBot(tt.userAgent)
+			if !ok {
+				t.Fatalf("ClassifyBot(%q) 未识别为机器人", tt.userAgent)
+			}
+			if sig.Category != tt.want {
+				t.Errorf("ClassifyBot(%q) = %q (%s), want %q", tt.userAgent, sig.Category, sig.Name, tt.want)
+			}
+		})
+	}
+
+	if sig, ok := ClassifyBot("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"); ok {
+		t.Errorf("浏览器被识别为 %q", sig.Category)
+	}
+}
+
+func TestClassifyBotCustomPatterns(t *testing.T) {
+	removeSig := AddBotSignature(BotSignature{Pattern: "AcmeMonitor", Name: "Acme", Category: CategoryMonitoring})
+	removeCommon := AddCustomBotPattern("evilfetch")
+	removeLegit := AddLegitimateBot("goodsearch")
+
+	tests := []struct {
+		userAgent string
+		want      BotCategory
+	}{
+		{"AcmeMonitor/2.0", CategoryMonitoring},
+		{"EvilFetch/1.0", CategoryUnknown},
+		{"GoodSearch/1.0", CategorySearchEngine},
+	}
+	for _, tt := range tests {
+		if sig, ok := ClassifyBot(tt.userAgent); !ok || sig.Category != tt.want {
+			t.Errorf("ClassifyBot(%q) = %q %v, want %q", tt.userAgent, sig.Category, ok, tt.want)
+		}
+	}
+
+	removeSig()
+	removeCommon()
+	removeLegit()
+	for _, tt := range tests {
+		if sig, ok := ClassifyBot(tt.userAgent); ok {
+			t.Errorf("移除后 ClassifyBot(%q) = %q", tt.userAgent, sig.Category)
+		}
+	}
+}
+
+func TestClassifyRequestWithVerifier(t *testing.T) {
+	restore := SetBotVerifier(NewDNSVerifier(newFakeResolver(), time.Minute))
+	defer restore()
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+
+	req.RemoteAddr = "66.249.66.1:12345"
+	if sig, _ := ClassifyRequest(req); sig.Category != CategorySearchEngine {
+		t.Errorf("真实Googlebot类别 = %q, want %q", sig.Category, CategorySearchEngine)
+	}
+
+	req.RemoteAddr = "198.51.100.10:12345"
+	if sig, _ := ClassifyRequest(req); sig.Category != CategoryUnknown {
+		t.Errorf("冒充Googlebot类别 = %q, want %q", sig.Category, CategoryUnknown)
+	}
+}
+
+func TestBotPolicyMiddleware(t *testing.T) {
+	const (
+		chrome    = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+		googlebot = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+		ahrefs    = "Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)"
+		twitter   = "Twitterbot/1.0"
+		uptime    = "Mozilla/5.0+(compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)"
+		nikto     = "Mozilla/5.00 (Nikto/2.1.6) (Evasions:None) (Test:Port Check)"
+		crawler   = "SomeCrawler/1.0"
+	)
+
+	allowList := AllowCategories(CategorySearchEngine, CategorySEOTool, CategorySocialPreview, CategoryMonitoring)
+	blockList := BlockCategories(CategorySecurityScanner)
+
+	tests := []struct {
+		name           string
+		policy         BotPolicy
+		userAgent      string
+		wantStatusCode int
+	}{
+		{"浏览器不受影响", allowList, chrome, http.StatusOK},
+		{"允许搜索引擎", allowList, googlebot, http.StatusOK},
+		{"允许SEO工具", allowList, ahrefs, http.StatusOK},
+		{"允许社交预览", allowList, twitter, http.StatusOK},
+		{"允许监控", allowList, uptime, http.StatusOK},
+		{"拦截扫描器", allowList, nikto, http.StatusForbidden},
+		{"拦截通用爬虫", allowList, crawler, http.StatusForbidden},
+		{"拦截空UA", allowList, "", http.StatusForbidden},
+		{"黑名单拦截扫描器", blockList, nikto, http.StatusForbidden},
+		{"黑名单放行通用爬虫", blockList, crawler, http.StatusOK},
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rr := httptest.NewRecorder()
+
+			BotPolicyMiddleware(tt.policy)(handler).ServeHTTP(rr, req)
+
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package uautil
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"math/bits"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+const (
+	// challengeSolutionCookie 是挑战页面提交答案使用的 Cookie 名称
+	challengeSolutionCookie = "uautil_challenge"
+	// challengeSolveTime 是挑战的有效期，超过后需要重新获取挑战
+	challengeSolveTime = 5 * time.Minute
+)
+
+// JSChallenge 是基于 JavaScript 工作量证明的挑战
+// 挑战页面在浏览器中计算 SHA-256 前导零位数不低于 difficulty 的答案，写入 Cookie 后刷新页面；
+// 服务端验证答案后通过 Clearance 颁发通行凭证，有效期内不再挑战
+// 挑战和凭证都不需要服务端存储，多个实例使用相同的密钥即可共享
+type JSChallenge struct {
+	clearance  *Clearance
+	difficulty int
+	ttl        time.Duration
+}
+
+// NewJSChallenge 创建 JavaScript 挑战
+// secret 为 HMAC 密钥，为空时随机生成（重启后已颁发的凭证失效）；
+// difficulty 为答案哈希的前导零位数，默认 16（普通设备约 1 秒）；ttl 为通过挑战后的有效期，默认 1 小时
+func NewJSChallenge(secret []byte, difficulty int, ttl time.Duration) *JSChallenge {
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic("uautil: failed to generate challenge secret: " + err.Error())
+		}
+	}
+	clearance, _ := NewClearance(ClearanceKey{ID: "default", Secret: secret})
+	return NewJSChallengeWithClearance(clearance, difficulty, ttl)
+}
+
+// NewJSChallengeWithClearance 创建使用 clearance 签名挑战和颁发凭证的 JavaScript 挑战，用于轮换密钥或与 ClearanceMiddleware 共享凭证
+func NewJSChallengeWithClearance(clearance *Clearance, difficulty int, ttl time.Duration) *JSChallenge {
+	if difficulty <= 0 {
+		difficulty = 16
+	}
+	if ttl <= 0 {
+		ttl = time.Hour
+	}
+	return &JSChallenge{clearance: clearance, difficulty: difficulty, ttl: ttl}
+}
+
+// Passed 报告请求是否带有有效的通行凭证
+func (c *JSChallenge) Passed(r *http.Request) bool {
+	t, ok := c.clearance.Verify(r)
+	return ok && t.Verdict == ActionAllow
+}
+
+// ServeChallenge 返回挑战页面
+// 请求中带有正确答案时改为颁发通过凭证，并重定向到原地址
+func (c *JSChallenge) ServeChallenge(w http.ResponseWriter, r *http.Request) {
+	ip := iputil.GetClientIP(r)
+
+	if cookie, err := r.Cookie(challengeSolutionCookie); err == nil && c.solved(cookie.Value, ip) {
+		c.clearance.Issue(w, r, ActionAllow, c.ttl)
+		http.SetCookie(w, &http.Cookie{Name: challengeSolutionCookie, Path: "/", MaxAge: -1})
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, r.URL.RequestURI(), http.StatusSeeOther)
+		return
+	}
+
+	nonce := make([]byte, 16)
+	rand.Read(nonce)
+	n := hex.EncodeToString(nonce)
+	expires := strconv.FormatInt(c.clearance.now().Add(challengeSolveTime).Unix(), 10)
+	key := c.clearance.signingKey()
+	mac := hmacSum(key.Secret, challengeMessage(ip, n, expires))
+	challenge := n + "." + expires + "." + key.ID + "." + hex.EncodeToString(mac)
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusForbidden)
+	strings.NewReplacer(
+		"{{challenge}}", challenge,
+		"{{difficulty}}", strconv.Itoa(c.difficulty),
+		"{{cookie}}", challengeSolutionCookie,
+	).WriteString(w, challengePage)
+}
+
+// solved 验证答案：挑战由本服务为该 IP 签发且未过期，哈希的前导零位数满足难度
+// 答案的格式为 nonce.expires.kid.mac.counter
+func (c *JSChallenge) solved(value, ip string) bool {
+	parts := strings.Split(value, ".")
+	if len(parts) != 5 {
+		return false
+	}
+	nonce, expires, kid, counter := parts[0], parts[1], parts[2], parts[4]
+	mac, err := hex.DecodeString(parts[3])
+	if err != nil || !c.clearance.verify(kid, challengeMessage(ip, nonce, expires), mac) {
+		return false
+	}
+	if unix, err := strconv.ParseInt(expires, 10, 64); err != nil || c.clearance.now().Unix() >= unix {
+		return false
+	}
+	if _, err := strconv.ParseUint(counter, 10, 64); err != nil {
+		return false
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(parts[:4], ".") + ":" + counter))
+	return leadingZeroBits(sum[:]) >= c.difficulty
+}
+
+// challengeMessage 返回挑战签名的内容
+func challengeMessage(ip, nonce, expires string) string {
+	return "challenge|" + ip + "|" + nonce + "|" + expires
+}
+
+// leadingZeroBits 返回 b 的前导零位数
+func leadingZeroBits(b []byte) int {
+	n := 0
+	for _, v := range b {
+		if v != 0 {
+			return n + bits.LeadingZeros8(v)
+		}
+		n += 8
+	}
+	return n
+}
+
+// ChallengeMiddleware 创建一个中间件，对可疑请求发起挑战而不是直接拒绝
+// suspect 判断请求是否可疑，为 nil 时使用 IsBot(r, true)；可疑且未通过挑战的请求由 ch 返回挑战页面
+// 被误判为机器人的真实用户通过挑战后即可正常访问，不执行 JavaScript 的脚本无法通过
+func ChallengeMiddleware(ch Challenger, suspect func(r *http.Request) bool) func(http.Handler) http.Handler {
+	if suspect == nil {
+		suspect = func(r *http.Request) bool { return IsBot(r, true) }
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if suspect(r) && !ch.Passed(r) {
+				ch.ServeChallenge(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// challengePage 是挑战页面，内置 SHA-256 实现，在非 HTTPS 页面（没有 crypto.subtle）中也能运行
+const challengePage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Checking your browser</title>
+</head>
+<body>
+<p>Checking your browser&hellip;</p>
+<noscript><p>Please enable JavaScript to continue.</p></noscript>
+<script>
+(function () {
+  var K = [], H = [], p = 2, i;
+  while (K.length < 64) {
+    for (i = 2; i * i <= p && p % i; i++);
+    if (i * i > p) {
+      if (H.length < 8) H.push((Math.pow(p, 1 / 2) * 4294967296) | 0);
+      K.push((Math.pow(p, 1 / 3) * 4294967296) | 0);
+    }
+    p++;
+  }
+  function rr(v, n) { return (v >>> n) | (v << (32 - n)); }
+  function sha256(s) {
+    var b = [], i, j, w = [], h = H.slice();
+    for (i = 0; i < s.length; i++) b.push(s.charCodeAt(i) & 255);
+    var len = b.length * 8;
+    b.push(128);
+    while (b.length % 64 !== 56) b.push(0);
+    for (i = 7; i >= 0; i--) b.push(i > 3 ? 0 : (len >>> (i * 8)) & 255);
+    for (j = 0; j < b.length; j += 64) {
+      for (i = 0; i < 16; i++) w[i] = (b[j + 4 * i] << 24) | (b[j + 4 * i + 1] << 16) | (b[j + 4 * i + 2] << 8) | b[j + 4 * i + 3];
+      for (i = 16; i < 64; i++) {
+        var s0 = rr(w[i - 15], 7) ^ rr(w[i - 15], 18) ^ (w[i - 15] >>> 3);
+        var s1 = rr(w[i - 2], 17) ^ rr(w[i - 2], 19) ^ (w[i - 2] >>> 10);
+        w[i] = (w[i - 16] + s0 + w[i - 7] + s1) | 0;
+      }
+      var a = h[0], c = h[1], d = h[2], e = h[3], f = h[4], g = h[5], k = h[6], l = h[7];
+      for (i = 0; i < 64; i++) {
+        var t1 = (l + (rr(f, 6) ^ rr(f, 11) ^ rr(f, 25)) + ((f & g) ^ (~f & k)) + K[i] + w[i]) | 0;
+        var t2 = ((rr(a, 2) ^ rr(a, 13) ^ rr(a, 22)) + ((a & c) ^ (a & d) ^ (c & d))) | 0;
+        l = k; k = g; g = f; f = (e + t1) | 0; e = d; d = c; c = a; a = (t1 + t2) | 0;
+      }
+      h[0] = (h[0] + a) | 0; h[1] = (h[1] + c) | 0; h[2] = (h[2] + d) | 0; h[3] = (h[3] + e) | 0;
+      h[4] = (h[4] + f) | 0; h[5] = (h[5] + g) | 0; h[6] = (h[6] + k) | 0; h[7] = (h[7] + l) | 0;
+    }
+    return h;
+  }
+  function zeros(h) {
+    for (var i = 0, n = 0; i < 8; i++, n += 32) if (h[i] !== 0) return n + Math.clz32(h[i]);
+    return 256;
+  }
+  var challenge = "{{challenge}}", difficulty = {{difficulty}}, counter = 0;
+  function step() {
+    for (var i = 0; i < 5000; i++, counter++) {
+      if (zeros(sha256(challenge + ":" + counter)) >= difficulty) {
+        document.cookie = "{{cookie}}=" + challenge + "." + counter + "; path=/; max-age=300; SameSite=Lax";
+        location.replace(location.href);
+        return;
+      }
+    }
+    setTimeout(step, 0);
+  }
+  step();
+})();
+</script>
+</body>
+</html>
+`
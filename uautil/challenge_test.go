@@ -0,0 +1,166 @@
+package uautil
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var challengePattern = regexp.MustCompile(`var challenge = "([^"]+)"`)
+
+// solveChallenge 从挑战页面中取出挑战并计算答案
+func solveChallenge(t *testing.T, page string, difficulty int) string {
+	t.Helper()
+	m := challengePattern.FindStringSubmatch(page)
+	if m == nil {
+		t.Fatalf("挑战页面中没有挑战: %s", page)
+	}
+	for counter := 0; ; counter++ {
+		sum := sha256.Sum256([]byte(m[1] + ":" + strconv.Itoa(counter)))
+		if leadingZeroBits(sum[:]) >= difficulty {
+			return m[1] + "." + strconv.Itoa(counter)
+		}
+	}
+}
+
+func challengeRequest(remoteAddr string, cookies ...*http.Cookie) *http.Request {
+	req := httptest.NewRequest("GET", "/page?q=1", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", "curl/8.0")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	return req
+}
+
+func TestJSChallenge(t *testing.T) {
+	ch := NewJSChallenge([]byte("secret"), 8, time.Hour)
+	now := time.Now()
+	ch.clearance.now = func() time.Time { return now }
+
+	if ch.Passed(challengeRequest("203.0.113.1:1234")) {
+		t.Fatal("没有凭证的请求不应通过")
+	}
+
+	// 获取挑战
+	rr := httptest.NewRecorder()
+	ch.ServeChallenge(rr, challengeRequest("203.0.113.1:1234"))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("挑战页面 status code = %d, want %d", rr.Code, http.StatusForbidden)
+	}
+	solution := &http.Cookie{Name: challengeSolutionCookie, Value: solveChallenge(t, rr.Body.String(), 8)}
+
+	// 其他 IP 提交答案
+	rr = httptest.NewRecorder()
+	ch.ServeChallenge(rr, challengeRequest("198.51.100.1:1234", solution))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("其他IP提交答案 status code = %d, want %d", rr.Code, http.StatusForbidden)
+	}
+
+	// 提交答案换取凭证
+	rr = httptest.NewRecorder()
+	ch.ServeChallenge(rr, challengeRequest("203.0.113.1:1234", solution))
+	if rr.Code != http.StatusSeeOther || rr.Header().Get("Location") != "/page?q=1" {
+		t.Fatalf("提交答案 status code = %d, Location = %q", rr.Code, rr.Header().Get("Location"))
+	}
+	var clearance *http.Cookie
+	for _, c := range rr.Result().Cookies() {
+		if c.Name == ClearanceCookieName {
+			clearance = c
+		}
+	}
+	if clearance == nil {
+		t.Fatal("提交答案后应颁发凭证")
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		cookie     *http.Cookie
+		after      time.Duration
+		want       bool
+	}{
+		{"有效凭证", "203.0.113.1:1234", clearance, 0, true},
+		{"其他IP使用凭证", "198.51.100.1:1234", clearance, 0, false},
+		{"凭证过期", "203.0.113.1:1234", clearance, time.Hour, false},
+		{"篡改凭证", "203.0.113.1:1234", &http.Cookie{Name: ClearanceCookieName, Value: "A" + clearance.Value[1:]}, 0, false},
+		{"格式错误", "203.0.113.1:1234", &http.Cookie{Name: ClearanceCookieName, Value: "garbage"}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ch.clearance.now = func() time.Time { return now.Add(tt.after) }
+			defer func() { ch.clearance.now = func() time.Time { return now } }()
+
+			if got := ch.Passed(challengeRequest(tt.remoteAddr, tt.cookie)); got != tt.want {
+				t.Errorf("Passed() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSChallengeWrongSolution(t *testing.T) {
+	ch := NewJSChallenge([]byte("secret"), 8, time.Hour)
+
+	rr := httptest.NewRecorder()
+	ch.ServeChallenge(rr, challengeRequest("203.0.113.1:1234"))
+	challenge := challengePattern.FindStringSubmatch(rr.Body.String())[1]
+
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"答案不是数字", challenge + ".notanumber"},
+		{"伪造挑战", "00.9999999999.default.00.1"},
+		{"格式错误", "garbage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			ch.ServeChallenge(rr, challengeRequest("203.0.113.1:1234", &http.Cookie{Name: challengeSolutionCookie, Value: tt.value}))
+			if rr.Code != http.StatusForbidden {
+				t.Errorf("status code = %d, want %d", rr.Code, http.StatusForbidden)
+			}
+		})
+	}
+
+	// 挑战过期后答案无效
+	solution := solveChallenge(t, rr.Body.String(), 8)
+	ch.clearance.now = func() time.Time { return time.Now().Add(challengeSolveTime) }
+	rr = httptest.NewRecorder()
+	ch.ServeChallenge(rr, challengeRequest("203.0.113.1:1234", &http.Cookie{Name: challengeSolutionCookie, Value: solution}))
+	if rr.Code != http.StatusForbidden {
+		t.Errorf("过期挑战 status code = %d, want %d", rr.Code, http.StatusForbidden)
+	}
+}
+
+func TestChallengeMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := ChallengeMiddleware(NewJSChallenge(nil, 8, 0), nil)(handler)
+
+	tests := []struct {
+		name           string
+		request        *http.Request
+		wantStatusCode int
+	}{
+		{"浏览器直接放行", browserRequest(chrome120UA), http.StatusOK},
+		{"机器人收到挑战", challengeRequest("203.0.113.1:1234"), http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, tt.request)
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
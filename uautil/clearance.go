@@ -0,0 +1,264 @@
+package uautil
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// ClearanceCookieName 是通行凭证 Cookie 的默认名称
+const ClearanceCookieName = "uautil_clearance"
+
+// clearanceVersion 是凭证格式的版本
+const clearanceVersion = "1"
+
+// ClearanceKey 是签名通行凭证的密钥
+type ClearanceKey struct {
+	ID     string // 密钥 ID，写入凭证中用于选择验证密钥，只能包含字母、数字、"-" 和 "_"
+	Secret []byte // HMAC 密钥
+}
+
+// ClearanceToken 是通行凭证的内容
+type ClearanceToken struct {
+	KeyID   string       // 签名使用的密钥 ID
+	Prefix  netip.Prefix // 颁发时客户端 IP 所在的网段
+	UAHash  string       // 颁发时 User-Agent 的哈希
+	Verdict Action       // 颁发时的检测结果
+	Expires time.Time    // 过期时间
+}
+
+// Clearance 颁发和验证 HMAC 签名的通行凭证 Cookie
+// 凭证绑定客户端 IP 所在的网段（由 iputil.GetClientIP 获取）和 User-Agent，在其他地址或客户端上重放无效
+// 支持多个密钥：第一个密钥用于签名，所有密钥都可用于验证，轮换时把新密钥放在最前面，旧密钥在凭证过期后移除
+type Clearance struct {
+	// CookieName 是 Cookie 名称，默认为 ClearanceCookieName
+	CookieName string
+	// IPv4Bits 和 IPv6Bits 是绑定的网段前缀长度，默认为 32 和 64（IPv6 隐私地址会在 /64 内变化）
+	IPv4Bits int
+	IPv6Bits int
+
+	mu   sync.RWMutex
+	keys []ClearanceKey
+	now  func() time.Time
+}
+
+// NewClearance 创建通行凭证管理器，keys 中的第一个密钥用于签名
+func NewClearance(keys ...ClearanceKey) (*Clearance, error) {
+	c := &Clearance{
+		CookieName: ClearanceCookieName,
+		IPv4Bits:   32,
+		IPv6Bits:   64,
+		now:        time.Now,
+	}
+	if err := c.SetKeys(keys...); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetKeys 替换全部密钥，可在运行时调用以轮换密钥
+func (c *Clearance) SetKeys(keys ...ClearanceKey) error {
+	if len(keys) == 0 {
+		return errors.New("uautil: clearance requires at least one key")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		switch {
+		case !validKeyID(k.ID):
+			return fmt.Errorf("uautil: invalid clearance key ID %q", k.ID)
+		case len(k.Secret) == 0:
+			return fmt.Errorf("uautil: clearance key %q has an empty secret", k.ID)
+		case seen[k.ID]:
+			return fmt.Errorf("uautil: duplicate clearance key ID %q", k.ID)
+		}
+		seen[k.ID] = true
+	}
+
+	c.mu.Lock()
+	c.keys = append([]ClearanceKey(nil), keys...)
+	c.mu.Unlock()
+	return nil
+}
+
+// Token 为请求生成有效期为 ttl 的凭证，客户端 IP 无效时返回 false
+func (c *Clearance) Token(r *http.Request, verdict Action, ttl time.Duration) (string, bool) {
+	prefix, ok := c.clientPrefix(r)
+	if !ok {
+		return "", false
+	}
+
+	key := c.signingKey()
+	payload := strings.Join([]string{
+		clearanceVersion,
+		key.ID,
+		prefix.String(),
+		uaHash(r.UserAgent()),
+		strconv.Itoa(int(verdict)),
+		strconv.FormatInt(c.now().Add(ttl).Unix(), 10),
+	}, "|")
+	mac := hmacSum(key.Secret, payload)
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString([]byte(payload)) + "." + enc.EncodeToString(mac), true
+}
+
+// Issue 为请求颁发有效期为 ttl 的凭证 Cookie，客户端 IP 无效时不颁发
+func (c *Clearance) Issue(w http.ResponseWriter, r *http.Request, verdict Action, ttl time.Duration) {
+	token, ok := c.Token(r, verdict, ttl)
+	if !ok {
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     c.CookieName,
+		Value:    token,
+		Path:     "/",
+		MaxAge:   int(ttl / time.Second),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// Verify 验证请求中的凭证 Cookie：签名有效、未过期、客户端 IP 在绑定的网段内且 User-Agent 相同
+func (c *Clearance) Verify(r *http.Request) (ClearanceToken, bool) {
+	cookie, err := r.Cookie(c.CookieName)
+	if err != nil {
+		return ClearanceToken{}, false
+	}
+	return c.VerifyToken(r, cookie.Value)
+}
+
+// VerifyToken 验证凭证是否可以被该请求使用
+func (c *Clearance) VerifyToken(r *http.Request, token string) (ClearanceToken, bool) {
+	encPayload, encMAC, ok := strings.Cut(token, ".")
+	if !ok {
+		return ClearanceToken{}, false
+	}
+	enc := base64.RawURLEncoding
+	payload, err1 := enc.DecodeString(encPayload)
+	mac, err2 := enc.DecodeString(encMAC)
+	if err1 != nil || err2 != nil {
+		return ClearanceToken{}, false
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 6 || fields[0] != clearanceVersion {
+		return ClearanceToken{}, false
+	}
+	if !c.verify(fields[1], string(payload), mac) {
+		return ClearanceToken{}, false
+	}
+
+	prefix, err := netip.ParsePrefix(fields[2])
+	if err != nil {
+		return ClearanceToken{}, false
+	}
+	verdict, err := strconv.Atoi(fields[4])
+	if err != nil {
+		return ClearanceToken{}, false
+	}
+	expires, err := strconv.ParseInt(fields[5], 10, 64)
+	if err != nil {
+		return ClearanceToken{}, false
+	}
+	t := ClearanceToken{
+		KeyID:   fields[1],
+		Prefix:  prefix,
+		UAHash:  fields[3],
+		Verdict: Action(verdict),
+		Expires: time.Unix(expires, 0),
+	}
+
+	if !c.now().Before(t.Expires) || t.UAHash != uaHash(r.UserAgent()) {
+		return ClearanceToken{}, false
+	}
+	if client, ok := c.clientPrefix(r); !ok || client != t.Prefix {
+		return ClearanceToken{}, false
+	}
+	return t, true
+}
+
+// clientPrefix 返回客户端 IP 所在的网段
+func (c *Clearance) clientPrefix(r *http.Request) (netip.Prefix, bool) {
+	addr, ok := parseClientAddr(iputil.GetClientIP(r))
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	return clientPrefix(addr, c.IPv4Bits, c.IPv6Bits)
+}
+
+// validKeyID 报告密钥 ID 是否只包含字母、数字、"-" 和 "_"
+func validKeyID(id string) bool {
+	if id == "" {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' || c == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// signingKey 返回当前用于签名的密钥
+func (c *Clearance) signingKey() ClearanceKey {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.keys[0]
+}
+
+// verify 使用密钥 kid 验证签名，kid 不存在时返回 false
+func (c *Clearance) verify(kid, message string, mac []byte) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	for _, key := range c.keys {
+		if key.ID == kid {
+			return hmac.Equal(mac, hmacSum(key.Secret, message))
+		}
+	}
+	return false
+}
+
+func hmacSum(secret []byte, message string) []byte {
+	h := hmac.New(sha256.New, secret)
+	h.Write([]byte(message))
+	return h.Sum(nil)
+}
+
+// uaHash 返回 User-Agent 的短哈希
+func uaHash(ua string) string {
+	sum := sha256.Sum256([]byte(ua))
+	return hex.EncodeToString(sum[:8])
+}
+
+// ClearanceMiddleware 创建一个中间件，持有有效凭证的请求跳过 detect 中的检测
+// detect 是任意检测中间件（如 RiskMiddleware、BrowserOnlyMiddlewareWithOptions），请求通过检测后颁发有效期为 ttl 的凭证，
+// 之后 ttl 内来自同一地址和客户端的请求不再重复检测
+func ClearanceMiddleware(c *Clearance, ttl time.Duration, detect func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		checked := detect(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c.Issue(w, r, ActionAllow, ttl)
+			next.ServeHTTP(w, r)
+		}))
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if t, ok := c.Verify(r); ok && t.Verdict == ActionAllow {
+				next.ServeHTTP(w, r)
+				return
+			}
+			checked.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,159 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func clearanceRequest(remoteAddr, ua string) *http.Request {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", ua)
+	return req
+}
+
+func TestNewClearance(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    []ClearanceKey
+		wantErr bool
+	}{
+		{"单个密钥", []ClearanceKey{{ID: "k1", Secret: []byte("s1")}}, false},
+		{"多个密钥", []ClearanceKey{{ID: "k2", Secret: []byte("s2")}, {ID: "k1", Secret: []byte("s1")}}, false},
+		{"没有密钥", nil, true},
+		{"空ID", []ClearanceKey{{Secret: []byte("s1")}}, true},
+		{"ID包含非法字符", []ClearanceKey{{ID: "k.1", Secret: []byte("s1")}}, true},
+		{"空密钥", []ClearanceKey{{ID: "k1"}}, true},
+		{"重复ID", []ClearanceKey{{ID: "k1", Secret: []byte("s1")}, {ID: "k1", Secret: []byte("s2")}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewClearance(tt.keys...)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("NewClearance() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClearanceVerify(t *testing.T) {
+	c, err := NewClearance(ClearanceKey{ID: "k1", Secret: []byte("secret")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.IPv4Bits = 24
+	now := time.Now()
+	c.now = func() time.Time { return now }
+
+	token, ok := c.Token(clearanceRequest("203.0.113.10:1234", chrome120UA), ActionAllow, time.Hour)
+	if !ok {
+		t.Fatal("Token() 应返回凭证")
+	}
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		token   string
+		after   time.Duration
+		want    bool
+	}{
+		{"同一客户端", clearanceRequest("203.0.113.10:1234", chrome120UA), token, 0, true},
+		{"同一网段", clearanceRequest("203.0.113.99:1234", chrome120UA), token, 0, true},
+		{"其他网段", clearanceRequest("198.51.100.10:1234", chrome120UA), token, 0, false},
+		{"其他User-Agent", clearanceRequest("203.0.113.10:1234", firefox121UA), token, 0, false},
+		{"过期", clearanceRequest("203.0.113.10:1234", chrome120UA), token, time.Hour, false},
+		{"篡改内容", clearanceRequest("203.0.113.10:1234", chrome120UA), "A" + token[1:], 0, false},
+		{"格式错误", clearanceRequest("203.0.113.10:1234", chrome120UA), "garbage", 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c.now = func() time.Time { return now.Add(tt.after) }
+			got, ok := c.VerifyToken(tt.request, tt.token)
+			if ok != tt.want {
+				t.Fatalf("VerifyToken() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && (got.KeyID != "k1" || got.Verdict != ActionAllow || got.Prefix.String() != "203.0.113.0/24") {
+				t.Errorf("VerifyToken() = %+v", got)
+			}
+		})
+	}
+}
+
+func TestClearanceKeyRotation(t *testing.T) {
+	oldKey := ClearanceKey{ID: "old", Secret: []byte("old-secret")}
+	newKey := ClearanceKey{ID: "new", Secret: []byte("new-secret")}
+
+	c, _ := NewClearance(oldKey)
+	req := clearanceRequest("203.0.113.10:1234", chrome120UA)
+	oldToken, _ := c.Token(req, ActionAllow, time.Hour)
+
+	// 轮换：新密钥签名，旧密钥仍可验证
+	if err := c.SetKeys(newKey, oldKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.VerifyToken(req, oldToken); !ok {
+		t.Error("轮换后旧密钥签名的凭证应仍然有效")
+	}
+	newToken, _ := c.Token(req, ActionAllow, time.Hour)
+	if got, ok := c.VerifyToken(req, newToken); !ok || got.KeyID != "new" {
+		t.Errorf("新凭证 = %+v, %v, want KeyID new", got, ok)
+	}
+
+	// 移除旧密钥
+	if err := c.SetKeys(newKey); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.VerifyToken(req, oldToken); ok {
+		t.Error("移除旧密钥后旧凭证应失效")
+	}
+
+	// 其他实例使用不同的密钥无法验证
+	other, _ := NewClearance(ClearanceKey{ID: "new", Secret: []byte("different")})
+	if _, ok := other.VerifyToken(req, newToken); ok {
+		t.Error("不同密钥不应验证通过")
+	}
+}
+
+func TestClearanceMiddleware(t *testing.T) {
+	c, _ := NewClearance(ClearanceKey{ID: "k1", Secret: []byte("secret")})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	detections := 0
+	detect := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			detections++
+			BrowserOnlyMiddlewareWithOptions(WithMinConsistency(70))(next).ServeHTTP(w, r)
+		})
+	}
+	mw := ClearanceMiddleware(c, time.Hour, detect)(handler)
+
+	// 第一次请求执行检测并颁发凭证
+	rr := httptest.NewRecorder()
+	mw.ServeHTTP(rr, browserRequest(chrome120UA))
+	cookies := rr.Result().Cookies()
+	if rr.Code != http.StatusOK || len(cookies) != 1 || cookies[0].Name != ClearanceCookieName {
+		t.Fatalf("status code = %d, cookies = %v", rr.Code, cookies)
+	}
+
+	// 带凭证的请求跳过检测
+	req := browserRequest(chrome120UA)
+	req.AddCookie(cookies[0])
+	rr = httptest.NewRecorder()
+	mw.ServeHTTP(rr, req)
+	if rr.Code != http.StatusOK || detections != 1 {
+		t.Errorf("status code = %d, detections = %d, want 200 and 1", rr.Code, detections)
+	}
+
+	// 未通过检测的请求不颁发凭证
+	rr = httptest.NewRecorder()
+	mw.ServeHTTP(rr, clearanceRequest("203.0.113.10:1234", "curl/8.0"))
+	if rr.Code != http.StatusForbidden || len(rr.Result().Cookies()) != 0 {
+		t.Errorf("status code = %d, cookies = %v, want 403 without cookies", rr.Code, rr.Result().Cookies())
+	}
+}
@@ -0,0 +1,57 @@
+package uautil
+
+import (
+	"net/netip"
+	"sort"
+	"time"
+)
+
+// parseClientAddr 解析客户端 IP，IPv4 映射的 IPv6 地址转换为 IPv4，并去掉 zone
+func parseClientAddr(ip string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap().WithZone(""), true
+}
+
+// clientPrefix 返回地址所在的网段：IPv4 为 ipv4Bits 位前缀，IPv6 为 ipv6Bits 位前缀
+// Clearance、BanManager 和 Honeypot 用它把同一客户端的不同地址归为一个键
+func clientPrefix(addr netip.Addr, ipv4Bits, ipv6Bits int) (netip.Prefix, bool) {
+	bits := ipv6Bits
+	if addr.Is4() {
+		bits = ipv4Bits
+	}
+	prefix, err := addr.Prefix(bits)
+	if err != nil {
+		return netip.Prefix{}, false
+	}
+	return prefix, true
+}
+
+// evictOldest 删除 now 时已经过期的记录，仍然不少于 max 条时按过期时间从早到晚删除，直到剩下 max 的 7/8
+// 用于限制按客户端记录状态的 map 的大小，调用方需要持有保护 m 的锁
+// 不会清空全部记录：客户端 IP 可能来自可伪造的请求头，伪造大量 IP 只能挤掉最旧的记录，不能重置其他客户端的状态
+func evictOldest[K comparable, V any](m map[K]V, max int, now time.Time, expiry func(V) time.Time) {
+	for key, v := range m {
+		if !now.Before(expiry(v)) {
+			delete(m, key)
+		}
+	}
+	if len(m) < max {
+		return
+	}
+
+	type entry struct {
+		key    K
+		expiry time.Time
+	}
+	entries := make([]entry, 0, len(m))
+	for key, v := range m {
+		entries = append(entries, entry{key, expiry(v)})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].expiry.Before(entries[j].expiry) })
+	for _, e := range entries[:len(entries)-max*7/8] {
+		delete(m, e.key)
+	}
+}
@@ -0,0 +1,59 @@
+package uautil
+
+import (
+	"testing"
+	"time"
+)
+
+func TestClientPrefix(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		want string
+	}{
+		{"IPv4", "203.0.113.9", "203.0.113.9/32"},
+		{"IPv4映射地址", "::ffff:203.0.113.9", "203.0.113.9/32"},
+		{"IPv6", "2001:db8::1", "2001:db8::/64"},
+		{"带zone的IPv6", "fe80::1%eth0", "fe80::/64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, ok := parseClientAddr(tt.ip)
+			if !ok {
+				t.Fatalf("parseClientAddr(%q) 解析失败", tt.ip)
+			}
+			if got, ok := clientPrefix(addr, 32, 64); !ok || got.String() != tt.want {
+				t.Errorf("clientPrefix() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, ok := parseClientAddr("unknown"); ok {
+		t.Error("无效IP应返回 false")
+	}
+}
+
+func TestEvictOldest(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	m := make(map[int]time.Time)
+	for i := 0; i < 16; i++ {
+		m[i] = now.Add(time.Duration(i) * time.Second)
+	}
+
+	evictOldest(m, 32, now.Add(4*time.Second), func(until time.Time) time.Time { return until })
+	if len(m) != 11 {
+		t.Errorf("清理过期记录后 len = %d, want 11", len(m))
+	}
+
+	// 仍然过多时删除最早过期的记录，保留 max 的 7/8
+	evictOldest(m, 8, now, func(until time.Time) time.Time { return until })
+	if len(m) != 7 {
+		t.Fatalf("len = %d, want 7", len(m))
+	}
+	for i := 9; i < 16; i++ {
+		if _, ok := m[i]; !ok {
+			t.Errorf("较新的记录 %d 不应被删除", i)
+		}
+	}
+}
@@ -0,0 +1,521 @@
+package uautil
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// User-Agent Client Hints 请求头
+const (
+	HeaderSecCHUA                = "Sec-CH-UA"
+	HeaderSecCHUAMobile          = "Sec-CH-UA-Mobile"
+	HeaderSecCHUAPlatform        = "Sec-CH-UA-Platform"
+	HeaderSecCHUAPlatformVersion = "Sec-CH-UA-Platform-Version"
+	HeaderSecCHUAFullVersionList = "Sec-CH-UA-Full-Version-List"
+	HeaderSecCHUAModel           = "Sec-CH-UA-Model"
+	HeaderSecCHUAArch            = "Sec-CH-UA-Arch"
+	HeaderSecCHUABitness         = "Sec-CH-UA-Bitness"
+)
+
+// HighEntropyClientHints 是浏览器默认不发送、需要通过 Accept-CH 请求的 hints
+var HighEntropyClientHints = []string{
+	HeaderSecCHUAFullVersionList,
+	HeaderSecCHUAPlatformVersion,
+	HeaderSecCHUAModel,
+	HeaderSecCHUAArch,
+	HeaderSecCHUABitness,
+}
+
+// Brand 是 Sec-CH-UA 中的一个品牌及其版本
+type Brand struct {
+	Name    string
+	Version string
+}
+
+// ClientHints 是从请求头解析出的 User-Agent Client Hints
+type ClientHints struct {
+	Brands          []Brand // Sec-CH-UA，版本只有主版本号
+	FullVersionList []Brand // Sec-CH-UA-Full-Version-List，完整版本号
+	Mobile          bool    // Sec-CH-UA-Mobile
+	Platform        string  // Sec-CH-UA-Platform，如 "Windows"、"Android"
+	PlatformVersion string  // Sec-CH-UA-Platform-Version
+	Model           string  // Sec-CH-UA-Model，仅移动设备有值
+	Arch            string  // Sec-CH-UA-Arch，如 "x86"、"arm"
+	Bitness         string  // Sec-CH-UA-Bitness，如 "64"
+
+	hasMobile bool
+}
+
+// Client Hints 品牌名与浏览器名称的对应关系
+var clientHintBrands = map[string]string{
+	"Google Chrome":    BrowserChrome,
+	"Microsoft Edge":   BrowserEdge,
+	"Opera":            BrowserOpera,
+	"Opera GX":         BrowserOpera,
+	"Brave":            BrowserBrave,
+	"Vivaldi":          BrowserVivaldi,
+	"YaBrowser":        BrowserYandex,
+	"Yandex":           BrowserYandex,
+	"Samsung Internet": BrowserSamsung,
+}
+
+// Client Hints 平台名与操作系统名称的对应关系
+var clientHintPlatforms = map[string]string{
+	"Windows":   OSWindows,
+	"macOS":     OSMacOS,
+	"iOS":       OSIOS,
+	"Android":   OSAndroid,
+	"Chrome OS": OSChromeOS,
+	"ChromeOS":  OSChromeOS,
+	"Linux":     OSLinux,
+}
+
+// ParseClientHints 从请求头中解析 User-Agent Client Hints
+// 请求中不包含任何 Sec-CH-UA 系列请求头时第二个返回值为 false
+// 语法错误的请求头按照 RFC 8941 的要求被忽略
+func ParseClientHints(h http.Header) (ClientHints, bool) {
+	var ch ClientHints
+	found := false
+
+	if v := h.Get(HeaderSecCHUA); v != "" {
+		found = true
+		ch.Brands = parseBrandList(v)
+	}
+	if v := h.Get(HeaderSecCHUAFullVersionList); v != "" {
+		found = true
+		ch.FullVersionList = parseBrandList(v)
+	}
+	if v := h.Get(HeaderSecCHUAMobile); v != "" {
+		found = true
+		if item, err := parseSFItem(v); err == nil && item.kind == sfBoolean {
+			ch.Mobile = item.value == "?1"
+			ch.hasMobile = true
+		}
+	}
+
+	for name, dst := range map[string]*string{
+		HeaderSecCHUAPlatform:        &ch.Platform,
+		HeaderSecCHUAPlatformVersion: &ch.PlatformVersion,
+		HeaderSecCHUAModel:           &ch.Model,
+		HeaderSecCHUAArch:            &ch.Arch,
+		HeaderSecCHUABitness:         &ch.Bitness,
+	} {
+		v := h.Get(name)
+		if v == "" {
+			continue
+		}
+		found = true
+		if item, err := parseSFItem(v); err == nil && item.kind == sfString {
+			*dst = item.value
+		}
+	}
+
+	return ch, found
+}
+
+// Browser 返回 hints 中声明的浏览器名称和版本
+// 优先使用 Full-Version-List 中的完整版本号；只声明了 Chromium 时返回空字符串
+func (ch ClientHints) Browser() (string, string) {
+	name, version := brandBrowser(ch.FullVersionList)
+	if name == "" {
+		name, version = brandBrowser(ch.Brands)
+	}
+	return name, version
+}
+
+// brandBrowser 从品牌列表中找出可识别的浏览器，忽略 GREASE 品牌和 Chromium
+func brandBrowser(brands []Brand) (string, string) {
+	for _, b := range brands {
+		if name, ok := clientHintBrands[b.Name]; ok {
+			return name, b.Version
+		}
+	}
+	return "", ""
+}
+
+// brandVersion 返回指定品牌的版本
+func brandVersion(brands []Brand, name string) string {
+	for _, b := range brands {
+		if b.Name == name {
+			return b.Version
+		}
+	}
+	return ""
+}
+
+// mergeClientHints 用 Client Hints 补充和修正从 UA 字符串解析出的信息
+// Chromium 会冻结 UA 中的版本号和平台信息，而 hints 中是真实值
+func (ua *UserAgent) mergeClientHints(ch ClientHints) {
+	// 应用内浏览器和国产浏览器的 hints 通常只声明 Chromium，保留 UA 的识别结果
+	if name, version := ch.Browser(); name != "" && (ua.Browser == "" || ua.Browser == BrowserChrome || ua.Browser == name) {
+		ua.Browser = name
+		if version != "" {
+			ua.BrowserVersion = version
+		}
+	}
+
+	chromium := brandVersion(ch.FullVersionList, "Chromium")
+	if chromium == "" {
+		chromium = brandVersion(ch.Brands, "Chromium")
+	}
+	if chromium != "" {
+		ua.Engine = EngineBlink
+		ua.EngineVersion = chromium
+	}
+
+	if os, ok := clientHintPlatforms[ch.Platform]; ok {
+		if os != ua.OS {
+			ua.OSVersion = ""
+		}
+		ua.OS = os
+		if ch.PlatformVersion != "" {
+			ua.OSVersion = ch.PlatformVersion
+			if os == OSWindows {
+				ua.OSVersion = windowsPlatformVersion(ch.PlatformVersion)
+			}
+		}
+	}
+
+	if ch.Model != "" {
+		ua.Model = ch.Model
+	}
+	if ch.Arch != "" {
+		ua.Arch = ch.Arch
+	}
+
+	// 机器人、电视、游戏机和可穿戴设备不根据 Mobile 提示调整
+	if ch.hasMobile && (ua.Device == DeviceUnknown || ua.Device == DeviceDesktop ||
+		ua.Device == DeviceMobile || ua.Device == DeviceTablet) {
+		switch {
+		case ch.Mobile:
+			ua.Device = DeviceMobile
+		case ua.OS == OSAndroid:
+			ua.Device = DeviceTablet
+		default:
+			ua.Device = DeviceDesktop
+		}
+	}
+}
+
+// windowsPlatformVersion 将 Sec-CH-UA-Platform-Version 转换为 Windows 产品版本
+// 参见 https://learn.microsoft.com/microsoft-edge/web-platform/how-to-detect-win11
+func windowsPlatformVersion(v string) string {
+	major, _ := strconv.Atoi(strings.SplitN(v, ".", 2)[0])
+	switch {
+	case major >= 13:
+		return "11"
+	case major > 0:
+		return "10"
+	}
+
+	switch {
+	case strings.HasPrefix(v, "0.3"):
+		return "8.1"
+	case strings.HasPrefix(v, "0.2"):
+		return "8"
+	case strings.HasPrefix(v, "0.1"):
+		return "7"
+	}
+	return ""
+}
+
+// ClientHintsMiddleware 创建一个中间件,通过 Accept-CH 请求浏览器发送指定的 Client Hints
+// critical 中的 hints 会同时写入 Critical-CH：浏览器发现首个请求缺少这些 hints 时会自动重试，
+// 因此只应把确实影响响应内容的 hints 列为 critical，它们也会被加入 Vary 头
+// 浏览器只在 HTTPS 下接受 Accept-CH
+func ClientHintsMiddleware(hints []string, critical ...string) func(http.Handler) http.Handler {
+	accept := make([]string, 0, len(hints)+len(critical))
+	seen := make(map[string]bool)
+	for _, h := range append(append([]string{}, hints...), critical...) {
+		key := strings.ToLower(h)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		accept = append(accept, h)
+	}
+
+	acceptValue := strings.Join(accept, ", ")
+	criticalValue := strings.Join(critical, ", ")
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if acceptValue != "" {
+				w.Header().Set("Accept-CH", acceptValue)
+			}
+			if criticalValue != "" {
+				w.Header().Set("Critical-CH", criticalValue)
+				AddVary(w.Header(), critical...)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseBrandList 解析 Sec-CH-UA 和 Sec-CH-UA-Full-Version-List
+// 格式为带参数 v 的字符串列表，如 `"Chromium";v="118", "Not=A?Brand";v="99"`
+// GREASE 品牌（如 "Not=A?Brand"）会被剔除
+func parseBrandList(v string) []Brand {
+	items, err := parseSFList(v)
+	if err != nil {
+		return nil
+	}
+
+	brands := make([]Brand, 0, len(items))
+	for _, item := range items {
+		if item.kind != sfString || isGreaseBrand(item.value) {
+			continue
+		}
+		brands = append(brands, Brand{Name: item.value, Version: item.params["v"]})
+	}
+	return brands
+}
+
+// isGreaseBrand 判断是否为 Chromium 随机插入的 GREASE 品牌
+func isGreaseBrand(name string) bool {
+	return strings.Contains(name, "Not") && strings.Contains(name, "Brand")
+}
+
+// 以下为 RFC 8941 Structured Field Values 的最小实现，
+// 仅支持 Client Hints 用到的 List 和 Item，内部列表会被解析后丢弃
+
+type sfKind int
+
+const (
+	sfString sfKind = iota
+	sfToken
+	sfBoolean
+	sfNumber
+	sfBytes
+	sfInnerList
+)
+
+// sfItem 是结构化字段中的一个成员
+// value 为字符串的内容、token、数字的字面值或布尔值的 "?0"/"?1"
+type sfItem struct {
+	kind   sfKind
+	value  string
+	params map[string]string
+}
+
+var errSFSyntax = errors.New("uautil: invalid structured header")
+
+type sfParser struct {
+	s   string
+	pos int
+}
+
+// parseSFList 解析结构化字段 List
+func parseSFList(s string) ([]sfItem, error) {
+	p := &sfParser{s: s}
+	p.skipSP()
+
+	var items []sfItem
+	for !p.eof() {
+		item, err := p.parseItemOrInnerList()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+
+		p.skipOWS()
+		if p.eof() {
+			return items, nil
+		}
+		if p.s[p.pos] != ',' {
+			return nil, errSFSyntax
+		}
+		p.pos++
+		p.skipOWS()
+		if p.eof() {
+			return nil, errSFSyntax // 末尾多余的逗号
+		}
+	}
+	return items, nil
+}
+
+// parseSFItem 解析结构化字段 Item
+func parseSFItem(s string) (sfItem, error) {
+	p := &sfParser{s: s}
+	p.skipSP()
+	item, err := p.parseItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+	p.skipSP()
+	if !p.eof() {
+		return sfItem{}, errSFSyntax
+	}
+	return item, nil
+}
+
+func (p *sfParser) eof() bool { return p.pos >= len(p.s) }
+
+func (p *sfParser) skipSP() {
+	for !p.eof() && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+func (p *sfParser) skipOWS() {
+	for !p.eof() && (p.s[p.pos] == ' ' || p.s[p.pos] == '\t') {
+		p.pos++
+	}
+}
+
+func (p *sfParser) parseItemOrInnerList() (sfItem, error) {
+	if !p.eof() && p.s[p.pos] == '(' {
+		return p.parseInnerList()
+	}
+	return p.parseItem()
+}
+
+func (p *sfParser) parseInnerList() (sfItem, error) {
+	p.pos++ // '('
+	for !p.eof() {
+		p.skipSP()
+		if !p.eof() && p.s[p.pos] == ')' {
+			p.pos++
+			params, err := p.parseParams()
+			return sfItem{kind: sfInnerList, params: params}, err
+		}
+		if _, err := p.parseItem(); err != nil {
+			return sfItem{}, err
+		}
+		if !p.eof() && p.s[p.pos] != ' ' && p.s[p.pos] != ')' {
+			return sfItem{}, errSFSyntax
+		}
+	}
+	return sfItem{}, errSFSyntax
+}
+
+func (p *sfParser) parseItem() (sfItem, error) {
+	kind, value, err := p.parseBareItem()
+	if err != nil {
+		return sfItem{}, err
+	}
+	params, err := p.parseParams()
+	if err != nil {
+		return sfItem{}, err
+	}
+	return sfItem{kind: kind, value: value, params: params}, nil
+}
+
+func (p *sfParser) parseParams() (map[string]string, error) {
+	var params map[string]string
+	for !p.eof() && p.s[p.pos] == ';' {
+		p.pos++
+		p.skipSP()
+		key, err := p.parseKey()
+		if err != nil {
+			return nil, err
+		}
+		value := "?1"
+		if !p.eof() && p.s[p.pos] == '=' {
+			p.pos++
+			if _, value, err = p.parseBareItem(); err != nil {
+				return nil, err
+			}
+		}
+		if params == nil {
+			params = make(map[string]string)
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+func (p *sfParser) parseKey() (string, error) {
+	start := p.pos
+	if p.eof() || !(isLCAlpha(p.s[p.pos]) || p.s[p.pos] == '*') {
+		return "", errSFSyntax
+	}
+	for !p.eof() {
+		c := p.s[p.pos]
+		if !isLCAlpha(c) && !isDigit(c) && c != '_' && c != '-' && c != '.' && c != '*' {
+			break
+		}
+		p.pos++
+	}
+	return p.s[start:p.pos], nil
+}
+
+func (p *sfParser) parseBareItem() (sfKind, string, error) {
+	if p.eof() {
+		return 0, "", errSFSyntax
+	}
+
+	c := p.s[p.pos]
+	switch {
+	case c == '"':
+		v, err := p.parseString()
+		return sfString, v, err
+	case c == '?':
+		if p.pos+1 >= len(p.s) || (p.s[p.pos+1] != '0' && p.s[p.pos+1] != '1') {
+			return 0, "", errSFSyntax
+		}
+		v := p.s[p.pos : p.pos+2]
+		p.pos += 2
+		return sfBoolean, v, nil
+	case c == '-' || isDigit(c):
+		start := p.pos
+		p.pos++
+		for !p.eof() && (isDigit(p.s[p.pos]) || p.s[p.pos] == '.') {
+			p.pos++
+		}
+		return sfNumber, p.s[start:p.pos], nil
+	case c == ':':
+		end := strings.IndexByte(p.s[p.pos+1:], ':')
+		if end < 0 {
+			return 0, "", errSFSyntax
+		}
+		v := p.s[p.pos+1 : p.pos+1+end]
+		p.pos += end + 2
+		return sfBytes, v, nil
+	case isAlpha(c) || c == '*':
+		start := p.pos
+		for !p.eof() && isTokenChar(p.s[p.pos]) {
+			p.pos++
+		}
+		return sfToken, p.s[start:p.pos], nil
+	}
+	return 0, "", errSFSyntax
+}
+
+func (p *sfParser) parseString() (string, error) {
+	p.pos++ // 起始引号
+	var b strings.Builder
+	for !p.eof() {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == '\\':
+			if p.eof() || (p.s[p.pos] != '"' && p.s[p.pos] != '\\') {
+				return "", errSFSyntax
+			}
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		case c == '"':
+			return b.String(), nil
+		case c < 0x20 || c > 0x7e:
+			return "", errSFSyntax
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", errSFSyntax
+}
+
+func isDigit(c byte) bool   { return c >= '0' && c <= '9' }
+func isLCAlpha(c byte) bool { return c >= 'a' && c <= 'z' }
+func isAlpha(c byte) bool   { return isLCAlpha(c) || (c >= 'A' && c <= 'Z') }
+
+// isTokenChar 判断是否为 sf-token 允许的字符（tchar、":" 和 "/"）
+func isTokenChar(c byte) bool {
+	if isAlpha(c) || isDigit(c) {
+		return true
+	}
+	return strings.IndexByte("!#$%&'*+-.^_`|~:/", c) >= 0
+}
@@ -0,0 +1,253 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestParseBrandList(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []Brand
+	}{
+		{
+			name:  "Chrome",
+			value: `"Chromium";v="118", "Google Chrome";v="118", "Not=A?Brand";v="99"`,
+			want:  []Brand{{"Chromium", "118"}, {"Google Chrome", "118"}},
+		},
+		{
+			name:  "Edge 完整版本",
+			value: `"Not_A Brand";v="8.0.0.0", "Chromium";v="120.0.6099.71", "Microsoft Edge";v="120.0.2210.61"`,
+			want:  []Brand{{"Chromium", "120.0.6099.71"}, {"Microsoft Edge", "120.0.2210.61"}},
+		},
+		{
+			name:  "转义字符",
+			value: `"Not\"A\\Brand";v="24", "Chromium";v="116"`,
+			want:  []Brand{{"Chromium", "116"}},
+		},
+		{
+			name:  "无空格",
+			value: `"Chromium";v="118","Brave";v="118"`,
+			want:  []Brand{{"Chromium", "118"}, {"Brave", "118"}},
+		},
+		{
+			name:  "末尾逗号",
+			value: `"Chromium";v="118",`,
+			want:  nil,
+		},
+		{
+			name:  "未闭合的字符串",
+			value: `"Chromium;v="118"`,
+			want:  nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := parseBrandList(tt.value)
+			if len(got) == 0 && len(tt.want) == 0 {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseBrandList(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSFItem(t *testing.T) {
+	tests := []struct {
+		value   string
+		kind    sfKind
+		want    string
+		wantErr bool
+	}{
+		{`?1`, sfBoolean, "?1", false},
+		{`?0`, sfBoolean, "?0", false},
+		{`"Windows"`, sfString, "Windows", false},
+		{` "15.0.0" `, sfString, "15.0.0", false},
+		{`"Pixel 5"`, sfString, "Pixel 5", false},
+		{`?2`, 0, "", true},
+		{`"abc" x`, 0, "", true},
+		{`"a` + "\x01" + `"`, 0, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseSFItem(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseSFItem(%q) err = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && (got.kind != tt.kind || got.value != tt.want) {
+			t.Errorf("parseSFItem(%q) = %v %q, want %v %q", tt.value, got.kind, got.value, tt.kind, tt.want)
+		}
+	}
+}
+
+func TestParseSFListInnerList(t *testing.T) {
+	items, err := parseSFList(`("a" "b");x, "c";v=1, tok, 1.5`)
+	if err != nil {
+		t.Fatalf("parseSFList() err = %v", err)
+	}
+	if len(items) != 4 {
+		t.Fatalf("len(items) = %d, want 4", len(items))
+	}
+	if items[0].kind != sfInnerList || items[1].params["v"] != "1" || items[2].kind != sfToken || items[3].kind != sfNumber {
+		t.Errorf("parseSFList() = %+v", items)
+	}
+}
+
+func TestParseClientHints(t *testing.T) {
+	h := http.Header{}
+	if _, ok := ParseClientHints(h); ok {
+		t.Error("没有 hints 时应该返回 false")
+	}
+
+	h.Set(HeaderSecCHUA, `"Chromium";v="118", "Google Chrome";v="118", "Not=A?Brand";v="99"`)
+	h.Set(HeaderSecCHUAFullVersionList, `"Chromium";v="118.0.5993.88", "Google Chrome";v="118.0.5993.88", "Not=A?Brand";v="99.0.0.0"`)
+	h.Set(HeaderSecCHUAMobile, "?1")
+	h.Set(HeaderSecCHUAPlatform, `"Android"`)
+	h.Set(HeaderSecCHUAPlatformVersion, `"13.0.0"`)
+	h.Set(HeaderSecCHUAModel, `"Pixel 7"`)
+	h.Set(HeaderSecCHUAArch, `""`)
+
+	ch, ok := ParseClientHints(h)
+	if !ok {
+		t.Fatal("ParseClientHints() ok = false")
+	}
+	if !ch.Mobile || ch.Platform != "Android" || ch.PlatformVersion != "13.0.0" || ch.Model != "Pixel 7" {
+		t.Errorf("ParseClientHints() = %+v", ch)
+	}
+
+	name, version := ch.Browser()
+	if name != BrowserChrome || version != "118.0.5993.88" {
+		t.Errorf("Browser() = %q %q, want %q %q", name, version, BrowserChrome, "118.0.5993.88")
+	}
+}
+
+func TestParseRequestWithClientHints(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		hints     map[string]string
+		browser   string
+		version   string
+		os        string
+		osVersion string
+		device    DeviceType
+		model     string
+	}{
+		{
+			name:      "冻结的 Windows UA",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			hints: map[string]string{
+				HeaderSecCHUA:                `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+				HeaderSecCHUAFullVersionList: `"Not_A Brand";v="8.0.0.0", "Chromium";v="120.0.6099.130", "Google Chrome";v="120.0.6099.130"`,
+				HeaderSecCHUAMobile:          "?0",
+				HeaderSecCHUAPlatform:        `"Windows"`,
+				HeaderSecCHUAPlatformVersion: `"15.0.0"`,
+			},
+			browser:   BrowserChrome,
+			version:   "120.0.6099.130",
+			os:        OSWindows,
+			osVersion: "11",
+			device:    DeviceDesktop,
+		},
+		{
+			name:      "冻结的 Android UA",
+			userAgent: "Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			hints: map[string]string{
+				HeaderSecCHUA:                `"Not_A Brand";v="8", "Chromium";v="120", "Google Chrome";v="120"`,
+				HeaderSecCHUAMobile:          "?1",
+				HeaderSecCHUAPlatform:        `"Android"`,
+				HeaderSecCHUAPlatformVersion: `"14.0.0"`,
+				HeaderSecCHUAModel:           `"Pixel 8"`,
+			},
+			browser:   BrowserChrome,
+			version:   "120",
+			os:        OSAndroid,
+			osVersion: "14.0.0",
+			device:    DeviceMobile,
+			model:     "Pixel 8",
+		},
+		{
+			name:      "Brave 通过 hints 识别",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			hints: map[string]string{
+				HeaderSecCHUA:         `"Not_A Brand";v="8", "Chromium";v="120", "Brave";v="120"`,
+				HeaderSecCHUAMobile:   "?0",
+				HeaderSecCHUAPlatform: `"macOS"`,
+			},
+			browser:   BrowserBrave,
+			version:   "120",
+			os:        OSMacOS,
+			osVersion: "10.15.7",
+			device:    DeviceDesktop,
+		},
+		{
+			name:      "应用内浏览器保留 UA 识别结果",
+			userAgent: "Mozilla/5.0 (Linux; Android 13; 22081212C) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/107.0.5304.141 Mobile Safari/537.36 XWEB/5023 MMWEBSDK/20230202 MicroMessenger/8.0.33.2320(0x28002151) WeChat/arm64 Weixin NetType/WIFI",
+			hints: map[string]string{
+				HeaderSecCHUA:         `"Chromium";v="107", "Not=A?Brand";v="24"`,
+				HeaderSecCHUAMobile:   "?1",
+				HeaderSecCHUAPlatform: `"Android"`,
+			},
+			browser:   BrowserWeChat,
+			version:   "8.0.33.2320",
+			os:        OSAndroid,
+			osVersion: "13",
+			device:    DeviceMobile,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			for k, v := range tt.hints {
+				req.Header.Set(k, v)
+			}
+
+			got := ParseRequest(req)
+			if got.Browser != tt.browser || got.BrowserVersion != tt.version {
+				t.Errorf("Browser = %q %q, want %q %q", got.Browser, got.BrowserVersion, tt.browser, tt.version)
+			}
+			if got.OS != tt.os || got.OSVersion != tt.osVersion {
+				t.Errorf("OS = %q %q, want %q %q", got.OS, got.OSVersion, tt.os, tt.osVersion)
+			}
+			if got.Device != tt.device {
+				t.Errorf("Device = %q, want %q", got.Device, tt.device)
+			}
+			if got.Model != tt.model {
+				t.Errorf("Model = %q, want %q", got.Model, tt.model)
+			}
+		})
+	}
+}
+
+func TestClientHintsMiddleware(t *testing.T) {
+	middleware := ClientHintsMiddleware(
+		[]string{HeaderSecCHUAFullVersionList, HeaderSecCHUAPlatformVersion},
+		HeaderSecCHUAModel,
+	)
+	handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+
+	wantAccept := "Sec-CH-UA-Full-Version-List, Sec-CH-UA-Platform-Version, Sec-CH-UA-Model"
+	if got := rec.Header().Get("Accept-CH"); got != wantAccept {
+		t.Errorf("Accept-CH = %q, want %q", got, wantAccept)
+	}
+	if got := rec.Header().Get("Critical-CH"); got != HeaderSecCHUAModel {
+		t.Errorf("Critical-CH = %q, want %q", got, HeaderSecCHUAModel)
+	}
+	if got := rec.Header().Get("Vary"); got != HeaderSecCHUAModel {
+		t.Errorf("Vary = %q, want %q", got, HeaderSecCHUAModel)
+	}
+}
@@ -0,0 +1,134 @@
+package uautil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Mismatch 是 UA 声称的浏览器与请求头之间的一处不一致
+type Mismatch struct {
+	Header string // 相关的请求头
+	Reason string // 不一致的原因
+	Weight int    // 扣除的分值
+}
+
+// ConsistencyResult 是一致性检查的结果
+type ConsistencyResult struct {
+	Browser    string     // UA 声称的浏览器，无法识别时为空字符串
+	Score      int        // 0-100，100 表示完全一致
+	Mismatches []Mismatch // 发现的不一致
+}
+
+func (c *ConsistencyResult) add(header, reason string, weight int) {
+	c.Mismatches = append(c.Mismatches, Mismatch{Header: header, Reason: reason, Weight: weight})
+	c.Score -= weight
+	if c.Score < 0 {
+		c.Score = 0
+	}
+}
+
+// CheckConsistency 交叉验证 UA 声称的浏览器与请求头是否一致
+// 例如 Chrome 在 HTTPS 页面请求中必须发送 Sec-CH-UA 和 Sec-Fetch-*，Firefox 和 Safari 不会发送 Sec-CH-UA，
+// 只有 Chromium 的 Accept 中包含 image/apng 和 application/signed-exchange
+// 不是浏览器的请求（IsBrowser 返回 false）得分为 0
+func CheckConsistency(r *http.Request) ConsistencyResult {
+	result := ConsistencyResult{Score: 100}
+	if !IsBrowser(r) {
+		result.add("User-Agent", "not a browser", 100)
+		return result
+	}
+
+	ua := Parse(r.UserAgent())
+	result.Browser = ua.Browser
+	if !claimsBrowser(ua) {
+		// 如 `curl -A "Mozilla/5.0"`
+		result.add("User-Agent", "unrecognized browser", 30)
+	}
+
+	// 缺少基本请求头的检查与 DetectAutomation 共用
+	h := r.Header
+	accept := h.Get("Accept")
+	navigation := isDocumentRequest(r)
+	headers := checkBrowserHeaders(r, ua)
+	switch {
+	case headers.missingAccept:
+		result.add("Accept", "missing", 25)
+	case headers.genericAccept:
+		result.add("Accept", "navigation request accepts only */*", 20)
+	}
+	if headers.missingAcceptLanguage {
+		result.add("Accept-Language", "missing", 25)
+	}
+	if headers.missingGzip {
+		result.add("Accept-Encoding", "missing gzip", 20)
+	}
+	if headers.missingSecFetch {
+		result.add("Sec-Fetch-Mode", ua.Browser+" sends Sec-Fetch-* on secure requests", 25)
+	}
+	checkSecFetch(&result, h)
+
+	chromium := chromiumBrowsers[ua.Browser] && ua.Engine == EngineBlink
+	ch, hasHints := ParseClientHints(h)
+	switch {
+	case chromium:
+		if headers.missingClientHints {
+			result.add(HeaderSecCHUA, ua.Browser+" sends Sec-CH-UA on secure requests", 30)
+		}
+		if !hasHints {
+			break
+		}
+		checkClientHints(&result, ua, ch)
+		if navigation && strings.HasPrefix(accept, "text/html") && !strings.Contains(accept, "image/apng") {
+			result.add("Accept", "navigation Accept differs from Chromium's", 10)
+		}
+	case ua.Engine == EngineGecko || ua.Browser == BrowserSafari:
+		if hasHints {
+			result.add(HeaderSecCHUA, ua.Browser+" does not send client hints", 35)
+		}
+		if strings.Contains(accept, "image/apng") || strings.Contains(accept, "application/signed-exchange") {
+			result.add("Accept", "Chromium Accept header sent by "+ua.Browser, 25)
+		}
+	}
+
+	return result
+}
+
+// checkClientHints 检查 Client Hints 中的品牌、版本、平台和移动设备标记是否与 UA 一致
+func checkClientHints(result *ConsistencyResult, ua UserAgent, ch ClientHints) {
+	if name, _ := ch.Browser(); name != "" && name != ua.Browser {
+		result.add(HeaderSecCHUA, "brand "+name+" does not match "+ua.Browser, 25)
+	}
+	if v := brandVersion(ch.Brands, "Chromium"); v != "" && leadingInt(v) != leadingInt(ua.EngineVersion) {
+		result.add(HeaderSecCHUA, "Chromium version "+v+" does not match User-Agent", 25)
+	}
+
+	if os, ok := clientHintPlatforms[ch.Platform]; ok && ua.OS != "" && os != ua.OS {
+		result.add(HeaderSecCHUAPlatform, "platform "+ch.Platform+" does not match "+ua.OS, 20)
+	}
+	if ch.hasMobile {
+		switch {
+		case ch.Mobile && ua.Device == DeviceDesktop:
+			result.add(HeaderSecCHUAMobile, "mobile hint from a desktop User-Agent", 15)
+		case !ch.Mobile && ua.Device == DeviceMobile:
+			result.add(HeaderSecCHUAMobile, "non-mobile hint from a mobile User-Agent", 15)
+		}
+	}
+}
+
+// checkSecFetch 检查 Sec-Fetch-* 请求头之间是否一致
+func checkSecFetch(result *ConsistencyResult, h http.Header) {
+	mode, dest := h.Get("Sec-Fetch-Mode"), h.Get("Sec-Fetch-Dest")
+	switch {
+	case mode == "" && (dest != "" || h.Get("Sec-Fetch-Site") != ""):
+		result.add("Sec-Fetch-Mode", "missing while other Sec-Fetch-* headers are present", 15)
+	case mode == "navigate" && dest != "" && !containsAny("document", "iframe", "frame", "embed", "object")(dest):
+		result.add("Sec-Fetch-Dest", "navigation to "+dest, 15)
+	case mode != "" && mode != "navigate" && h.Get("Sec-Fetch-User") != "":
+		result.add("Sec-Fetch-User", "sent on a non-navigation request", 15)
+	}
+}
+
+// IsConsistentBrowser 报告请求是否是浏览器，并且一致性得分不低于 minScore
+func IsConsistentBrowser(r *http.Request, minScore int) bool {
+	return CheckConsistency(r).Score >= minScore
+}
@@ -0,0 +1,134 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	tests := []struct {
+		name       string
+		request    func() *http.Request
+		wantHeader []string // 期望出现不一致的请求头
+		wantScore  int      // -1 表示不检查分值
+	}{
+		{"真实Chrome", func() *http.Request { return browserRequest(chrome120UA) }, nil, 100},
+		{"真实Firefox", func() *http.Request { return browserRequest(firefox121UA) }, nil, 100},
+		{"真实Safari", func() *http.Request { return browserRequest(safari17UA) }, nil, 100},
+		{"curl -A Mozilla/5.0", func() *http.Request {
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Header.Set("User-Agent", "Mozilla/5.0")
+			req.Header.Set("Accept", "*/*")
+			return req
+		}, []string{"User-Agent", "Accept", "Accept-Language", "Accept-Encoding"}, 5},
+		{"Firefox UA带Client Hints", func() *http.Request {
+			req := browserRequest(firefox121UA)
+			req.Header.Set("Sec-CH-UA", `"Chromium";v="120", "Google Chrome";v="120"`)
+			req.Header.Set("Accept", "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7")
+			return req
+		}, []string{HeaderSecCHUA, "Accept"}, 40},
+		{"Chrome缺少Client Hints和Sec-Fetch", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			for _, h := range []string{"Sec-CH-UA", "Sec-CH-UA-Mobile", "Sec-CH-UA-Platform", "Sec-Fetch-Site", "Sec-Fetch-Mode", "Sec-Fetch-User", "Sec-Fetch-Dest"} {
+				req.Header.Del(h)
+			}
+			return req
+		}, []string{"Sec-Fetch-Mode", HeaderSecCHUA}, 45},
+		{"Chrome版本与品牌不符", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.Header.Set("Sec-CH-UA", `"Chromium";v="110", "Microsoft Edge";v="110"`)
+			return req
+		}, []string{HeaderSecCHUA, HeaderSecCHUA}, 50},
+		{"平台和移动标记不符", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.Header.Set("Sec-CH-UA-Platform", `"Android"`)
+			req.Header.Set("Sec-CH-UA-Mobile", "?1")
+			return req
+		}, []string{HeaderSecCHUAPlatform, HeaderSecCHUAMobile}, 65},
+		{"Sec-Fetch不一致", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.Header.Set("Sec-Fetch-Dest", "image")
+			return req
+		}, []string{"Sec-Fetch-Dest"}, 85},
+		{"不是浏览器", func() *http.Request {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", "curl/8.0")
+			return req
+		}, []string{"User-Agent"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CheckConsistency(tt.request())
+			if len(got.Mismatches) != len(tt.wantHeader) {
+				t.Fatalf("Mismatches = %+v, want headers %v", got.Mismatches, tt.wantHeader)
+			}
+			for i, h := range tt.wantHeader {
+				if got.Mismatches[i].Header != h {
+					t.Errorf("Mismatches[%d].Header = %q, want %q", i, got.Mismatches[i].Header, h)
+				}
+			}
+			if got.Score != tt.wantScore {
+				t.Errorf("Score = %d, want %d", got.Score, tt.wantScore)
+			}
+		})
+	}
+}
+
+func TestBrowserOnlyMinConsistency(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	spoofed := httptest.NewRequest("GET", "https://example.com/", nil)
+	spoofed.Header.Set("User-Agent", "Mozilla/5.0")
+
+	tests := []struct {
+		name           string
+		middleware     func(http.Handler) http.Handler
+		request        *http.Request
+		wantStatusCode int
+	}{
+		{"BrowserOnly放行伪装请求", BrowserOnlyMiddleware(), spoofed, http.StatusOK},
+		{"要求一致性时拦截伪装请求", BrowserOnlyMiddlewareWithOptions(WithMinConsistency(70)), spoofed, http.StatusForbidden},
+		{"要求一致性时放行真实浏览器", BrowserOnlyMiddlewareWithOptions(WithMinConsistency(70)), browserRequest(chrome120UA), http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			tt.middleware(handler).ServeHTTP(rr, tt.request)
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestCheckConsistencyMatchesAutomation(t *testing.T) {
+	// 缺少基本请求头的判断与 DetectAutomation 一致
+	signals := map[string]AutomationSignal{
+		"Accept":          SignalMissingAccept,
+		"Accept-Language": SignalMissingAcceptLanguage,
+		"Accept-Encoding": SignalMissingAcceptEncoding,
+		"Sec-Fetch-Mode":  SignalMissingSecFetch,
+		HeaderSecCHUA:     SignalMissingClientHints,
+	}
+
+	for header, signal := range signals {
+		t.Run(header, func(t *testing.T) {
+			req := browserRequest(chrome120UA)
+			req.Header.Del(header)
+			req.Header.Del("Sec-Fetch-Site")
+
+			found := false
+			for _, m := range CheckConsistency(req).Mismatches {
+				found = found || m.Header == header
+			}
+			if has := DetectAutomation(req).Has(signal); !found || !has {
+				t.Errorf("缺少 %s 时一致性检查 = %v, 自动化检测 = %v，两者都应发现", header, found, has)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package uautil
+
+import (
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// Decision 记录中间件对一个请求的处理方式及分类详情
+type Decision struct {
+	Middleware string        // 产生决定的中间件，如 "BlockBotMiddleware"
+	Action     Action        // 中间件对请求的处理方式
+	ReportOnly bool          // 为 true 时请求实际被放行，Action 是启用后会执行的处理方式
+	Reason     string        // 处理的原因，如 "bot"、"not a browser"
+	Rule       string        // 命中的规则，如机器人特征或规则集中的规则名
+	Latency    time.Duration // 检测耗时，不包括后续处理器
+
+	ClientIP  string    // 由 iputil.GetClientIP 获取
+	Method    string    // 请求方法
+	Path      string    // 请求路径
+	UserAgent UserAgent // 解析后的 User-Agent
+
+	Bot         BotSignature       // 命中的机器人特征，没有命中时为零值
+	Consistency *ConsistencyResult // 一致性检查结果，没有检查时为 nil
+	Risk        *RiskResult        // 风险评分，只有 RiskMiddleware 的决定包含
+}
+
+// DecisionHook 接收中间件对每个请求作出的决定
+type DecisionHook interface {
+	OnDecision(r *http.Request, d Decision)
+}
+
+// DecisionHookFunc 把函数适配为 DecisionHook
+type DecisionHookFunc func(r *http.Request, d Decision)
+
+// OnDecision 调用 f(r, d)
+func (f DecisionHookFunc) OnDecision(r *http.Request, d Decision) {
+	f(r, d)
+}
+
+// 全局的决定钩子，对所有中间件生效
+var decisionHooks []*DecisionHook
+
+// AddDecisionHook 添加对所有中间件生效的决定钩子，应在处理请求之前调用
+// 钩子在请求处理的 goroutine 中同步调用，返回的函数可用于移除该钩子
+func AddDecisionHook(h DecisionHook) func() {
+	entry := &h
+	decisionHooks = append(decisionHooks, entry)
+
+	return func() {
+		for i, e := range decisionHooks {
+			if e == entry {
+				decisionHooks = append(decisionHooks[:i], decisionHooks[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// WithDecisionHook 添加只对该中间件生效的决定钩子
+func WithDecisionHook(h DecisionHook) Option {
+	return func(o *middlewareOptions) {
+		o.hooks = append(o.hooks, h)
+	}
+}
+
+// hasDecisionHooks 报告是否有需要通知的钩子
+func hasDecisionHooks(local []DecisionHook) bool {
+	return len(local) > 0 || len(decisionHooks) > 0 || metricsRecorder != nil
+}
+
+// emitDecision 依次通知中间件的钩子、全局钩子和指标记录器
+func emitDecision(r *http.Request, d Decision, local []DecisionHook) {
+	for _, h := range local {
+		h.OnDecision(r, d)
+	}
+	for _, h := range decisionHooks {
+		(*h).OnDecision(r, d)
+	}
+	if metricsRecorder != nil {
+		metricsRecorder.OnDecision(r, d)
+	}
+}
+
+// newDecision 返回包含请求基本信息的决定
+func newDecision(r *http.Request, middleware string, action Action) Decision {
+	return Decision{
+		Middleware: middleware,
+		Action:     action,
+		ClientIP:   iputil.GetClientIP(r),
+		Method:     r.Method,
+		Path:       r.URL.Path,
+		UserAgent:  Parse(r.UserAgent()),
+	}
+}
+
+// WithReportOnly 启用仅报告模式：本应拒绝的请求被放行，并调用 report 报告分类详情
+// report 为 nil 时使用 log.Printf 记录，可用于在生产环境上线规则前观察会拦截哪些请求
+func WithReportOnly(report func(r *http.Request, d Decision)) Option {
+	return func(o *middlewareOptions) {
+		o.reportOnly = true
+		o.report = report
+	}
+}
+
+// WithSampleRate 设置仅报告模式下报告的比例，取值 0-1，默认为 1（报告全部）
+func WithSampleRate(rate float64) Option {
+	return func(o *middlewareOptions) {
+		o.sampleRate = rate
+	}
+}
+
+// sampled 报告本次是否需要报告
+func (o *middlewareOptions) sampled() bool {
+	return o.sampleRate >= 1 || o.sampleRate > 0 && rand.Float64() < o.sampleRate
+}
+
+// logDecision 是仅报告模式的默认报告方式
+func logDecision(r *http.Request, d Decision) {
+	log.Printf("uautil: [report-only] %s would %s %s %s %s from %s (%s): %q",
+		d.Middleware, d.Action, d.Method, d.Path, d.Reason, d.ClientIP, d.Bot.Name, r.UserAgent())
+}
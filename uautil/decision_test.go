@@ -0,0 +1,210 @@
+package uautil
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+func TestReportOnly(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	var reports []Decision
+	report := func(r *http.Request, d Decision) { reports = append(reports, d) }
+
+	spoofed := httptest.NewRequest("GET", "https://example.com/checkout", nil)
+	spoofed.Header.Set("User-Agent", "Mozilla/5.0")
+
+	headless := httptest.NewRequest("GET", "/", nil)
+	headless.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36")
+
+	gptbot := httptest.NewRequest("POST", "/api/items", nil)
+	gptbot.Header.Set("User-Agent", "Mozilla/5.0 AppleWebKit/537.36 (KHTML, like Gecko; compatible; GPTBot/1.0; +https://openai.com/gptbot)")
+	gptbot.RemoteAddr = "203.0.113.9:1234"
+
+	tests := []struct {
+		name        string
+		middleware  func(http.Handler) http.Handler
+		request     *http.Request
+		wantReports int
+		want        Decision
+	}{
+		{"报告机器人", BlockBotMiddlewareWithOptions(true, WithReportOnly(report)), gptbot, 1, Decision{
+			Middleware: "BlockBotMiddleware", Action: ActionBlock, ReportOnly: true, Reason: "bot",
+			ClientIP: "203.0.113.9", Method: "POST", Path: "/api/items",
+			Bot: BotSignature{Pattern: "gptbot", Name: "GPTBot", Category: CategoryAICrawler},
+		}},
+		{"浏览器不报告", BlockBotMiddlewareWithOptions(true, WithReportOnly(report)), browserRequest(chrome120UA), 0, Decision{}},
+		{"报告挑战", BlockBotMiddlewareWithOptions(true, WithReportOnly(report), WithChallenge(cookieChallenger{})), gptbot, 1, Decision{
+			Middleware: "BlockBotMiddleware", Action: ActionChallenge, ReportOnly: true, Reason: "bot",
+			ClientIP: "203.0.113.9", Method: "POST", Path: "/api/items",
+			Bot: BotSignature{Pattern: "gptbot", Name: "GPTBot", Category: CategoryAICrawler},
+		}},
+		{"报告不一致的浏览器", BrowserOnlyMiddlewareWithOptions(WithMinConsistency(70), WithReportOnly(report)), spoofed, 1, Decision{
+			Middleware: "BrowserOnlyMiddleware", Action: ActionBlock, ReportOnly: true, Reason: "inconsistent browser",
+			ClientIP: "192.0.2.1", Method: "GET", Path: "/checkout",
+		}},
+		{"报告AI爬虫", AICrawlerMiddlewareWithOptions(AICrawlerPolicy{Action: ActionBlock}, WithReportOnly(report)), gptbot, 1, Decision{
+			Middleware: "AICrawlerMiddleware", Action: ActionBlock, ReportOnly: true, Reason: "ai crawler",
+			ClientIP: "203.0.113.9", Method: "POST", Path: "/api/items",
+			Bot: BotSignature{Pattern: "gptbot", Name: "GPTBot", Category: CategoryAICrawler},
+		}},
+		{"报告机器人类别", BotPolicyMiddlewareWithOptions(BlockCategories(CategoryAICrawler), WithReportOnly(report)), gptbot, 1, Decision{
+			Middleware: "BotPolicyMiddleware", Action: ActionBlock, ReportOnly: true, Reason: "bot category ai_crawler",
+			ClientIP: "203.0.113.9", Method: "POST", Path: "/api/items",
+			Bot: BotSignature{Pattern: "gptbot", Name: "GPTBot", Category: CategoryAICrawler},
+		}},
+		{"报告设备", DeviceOnlyMiddlewareWithOptions([]DeviceType{DeviceMobile}, WithReportOnly(report)), browserRequest(chrome120UA), 1, Decision{
+			Middleware: "DeviceOnlyMiddleware", Action: ActionBlock, ReportOnly: true, Reason: "unsupported device desktop",
+			ClientIP: "192.0.2.1", Method: "GET", Path: "/",
+		}},
+		{"报告浏览器版本", MinVersionMiddleware(VersionPolicy{}, WithReportOnly(report)), gptbot, 1, Decision{
+			Middleware: "MinVersionMiddleware", Action: ActionBlock, ReportOnly: true, Reason: "unsupported browser",
+			ClientIP: "203.0.113.9", Method: "POST", Path: "/api/items",
+			Bot: BotSignature{Pattern: "gptbot", Name: "GPTBot", Category: CategoryAICrawler},
+		}},
+		{"报告自动化", AutomationMiddlewareWithOptions(1, WithReportOnly(report)), headless, 1, Decision{
+			Middleware: "AutomationMiddleware", Action: ActionBlock, ReportOnly: true,
+			Reason:   "automation score " + strconv.Itoa(DetectAutomation(headless).Score),
+			ClientIP: "192.0.2.1", Method: "GET", Path: "/",
+			Bot: BotSignature{Pattern: "headless", Name: "Headless Browser", Category: CategoryHeadlessBrowser},
+		}},
+		{"采样率为0", BlockBotMiddlewareWithOptions(true, WithReportOnly(report), WithSampleRate(0)), gptbot, 0, Decision{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reports = nil
+			rr := httptest.NewRecorder()
+			tt.middleware(handler).ServeHTTP(rr, tt.request)
+
+			if rr.Code != http.StatusOK {
+				t.Errorf("status code = %d, want %d", rr.Code, http.StatusOK)
+			}
+			if len(reports) != tt.wantReports {
+				t.Fatalf("reports = %d, want %d", len(reports), tt.wantReports)
+			}
+			if tt.wantReports == 0 {
+				return
+			}
+
+			got := reports[0]
+			if got.Middleware != tt.want.Middleware || got.Action != tt.want.Action || got.ReportOnly != tt.want.ReportOnly ||
+				got.Reason != tt.want.Reason || got.ClientIP != tt.want.ClientIP || got.Method != tt.want.Method ||
+				got.Path != tt.want.Path || got.Bot != tt.want.Bot {
+				t.Errorf("Decision = %+v, want %+v", got, tt.want)
+			}
+			if got.UserAgent.Raw != tt.request.UserAgent() {
+				t.Errorf("UserAgent.Raw = %q, want %q", got.UserAgent.Raw, tt.request.UserAgent())
+			}
+			if tt.want.Reason == "inconsistent browser" && (got.Consistency == nil || got.Consistency.Score >= 70) {
+				t.Errorf("Consistency = %+v, want score < 70", got.Consistency)
+			}
+		})
+	}
+}
+
+func TestReportOnlySampleRate(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	reports := 0
+	mw := BlockBotMiddlewareWithOptions(true, WithSampleRate(0.5), WithReportOnly(func(r *http.Request, d Decision) { reports++ }))(handler)
+
+	for i := 0; i < 1000; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", "curl/8.0")
+		mw.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if reports < 350 || reports > 650 {
+		t.Errorf("reports = %d, want about 500", reports)
+	}
+}
+
+func TestReportOnlyDefaultLog(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	defer log.SetOutput(os.Stderr)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	BlockBotMiddlewareWithOptions(true, WithReportOnly(nil))(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	if out := buf.String(); !strings.Contains(out, "[report-only] BlockBotMiddleware would block GET /admin") {
+		t.Errorf("log = %q", out)
+	}
+}
+
+func TestDecisionHooks(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	var global, local []Decision
+	remove := AddDecisionHook(DecisionHookFunc(func(r *http.Request, d Decision) { global = append(global, d) }))
+	defer remove()
+	hook := WithDecisionHook(DecisionHookFunc(func(r *http.Request, d Decision) { local = append(local, d) }))
+
+	curl := httptest.NewRequest("GET", "/admin", nil)
+	curl.Header.Set("User-Agent", "curl/8.0")
+	curl.RemoteAddr = "203.0.113.9:1234"
+
+	blocked := httptest.NewRequest("GET", "/", nil)
+	blocked.Header.Set("User-Agent", "curl/8.0")
+	blocked.AddCookie(&http.Cookie{Name: "passed", Value: "1"})
+
+	tests := []struct {
+		name       string
+		middleware func(http.Handler) http.Handler
+		request    *http.Request
+		wantLocal  bool
+		wantAction Action
+		wantRule   string
+	}{
+		{"拒绝机器人", BlockBotMiddlewareWithOptions(true, hook), curl, true, ActionBlock, "curl"},
+		{"放行浏览器", BlockBotMiddlewareWithOptions(true, hook), browserRequest(chrome120UA), true, ActionAllow, ""},
+		{"通过挑战", BlockBotMiddlewareWithOptions(true, hook, WithChallenge(cookieChallenger{})), blocked, true, ActionAllow, "curl"},
+		{"只有全局钩子", BrowserOnlyMiddleware(), curl, false, ActionBlock, "curl"},
+		{"风险评分", RiskMiddleware(NewRiskScorer(DefaultRiskWeights, 0, 0), DefaultRiskPolicy), curl, false, ActionThrottle, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			global, local = nil, nil
+			tt.middleware(handler).ServeHTTP(httptest.NewRecorder(), tt.request)
+
+			if len(global) != 1 {
+				t.Fatalf("global hook calls = %d, want 1", len(global))
+			}
+			if tt.wantLocal && len(local) != 1 {
+				t.Fatalf("local hook calls = %d, want 1", len(local))
+			}
+			got := global[0]
+			if got.Action != tt.wantAction {
+				t.Errorf("Action = %v, want %v", got.Action, tt.wantAction)
+			}
+			if got.Rule != tt.wantRule {
+				t.Errorf("Rule = %q, want %q", got.Rule, tt.wantRule)
+			}
+			if got.ClientIP != iputil.GetClientIP(tt.request) {
+				t.Errorf("ClientIP = %q", got.ClientIP)
+			}
+			if got.Middleware == "RiskMiddleware" && (got.Risk == nil || got.Reason != "risk score "+strconv.Itoa(got.Risk.Score)) {
+				t.Errorf("Risk = %+v, Reason = %q", got.Risk, got.Reason)
+			}
+		})
+	}
+
+	remove()
+	global = nil
+	BlockBotMiddleware(true)(handler).ServeHTTP(httptest.NewRecorder(), curl)
+	if len(global) != 0 {
+		t.Errorf("移除后的钩子仍被调用了 %d 次", len(global))
+	}
+}
@@ -0,0 +1,162 @@
+package uautil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DeviceType 设备类型
+type DeviceType string
+
+// 设备类型
+const (
+	DeviceUnknown  DeviceType = ""
+	DeviceDesktop  DeviceType = "desktop"
+	DeviceMobile   DeviceType = "mobile"
+	DeviceTablet   DeviceType = "tablet"
+	DeviceTV       DeviceType = "tv"
+	DeviceConsole  DeviceType = "console"
+	DeviceWearable DeviceType = "wearable"
+	DeviceBot      DeviceType = "bot"
+)
+
+// 设备类型特征，按 TV、游戏机、可穿戴设备、平板、手机的顺序匹配
+var (
+	tvDevicePatterns       = []string{"smart-tv", "smarttv", "googletv", "appletv", "crkey", "hbbtv", "bravia", "roku", "netcast", "; aft"}
+	consoleDevicePatterns  = []string{"playstation", "xbox", "nintendo"}
+	wearableDevicePatterns = []string{"watch os", "watchos", "wear os", "smartwatch", "; watch", "glass 1"}
+	tabletDevicePatterns   = []string{"ipad", "tablet", "kindle", "silk/", "playbook"}
+	mobileDevicePatterns   = []string{"mobi", "iphone", "ipod", "windows phone", "blackberry", "opera mini"}
+)
+
+// DetectDevice 检测请求来自哪种设备
+func DetectDevice(r *http.Request) DeviceType {
+	return ParseRequest(r).Device
+}
+
+// DetectDeviceUserAgent 直接检测 User-Agent 字符串对应的设备类型
+func DetectDeviceUserAgent(userAgent string) DeviceType {
+	return Parse(userAgent).Device
+}
+
+// parseDevice 根据小写 UA 和已识别的操作系统判断设备类型
+func parseDevice(ua, os string, bot bool) DeviceType {
+	switch {
+	case bot:
+		return DeviceBot
+	case containsAny(tvDevicePatterns...)(ua):
+		return DeviceTV
+	case containsAny(consoleDevicePatterns...)(ua):
+		return DeviceConsole
+	case containsAny(wearableDevicePatterns...)(ua):
+		return DeviceWearable
+	case containsAny(tabletDevicePatterns...)(ua):
+		return DeviceTablet
+	case containsAny(mobileDevicePatterns...)(ua):
+		return DeviceMobile
+	case os == OSAndroid || os == OSHarmonyOS:
+		// Android 平板的 UA 中不包含 "Mobile"
+		return DeviceTablet
+	case os == OSWindows || os == OSMacOS || os == OSLinux || os == OSChromeOS:
+		return DeviceDesktop
+	}
+	return DeviceUnknown
+}
+
+// DeviceOnlyMiddleware 创建一个中间件,仅允许指定类型的设备访问
+// customMessage 是可选的自定义拒绝消息
+func DeviceOnlyMiddleware(devices []DeviceType, customMessage ...string) func(http.Handler) http.Handler {
+	var opts []Option
+	if len(customMessage) > 0 {
+		opts = append(opts, WithMessage(customMessage[0]))
+	}
+	return DeviceOnlyMiddlewareWithOptions(devices, opts...)
+}
+
+// DeviceOnlyMiddlewareWithOptions 创建一个仅允许指定类型设备访问的中间件，通过 opts 配置拒绝的方式
+// 默认与 DeviceOnlyMiddleware 相同，返回 403 和 "Device not supported"
+func DeviceOnlyMiddlewareWithOptions(devices []DeviceType, opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions("DeviceOnlyMiddleware", "Device not supported", opts)
+	allowed := make(map[DeviceType]bool, len(devices))
+	for _, d := range devices {
+		allowed[d] = true
+	}
+	detect := func(r *http.Request) bool {
+		return !allowed[DetectDevice(r)]
+	}
+	describe := func(r *http.Request, d *Decision) {
+		device := DetectDevice(r)
+		if device == DeviceUnknown {
+			device = "unknown"
+		}
+		d.Reason = "unsupported device " + string(device)
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Bot = sig
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			VaryByDevice(w.Header())
+			o.serve(w, r, next, detect, describe)
+		})
+	}
+}
+
+// MobileOnlyMiddleware 创建一个中间件,仅允许手机访问
+// 需要同时允许平板时请使用 DeviceOnlyMiddleware
+func MobileOnlyMiddleware(customMessage ...string) func(http.Handler) http.Handler {
+	return DeviceOnlyMiddleware([]DeviceType{DeviceMobile}, customMessage...)
+}
+
+// DesktopOnlyMiddleware 创建一个中间件,仅允许桌面设备访问
+func DesktopOnlyMiddleware(customMessage ...string) func(http.Handler) http.Handler {
+	return DeviceOnlyMiddleware([]DeviceType{DeviceDesktop}, customMessage...)
+}
+
+// DeviceRouter 按设备类型将请求分发给不同的处理器
+// 没有对应处理器的设备类型使用 fallback 处理，fallback 为 nil 时返回 404
+func DeviceRouter(handlers map[DeviceType]http.Handler, fallback http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		VaryByDevice(w.Header())
+		if h, ok := handlers[DetectDevice(r)]; ok && h != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if fallback != nil {
+			fallback.ServeHTTP(w, r)
+			return
+		}
+		http.NotFound(w, r)
+	})
+}
+
+// VaryByDevice 为响应添加按设备区分缓存所需的 Vary 头
+// 根据设备类型返回不同内容时必须调用，否则共享缓存可能把手机页面返回给桌面用户
+func VaryByDevice(h http.Header) {
+	AddVary(h, "User-Agent", HeaderSecCHUAMobile)
+}
+
+// AddVary 向 Vary 头追加字段，已存在的字段（不区分大小写）不会重复添加
+func AddVary(h http.Header, fields ...string) {
+	existing := make(map[string]bool)
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.ToLower(strings.TrimSpace(f))
+			if f == "*" {
+				// Vary: * 已经涵盖所有字段
+				return
+			}
+			existing[f] = true
+		}
+	}
+
+	for _, f := range fields {
+		key := strings.ToLower(f)
+		if existing[key] {
+			continue
+		}
+		existing[key] = true
+		h.Add("Vary", f)
+	}
+}
@@ -0,0 +1,185 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestDetectDeviceUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		userAgent string
+		want      DeviceType
+	}{
+		{
+			name:      "桌面Chrome",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+			want:      DeviceDesktop,
+		},
+		{
+			name:      "iPhone",
+			userAgent: "Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1",
+			want:      DeviceMobile,
+		},
+		{
+			name:      "Android平板",
+			userAgent: "Mozilla/5.0 (Linux; Android 11; SM-T870) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Safari/537.36",
+			want:      DeviceTablet,
+		},
+		{
+			name:      "Kindle Fire",
+			userAgent: "Mozilla/5.0 (Linux; Android 9; KFMAWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/93.2.7 like Chrome/93.0.4577.82 Safari/537.36",
+			want:      DeviceTablet,
+		},
+		{
+			name:      "Fire TV",
+			userAgent: "Mozilla/5.0 (Linux; Android 9; AFTKA Build/PS7624.3337N; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/98.0.4758.101 Mobile Safari/537.36",
+			want:      DeviceTV,
+		},
+		{
+			name:      "LG webOS TV",
+			userAgent: "Mozilla/5.0 (Web0S; Linux/SmartTV) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/79.0.3945.79 Safari/537.36 WebAppManager",
+			want:      DeviceTV,
+		},
+		{
+			name:      "Xbox",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; Xbox; Xbox One) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19041",
+			want:      DeviceConsole,
+		},
+		{
+			name:      "Nintendo Switch",
+			userAgent: "Mozilla/5.0 (Nintendo Switch; WifiWebAuthApplet) AppleWebKit/606.4 (KHTML, like Gecko) NF/6.0.1.15.4 NintendoBrowser/5.1.0.20393",
+			want:      DeviceConsole,
+		},
+		{
+			name:      "Wear OS 手表",
+			userAgent: "Mozilla/5.0 (Linux; Android 11; Wear OS; SM-R860) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/96.0.4664.104 Mobile Safari/537.36",
+			want:      DeviceWearable,
+		},
+		{
+			name:      "Apple Watch",
+			userAgent: "Mozilla/5.0 (Watch; CPU Watch OS 8_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/19R346",
+			want:      DeviceWearable,
+		},
+		{
+			name:      "Googlebot 手机版",
+			userAgent: "Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/99.0.4844.84 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      DeviceBot,
+		},
+		{
+			name:      "空UA",
+			userAgent: "",
+			want:      DeviceBot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DetectDeviceUserAgent(tt.userAgent)
+			if got != tt.want {
+				t.Errorf("DetectDeviceUserAgent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDeviceOnlyMiddleware(t *testing.T) {
+	const (
+		desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36"
+		mobileUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1"
+	)
+
+	tests := []struct {
+		name           string
+		middleware     func(http.Handler) http.Handler
+		userAgent      string
+		wantStatusCode int
+	}{
+		{"仅手机-允许手机", MobileOnlyMiddleware(), mobileUA, http.StatusOK},
+		{"仅手机-拦截桌面", MobileOnlyMiddleware(), desktopUA, http.StatusForbidden},
+		{"仅桌面-允许桌面", DesktopOnlyMiddleware(), desktopUA, http.StatusOK},
+		{"仅桌面-拦截手机", DesktopOnlyMiddleware("请使用电脑访问"), mobileUA, http.StatusForbidden},
+		{"多设备-允许手机", DeviceOnlyMiddleware([]DeviceType{DeviceMobile, DeviceTablet}), mobileUA, http.StatusOK},
+		{"多设备-拦截curl", DeviceOnlyMiddleware([]DeviceType{DeviceMobile, DeviceDesktop}), "curl/7.68.0", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("Status code = %v, want %v", rec.Code, tt.wantStatusCode)
+			}
+			if rec.Header().Get("Vary") != "User-Agent" {
+				t.Errorf("Vary = %q, want %q", rec.Header().Get("Vary"), "User-Agent")
+			}
+		})
+	}
+}
+
+func TestDeviceRouter(t *testing.T) {
+	respond := func(body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(body))
+		})
+	}
+
+	router := DeviceRouter(map[DeviceType]http.Handler{
+		DeviceMobile: respond("mobile"),
+		DeviceTablet: respond("tablet"),
+	}, respond("desktop"))
+
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1", "mobile"},
+		{"Mozilla/5.0 (iPad; CPU OS 12_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1 Mobile/15E148 Safari/604.1", "tablet"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36", "desktop"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", tt.userAgent)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		if rec.Body.String() != tt.want {
+			t.Errorf("DeviceRouter body = %q, want %q", rec.Body.String(), tt.want)
+		}
+	}
+
+	noFallback := DeviceRouter(map[DeviceType]http.Handler{}, nil)
+	rec := httptest.NewRecorder()
+	noFallback.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("无 fallback 时 Status code = %v, want %v", rec.Code, http.StatusNotFound)
+	}
+}
+
+func TestAddVary(t *testing.T) {
+	h := http.Header{}
+	h.Set("Vary", "Accept-Encoding, user-agent")
+
+	AddVary(h, "User-Agent", "Sec-CH-UA-Mobile")
+
+	got := h.Values("Vary")
+	if len(got) != 2 || got[1] != "Sec-CH-UA-Mobile" {
+		t.Errorf("Vary = %v, want [Accept-Encoding, user-agent Sec-CH-UA-Mobile]", got)
+	}
+
+	star := http.Header{}
+	star.Set("Vary", "*")
+	AddVary(star, "User-Agent")
+	if len(star.Values("Vary")) != 1 {
+		t.Errorf("Vary: * 时不应追加字段, got %v", star.Values("Vary"))
+	}
+}
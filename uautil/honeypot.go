@@ -0,0 +1,154 @@
+package uautil
+
+import (
+	"html"
+	"net/http"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// honeypotMiddlewareName 是蜜罐中间件在 Decision 中的名称
+const honeypotMiddlewareName = "HoneypotMiddleware"
+
+// honeypotMaxKeys 是 Honeypot 最多记录的客户端数量，超过时清理过期的记录
+const honeypotMaxKeys = 65536
+
+// Honeypot 是蜜罐陷阱：陷阱 URL 在 robots.txt 中禁止访问，并以不可见链接的形式放在页面中
+// 遵守 robots.txt 的合法爬虫和真实用户不会访问陷阱，访问陷阱的客户端 IP（IPv6 为 /64 前缀）被标记为恶意爬虫
+// Honeypot 可以被多个 goroutine 同时使用
+type Honeypot struct {
+	paths []string
+	ttl   time.Duration
+
+	mu      sync.Mutex
+	flagged map[netip.Prefix]time.Time
+	now     func() time.Time
+}
+
+// NewHoneypot 创建蜜罐，paths 为陷阱路径，规则同 Route.Path；ttl 为客户端被标记的时长，默认 24 小时
+func NewHoneypot(ttl time.Duration, paths ...string) *Honeypot {
+	if ttl <= 0 {
+		ttl = 24 * time.Hour
+	}
+	return &Honeypot{
+		paths:   append([]string(nil), paths...),
+		ttl:     ttl,
+		flagged: make(map[netip.Prefix]time.Time),
+		now:     time.Now,
+	}
+}
+
+// Paths 返回陷阱路径，用于在 robots.txt 中添加 Disallow
+func (h *Honeypot) Paths() []string {
+	return append([]string(nil), h.paths...)
+}
+
+// HiddenLink 返回指向第一个陷阱路径的不可见链接，用于嵌入页面
+// 链接对用户和屏幕阅读器不可见，并带有 rel="nofollow"，只有不遵守规则的爬虫会访问
+func (h *Honeypot) HiddenLink() string {
+	if len(h.paths) == 0 {
+		return ""
+	}
+	return `<a href="` + html.EscapeString(h.paths[0]) + `" rel="nofollow" style="display:none" aria-hidden="true" tabindex="-1"></a>`
+}
+
+// IsTrap 报告路径是否为陷阱路径，路径先按 path.Clean 规范化
+func (h *Honeypot) IsTrap(path string) bool {
+	path = cleanPath(path)
+	for _, pattern := range h.paths {
+		if matchPath(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Flag 把 IP 标记为恶意爬虫
+func (h *Honeypot) Flag(ip string) {
+	prefix, ok := honeypotPrefix(ip)
+	if !ok {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if _, ok := h.flagged[prefix]; !ok && len(h.flagged) >= honeypotMaxKeys {
+		evictOldest(h.flagged, honeypotMaxKeys, now, func(until time.Time) time.Time { return until })
+	}
+	h.flagged[prefix] = now.Add(h.ttl)
+}
+
+// FlaggedIP 报告 IP 是否被标记为恶意爬虫
+func (h *Honeypot) FlaggedIP(ip string) bool {
+	prefix, ok := honeypotPrefix(ip)
+	if !ok {
+		return false
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	until, ok := h.flagged[prefix]
+	if ok && !h.now().Before(until) {
+		delete(h.flagged, prefix)
+		return false
+	}
+	return ok
+}
+
+// Flagged 报告请求的客户端 IP（由 iputil.GetClientIP 获取）是否被标记为恶意爬虫
+// 可以通过 AddBotSignal(h.Flagged) 让 IsBot 及基于它的中间件拦截被标记的客户端
+func (h *Honeypot) Flagged(r *http.Request) bool {
+	return h.FlaggedIP(iputil.GetClientIP(r))
+}
+
+// Unflag 取消 IP 的标记
+func (h *Honeypot) Unflag(ip string) {
+	if prefix, ok := honeypotPrefix(ip); ok {
+		h.mu.Lock()
+		delete(h.flagged, prefix)
+		h.mu.Unlock()
+	}
+}
+
+// Middleware 创建一个中间件，标记访问陷阱路径的客户端并拒绝该请求
+// 默认返回 404，不暴露陷阱的存在；opts 配置拒绝的方式，命中陷阱时产生 Reason 为 "honeypot" 的决定
+func (h *Honeypot) Middleware(opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions(honeypotMiddlewareName, "404 page not found",
+		append([]Option{WithStatus(http.StatusNotFound)}, opts...))
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !h.IsTrap(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h.Flag(iputil.GetClientIP(r))
+			if hasDecisionHooks(o.hooks) {
+				d := newDecision(r, honeypotMiddlewareName, ActionBlock)
+				d.Reason = "honeypot"
+				d.Rule = r.URL.Path
+				if sig, ok := ClassifyRequest(r); ok {
+					d.Bot = sig
+				}
+				emitDecision(r, d, o.hooks)
+			}
+			o.deny(w, r)
+		})
+	}
+}
+
+// honeypotPrefix 返回标记使用的前缀：IPv4 为单个 IP，IPv6 为 /64
+func honeypotPrefix(ip string) (netip.Prefix, bool) {
+	addr, ok := parseClientAddr(ip)
+	if !ok {
+		return netip.Prefix{}, false
+	}
+	return clientPrefix(addr, 32, 64)
+}
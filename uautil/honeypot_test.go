@@ -0,0 +1,108 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHoneypotMiddleware(t *testing.T) {
+	hp := NewHoneypot(time.Hour, "/trap/", "/.git/config")
+	remove := AddBotSignal(hp.Flagged)
+	defer remove()
+
+	var decisions []Decision
+	hook := WithDecisionHook(DecisionHookFunc(func(r *http.Request, d Decision) { decisions = append(decisions, d) }))
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	handler := hp.Middleware(hook)(BlockBotMiddleware(true)(app))
+
+	request := func(ip, path string) *http.Request {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("User-Agent", chrome120UA)
+		req.RemoteAddr = ip
+		return req
+	}
+
+	tests := []struct {
+		name       string
+		ip         string
+		path       string
+		wantStatus int
+	}{
+		{"正常访问", "192.0.2.1:1234", "/", http.StatusOK},
+		{"访问陷阱", "192.0.2.1:1234", "/trap/page", http.StatusNotFound},
+		{"之后的请求被识别为机器人", "192.0.2.1:1234", "/", http.StatusForbidden},
+		{"其他客户端不受影响", "192.0.2.2:1234", "/", http.StatusOK},
+		{"IPv6访问陷阱", "[2001:db8::1]:1234", "/.git/config", http.StatusNotFound},
+		{"同一IPv6前缀被识别为机器人", "[2001:db8::2]:1234", "/", http.StatusForbidden},
+		{"规范化后的陷阱路径", "192.0.2.3:1234", "/static/../trap/x", http.StatusNotFound},
+		{"重复斜杠的陷阱路径", "192.0.2.4:1234", "//.git/config", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			handler.ServeHTTP(rr, request(tt.ip, tt.path))
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatus)
+			}
+		})
+	}
+
+	if len(decisions) != 4 || decisions[0].Reason != "honeypot" || decisions[0].Rule != "/trap/page" || decisions[0].ClientIP != "192.0.2.1" {
+		t.Errorf("decisions = %+v", decisions)
+	}
+}
+
+func TestHoneypotExpiry(t *testing.T) {
+	hp := NewHoneypot(time.Hour, "/trap")
+	now := time.Now()
+	hp.now = func() time.Time { return now }
+
+	hp.Flag("203.0.113.9")
+	tests := []struct {
+		name    string
+		advance time.Duration
+		ip      string
+		want    bool
+	}{
+		{"已标记", 0, "203.0.113.9", true},
+		{"IPv4映射地址", 0, "::ffff:203.0.113.9", true},
+		{"未标记", 0, "203.0.113.10", false},
+		{"无效IP", 0, "unknown", false},
+		{"过期", time.Hour, "203.0.113.9", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = now.Add(tt.advance)
+			if got := hp.FlaggedIP(tt.ip); got != tt.want {
+				t.Errorf("FlaggedIP(%q) = %v, want %v", tt.ip, got, tt.want)
+			}
+		})
+	}
+
+	hp.Flag("203.0.113.9")
+	hp.Unflag("203.0.113.9")
+	if hp.FlaggedIP("203.0.113.9") {
+		t.Error("Unflag 后仍被标记")
+	}
+}
+
+func TestHoneypotHiddenLink(t *testing.T) {
+	hp := NewHoneypot(0, "/trap?a=1&b=2")
+	link := hp.HiddenLink()
+	for _, want := range []string{`href="/trap?a=1&amp;b=2"`, `rel="nofollow"`, `display:none`} {
+		if !strings.Contains(link, want) {
+			t.Errorf("HiddenLink() = %q, want %q", link, want)
+		}
+	}
+	if NewHoneypot(0).HiddenLink() != "" {
+		t.Error("没有陷阱路径时应返回空字符串")
+	}
+	if got := hp.Paths(); len(got) != 1 || got[0] != "/trap?a=1&b=2" {
+		t.Errorf("Paths() = %v", got)
+	}
+}
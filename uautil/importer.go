@@ -0,0 +1,243 @@
+package uautil
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+//go:generate go run ./internal/rulegen -crawlers https://raw.githubusercontent.com/monperrus/crawler-user-agents/master/crawler-user-agents.json -out community_rules.json
+
+// communityRules 是由 internal/rulegen 从 crawler-user-agents 生成的规则快照
+// 只包含 MIT 许可证的 crawler-user-agents 数据，不包含 LGPL-3.0 许可证的 device-detector 数据
+//
+//go:embed community_rules.json
+var communityRules []byte
+
+// ImportReport 是导入社区数据的结果
+type ImportReport struct {
+	Total    int      // 源数据中的条目数
+	Imported int      // 成功转换的规则数
+	Skipped  []string // 被跳过的条目及原因
+}
+
+func (r *ImportReport) skip(format string, args ...interface{}) {
+	r.Skipped = append(r.Skipped, fmt.Sprintf(format, args...))
+}
+
+// crawlerUserAgent 是 crawler-user-agents.json 中的条目
+type crawlerUserAgent struct {
+	Pattern     string   `json:"pattern"`
+	URL         string   `json:"url"`
+	Instances   []string `json:"instances"`
+	Description string   `json:"description"`
+}
+
+// ImportCrawlerUserAgents 把 crawler-user-agents 项目（github.com/monperrus/crawler-user-agents）
+// 的 crawler-user-agents.json 转换为规则集
+// 类别根据条目的示例 UA 和描述推断，Go 正则不支持的条目会被跳过并记录在报告中
+func ImportCrawlerUserAgents(r io.Reader) (*RuleSet, *ImportReport, error) {
+	var entries []crawlerUserAgent
+	if err := json.NewDecoder(r).Decode(&entries); err != nil {
+		return nil, nil, fmt.Errorf("uautil: invalid crawler-user-agents data: %w", err)
+	}
+
+	rs := &RuleSet{Version: RuleSetVersion, Mode: RuleModeMerge}
+	report := &ImportReport{Total: len(entries)}
+	for i, e := range entries {
+		if e.Pattern == "" {
+			report.skip("entry %d: empty pattern", i)
+			continue
+		}
+		if _, err := regexp.Compile(e.Pattern); err != nil {
+			report.skip("entry %d: pattern %q: %v", i, e.Pattern, err)
+			continue
+		}
+
+		category := guessCategory(e.Instances, e.Description)
+		rs.Rules = append(rs.Rules, Rule{
+			Name:     regexDisplayName(e.Pattern),
+			Pattern:  e.Pattern,
+			Match:    MatchRegex,
+			Category: category,
+			Action:   categoryAction(category),
+			URL:      e.URL,
+		})
+	}
+	report.Imported = len(rs.Rules)
+	return rs, report, nil
+}
+
+// device-detector 的机器人类别
+var deviceDetectorCategories = map[string]BotCategory{
+	"search bot":            CategorySearchEngine,
+	"social media agent":    CategorySocialPreview,
+	"site monitor":          CategoryMonitoring,
+	"network monitor":       CategoryMonitoring,
+	"feed fetcher":          CategoryFeedReader,
+	"feed reader":           CategoryFeedReader,
+	"feed parser":           CategoryFeedReader,
+	"security checker":      CategorySecurityScanner,
+	"security search bot":   CategorySecurityScanner,
+	"ai agent":              CategoryAICrawler,
+	"ai assistant":          CategoryAICrawler,
+	"ai crawler":            CategoryAICrawler,
+	"ai data scraper":       CategoryAICrawler,
+	"ai search crawler":     CategoryAICrawler,
+	"undocumented ai agent": CategoryAICrawler,
+}
+
+// ImportDeviceDetectorBots 把 Matomo device-detector 项目（github.com/matomo-org/device-detector）
+// 的 regexes/bots.yml 转换为规则集
+// device-detector 使用 LGPL-3.0 许可证，因此本模块不附带其数据，需要使用者自行下载
+// Go 正则不支持的条目（如使用了零宽断言）会被跳过并记录在报告中
+func ImportDeviceDetectorBots(r io.Reader) (*RuleSet, *ImportReport, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	root, err := parseYAML(data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("uautil: invalid device-detector data: %w", err)
+	}
+	if root.kind != yamlSeq {
+		return nil, nil, errors.New("uautil: invalid device-detector data: expected a list")
+	}
+
+	rs := &RuleSet{Version: RuleSetVersion, Mode: RuleModeMerge}
+	report := &ImportReport{Total: len(root.items)}
+	for _, item := range root.items {
+		if item.kind != yamlMap {
+			report.skip("line %d: expected a mapping", item.line)
+			continue
+		}
+
+		field := func(n *yamlNode, key string) string {
+			if v := n.fields[key]; v != nil && v.kind == yamlScalar {
+				return v.value
+			}
+			return ""
+		}
+		pattern, name := field(item, "regex"), field(item, "name")
+		if pattern == "" {
+			report.skip("line %d: empty regex", item.line)
+			continue
+		}
+		if _, err := regexp.Compile(pattern); err != nil {
+			report.skip("line %d: %s: %v", item.line, name, err)
+			continue
+		}
+
+		url := field(item, "url")
+		if producer := item.fields["producer"]; url == "" && producer != nil && producer.kind == yamlMap {
+			url = field(producer, "url")
+		}
+		category, ok := deviceDetectorCategories[strings.ToLower(field(item, "category"))]
+		if !ok {
+			category = guessCategory([]string{name}, "")
+		}
+		if name == "" {
+			name = regexDisplayName(pattern)
+		}
+
+		rs.Rules = append(rs.Rules, Rule{
+			Name:     name,
+			Pattern:  pattern,
+			Match:    MatchRegex,
+			Category: category,
+			Action:   categoryAction(category),
+			URL:      url,
+		})
+	}
+	report.Imported = len(rs.Rules)
+	return rs, report, nil
+}
+
+// MergeRuleSets 按顺序合并多个规则集，特征相同（不区分大小写）的规则只保留第一条
+func MergeRuleSets(sets ...*RuleSet) *RuleSet {
+	merged := &RuleSet{Version: RuleSetVersion, Mode: RuleModeMerge}
+	seen := make(map[string]bool)
+	for _, rs := range sets {
+		for _, r := range rs.Rules {
+			key := string(r.Kind) + "\x00" + strings.ToLower(r.Pattern)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			merged.Rules = append(merged.Rules, r)
+		}
+	}
+	return merged
+}
+
+// CommunityRules 返回内置的社区规则快照，可以直接传给 ApplyRuleSet
+// 快照由 go generate 从 crawler-user-agents 生成；需要 device-detector 的规则时用 ImportDeviceDetectorBots 自行导入
+func CommunityRules() (*RuleSet, error) {
+	return ParseRuleSet(communityRules)
+}
+
+// 根据描述推断类别的关键词，按顺序匹配
+var categoryKeywords = []struct {
+	keyword  string
+	category BotCategory
+}{
+	{"search engine", CategorySearchEngine},
+	{"link preview", CategorySocialPreview},
+	{"social", CategorySocialPreview},
+	{"seo", CategorySEOTool},
+	{"backlink", CategorySEOTool},
+	{"uptime", CategoryMonitoring},
+	{"monitor", CategoryMonitoring},
+	{"rss", CategoryFeedReader},
+	{"feed", CategoryFeedReader},
+	{"llm", CategoryAICrawler},
+	{"language model", CategoryAICrawler},
+	{"artificial intelligence", CategoryAICrawler},
+	{"headless", CategoryHeadlessBrowser},
+	{"vulnerability", CategorySecurityScanner},
+	{"security", CategorySecurityScanner},
+	{"http library", CategoryHTTPLibrary},
+	{"http client", CategoryHTTPLibrary},
+}
+
+// guessCategory 先用内置分类识别示例 UA，再按描述中的关键词推断类别
+func guessCategory(instances []string, description string) BotCategory {
+	for _, ua := range instances {
+		if sig, ok := ClassifyBot(ua); ok && sig.Category != CategoryUnknown {
+			return sig.Category
+		}
+	}
+
+	desc := strings.ToLower(description)
+	for _, k := range categoryKeywords {
+		if strings.Contains(desc, k.keyword) {
+			return k.category
+		}
+	}
+	return CategoryUnknown
+}
+
+// categoryAction 返回导入规则的默认动作：搜索引擎和社交预览视为合法爬虫
+func categoryAction(category BotCategory) string {
+	if category == CategorySearchEngine || category == CategorySocialPreview {
+		return "allow"
+	}
+	return "deny"
+}
+
+// regexDisplayName 从正则表达式中提取可读的名称，如 "Googlebot\\/" 得到 "Googlebot"
+func regexDisplayName(pattern string) string {
+	name := strings.NewReplacer(`\`, "", "^", "", "$", "").Replace(pattern)
+	if i := strings.IndexAny(name, "[](){}|?*+"); i >= 0 {
+		name = name[:i]
+	}
+	name = strings.Trim(name, "/ .-_")
+	if name == "" {
+		return pattern
+	}
+	return name
+}
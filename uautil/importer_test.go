@@ -0,0 +1,129 @@
+package uautil
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestImportCrawlerUserAgents(t *testing.T) {
+	f, err := os.Open("testdata/crawler-user-agents.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rs, report, err := ImportCrawlerUserAgents(f)
+	if err != nil {
+		t.Fatalf("ImportCrawlerUserAgents() error = %v", err)
+	}
+	if report.Total != 12 || report.Imported != 11 || len(report.Skipped) != 1 {
+		t.Errorf("report = %+v", report)
+	}
+
+	want := map[string]struct {
+		name     string
+		category BotCategory
+		action   string
+	}{
+		`Googlebot\/`:         {"Googlebot", CategorySearchEngine, "allow"},
+		"facebookexternalhit": {"facebookexternalhit", CategorySocialPreview, "allow"},
+		"AhrefsBot":           {"AhrefsBot", CategorySEOTool, "deny"},
+		`UptimeRobot\/`:       {"UptimeRobot", CategoryMonitoring, "deny"},
+		"Feedly":              {"Feedly", CategoryFeedReader, "deny"},
+		"GPTBot":              {"GPTBot", CategoryAICrawler, "deny"},
+		"[wW]get":             {"[wW]get", CategoryHTTPLibrary, "deny"},
+		"CensysInspect":       {"CensysInspect", CategorySecurityScanner, "deny"},
+	}
+	for _, r := range rs.Rules {
+		if r.Match != MatchRegex {
+			t.Errorf("%q: match = %q, want regex", r.Pattern, r.Match)
+		}
+		w, ok := want[r.Pattern]
+		if !ok {
+			continue
+		}
+		if r.Name != w.name || r.Category != w.category || r.Action != w.action {
+			t.Errorf("%q = {%q %q %q}, want %+v", r.Pattern, r.Name, r.Category, r.Action, w)
+		}
+	}
+	if err := rs.Validate(); err != nil {
+		t.Errorf("导入的规则集无效: %v", err)
+	}
+}
+
+func TestImportDeviceDetectorBots(t *testing.T) {
+	f, err := os.Open("testdata/bots.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	rs, report, err := ImportDeviceDetectorBots(f)
+	if err != nil {
+		t.Fatalf("ImportDeviceDetectorBots() error = %v", err)
+	}
+	if report.Total != 10 || report.Imported != 9 {
+		t.Errorf("report = %+v", report)
+	}
+	if len(report.Skipped) != 1 || !strings.HasPrefix(report.Skipped[0], "line 80: Generic Bot") {
+		t.Errorf("Skipped = %q", report.Skipped)
+	}
+
+	want := map[string]BotCategory{
+		"Googlebot":   CategorySearchEngine,
+		"Twitterbot":  CategorySocialPreview,
+		"SEMrushBot":  CategorySEOTool,    // Crawler 类别，按名称推断
+		"ClaudeBot":   CategoryAICrawler,  // Crawler 类别，按名称推断
+		"Pingdom Bot": CategoryMonitoring, // url 为空时使用 producer.url
+		"Inoreader":   CategoryFeedReader,
+		"Nuclei":      CategorySecurityScanner,
+	}
+	for _, r := range rs.Rules {
+		if c, ok := want[r.Name]; ok && r.Category != c {
+			t.Errorf("%s: category = %q, want %q", r.Name, r.Category, c)
+		}
+		if r.Name == "Pingdom Bot" && r.URL != "https://www.pingdom.com" {
+			t.Errorf("Pingdom Bot: url = %q", r.URL)
+		}
+	}
+
+	if _, _, err := ImportDeviceDetectorBots(strings.NewReader("regex: a\n")); err == nil {
+		t.Error("不是列表时应该返回错误")
+	}
+}
+
+func TestMergeRuleSets(t *testing.T) {
+	a := &RuleSet{Version: 1, Rules: []Rule{{Name: "A", Pattern: "Googlebot"}, {Name: "B", Pattern: "curl"}}}
+	b := &RuleSet{Version: 1, Rules: []Rule{{Name: "C", Pattern: "googlebot"}, {Name: "D", Pattern: "wget"}, {Name: "E", Pattern: "curl", Kind: RuleKindBrowser}}}
+
+	merged := MergeRuleSets(a, b)
+	var names []string
+	for _, r := range merged.Rules {
+		names = append(names, r.Name)
+	}
+	if got := strings.Join(names, ","); got != "A,B,D,E" {
+		t.Errorf("MergeRuleSets() = %s, want A,B,D,E", got)
+	}
+}
+
+func TestCommunityRules(t *testing.T) {
+	rs, err := CommunityRules()
+	if err != nil {
+		t.Fatalf("CommunityRules() error = %v", err)
+	}
+	if len(rs.Rules) == 0 {
+		t.Fatal("快照为空")
+	}
+
+	restore, err := ApplyRuleSet(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	sig, ok := ClassifyBot("Mozilla/5.0 (compatible; AhrefsBot/7.0; +http://ahrefs.com/robot/)")
+	if !ok || sig.Category != CategorySEOTool {
+		t.Errorf("ClassifyBot(AhrefsBot) = %q %v", sig.Category, ok)
+	}
+}
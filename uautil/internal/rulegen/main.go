@@ -0,0 +1,93 @@
+// Command rulegen 把 crawler-user-agents 和 device-detector 的机器人数据转换为 uautil 规则文件，
+// 生成的文件可以用 uautil.LoadRuleSetFile 加载：
+//
+//	go run ./internal/rulegen -crawlers crawler-user-agents.json -bots bots.yml -out bot_rules.json
+//
+// -crawlers 和 -bots 可以是本地文件，也可以是 http(s) URL。
+// go generate 只用 -crawlers 生成 uautil/community_rules.json：crawler-user-agents 使用 MIT 许可证，可以随本模块分发；
+// device-detector 使用 LGPL-3.0 许可证，生成的文件只供使用者自行部署。
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/uautil"
+)
+
+func main() {
+	crawlers := flag.String("crawlers", "", "crawler-user-agents.json 的路径或 URL")
+	bots := flag.String("bots", "", "device-detector regexes/bots.yml 的路径或 URL")
+	out := flag.String("out", "bot_rules.json", "输出文件")
+	verbose := flag.Bool("v", false, "输出被跳过的条目")
+	flag.Parse()
+
+	if *crawlers == "" && *bots == "" {
+		log.Fatal("rulegen: at least one of -crawlers and -bots is required")
+	}
+
+	var sets []*uautil.RuleSet
+	sources := []struct {
+		name   string
+		source string
+		load   func(io.Reader) (*uautil.RuleSet, *uautil.ImportReport, error)
+	}{
+		{"crawler-user-agents", *crawlers, uautil.ImportCrawlerUserAgents},
+		{"device-detector", *bots, uautil.ImportDeviceDetectorBots},
+	}
+	for _, s := range sources {
+		if s.source == "" {
+			continue
+		}
+		data, err := fetch(s.source)
+		if err != nil {
+			log.Fatalf("rulegen: %s: %v", s.source, err)
+		}
+		rs, report, err := s.load(bytes.NewReader(data))
+		if err != nil {
+			log.Fatalf("rulegen: %s: %v", s.source, err)
+		}
+
+		log.Printf("%s: imported %d of %d entries, skipped %d", s.name, report.Imported, report.Total, len(report.Skipped))
+		if *verbose {
+			for _, msg := range report.Skipped {
+				log.Printf("  skipped %s", msg)
+			}
+		}
+		sets = append(sets, rs)
+	}
+
+	var buf bytes.Buffer
+	if err := uautil.MergeRuleSets(sets...).WriteJSON(&buf); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(*out, buf.Bytes(), 0o644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// fetch 读取本地文件或下载 URL
+func fetch(source string) ([]byte, error) {
+	if !strings.HasPrefix(source, "http://") && !strings.HasPrefix(source, "https://") {
+		return os.ReadFile(source)
+	}
+
+	client := &http.Client{Timeout: time.Minute}
+	resp, err := client.Get(source)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
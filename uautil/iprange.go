@@ -0,0 +1,176 @@
+package uautil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// IPRangeVerifier 通过爬虫官方公布的 IP 段验证爬虫，比 DNS 验证开销更小
+// 支持 Google（googlebot.json、special-crawlers.json）、Bing（bingbot.json）、
+// Apple（applebot.json）、DuckDuckGo（duckduckbot.json）、OpenAI（gptbot.json）等使用的 JSON 格式
+type IPRangeVerifier struct {
+	mu     sync.RWMutex
+	ranges map[string]*iputil.PrefixSet
+}
+
+// ipRangeFile 是各爬虫公布 IP 段所使用的 JSON 格式
+//
+//	{"creationTime": "...", "prefixes": [{"ipv4Prefix": "66.249.64.0/27"}, {"ipv6Prefix": "2001:4860:4801:10::/64"}]}
+type ipRangeFile struct {
+	Prefixes []struct {
+		IPv4Prefix string `json:"ipv4Prefix"`
+		IPv6Prefix string `json:"ipv6Prefix"`
+	} `json:"prefixes"`
+}
+
+// NewIPRangeVerifier 创建基于 IP 段的爬虫验证器
+func NewIPRangeVerifier() *IPRangeVerifier {
+	return &IPRangeVerifier{ranges: make(map[string]*iputil.PrefixSet)}
+}
+
+// LoadFile 从本地 JSON 文件加载 bot 的 IP 段，替换该爬虫已有的 IP 段
+// bot 为合法爬虫特征，如 "googlebot"；一个爬虫有多个文件时一起传入，如 googlebot.json 和 special-crawlers.json
+func (v *IPRangeVerifier) LoadFile(bot string, paths ...string) error {
+	var cidrs []string
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		parsed, err := parseIPRanges(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		cidrs = append(cidrs, parsed...)
+	}
+	return v.setRanges(bot, cidrs)
+}
+
+// LoadJSON 从 JSON 加载 bot 的 IP 段，替换该爬虫已有的 IP 段，多个 readers 的 IP 段合并在一起
+// 除官方格式外，也接受由 CIDR 或单个 IP 组成的字符串数组
+func (v *IPRangeVerifier) LoadJSON(bot string, readers ...io.Reader) error {
+	var cidrs []string
+	for _, r := range readers {
+		data, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		parsed, err := parseIPRanges(data)
+		if err != nil {
+			return err
+		}
+		cidrs = append(cidrs, parsed...)
+	}
+	return v.setRanges(bot, cidrs)
+}
+
+// setRanges 用 cidrs 替换 bot 的 IP 段
+func (v *IPRangeVerifier) setRanges(bot string, cidrs []string) error {
+	set, err := iputil.NewPrefixSet(cidrs...)
+	if err != nil {
+		return err
+	}
+
+	v.mu.Lock()
+	v.ranges[strings.ToLower(bot)] = set
+	v.mu.Unlock()
+	return nil
+}
+
+// parseIPRanges 解析 IP 段文件，返回其中的 CIDR
+func parseIPRanges(data []byte) ([]string, error) {
+	var cidrs []string
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		if err := json.Unmarshal(data, &cidrs); err != nil {
+			return nil, fmt.Errorf("uautil: invalid IP range list: %w", err)
+		}
+		return cidrs, nil
+	}
+
+	var file ipRangeFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("uautil: invalid IP range file: %w", err)
+	}
+	for _, p := range file.Prefixes {
+		if p.IPv4Prefix != "" {
+			cidrs = append(cidrs, p.IPv4Prefix)
+		}
+		if p.IPv6Prefix != "" {
+			cidrs = append(cidrs, p.IPv6Prefix)
+		}
+	}
+	return cidrs, nil
+}
+
+// AddPrefix 为 bot 追加 IP 段，cidrs 可以是 CIDR 或单个 IP
+func (v *IPRangeVerifier) AddPrefix(bot string, cidrs ...string) error {
+	bot = strings.ToLower(bot)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	set := v.ranges[bot]
+	if set == nil {
+		set, _ = iputil.NewPrefixSet()
+	} else {
+		set = set.Clone()
+	}
+	for _, c := range cidrs {
+		if err := set.Add(c); err != nil {
+			return err
+		}
+	}
+	v.ranges[bot] = set
+	return nil
+}
+
+// VerifyBot 验证请求的客户端 IP（由 iputil.GetClientIP 获取）是否在 bot 的 IP 段内
+func (v *IPRangeVerifier) VerifyBot(r *http.Request, bot string) VerifyResult {
+	return v.VerifyIP(iputil.GetClientIP(r), bot)
+}
+
+// VerifyIP 验证 IP 是否在 bot 的 IP 段内
+func (v *IPRangeVerifier) VerifyIP(ip, bot string) VerifyResult {
+	v.mu.RLock()
+	set := v.ranges[strings.ToLower(bot)]
+	v.mu.RUnlock()
+
+	if set == nil {
+		return VerifyUnsupported
+	}
+
+	if !set.ContainsIP(ip) {
+		return VerifyFailed
+	}
+	return VerifyPassed
+}
+
+// ChainVerifiers 依次使用多个验证器，任意一个验证通过即返回 VerifyPassed
+// 没有验证器通过时，有验证器返回 VerifyFailed 则返回 VerifyFailed，否则返回 VerifyUnsupported
+// IP 段列表过时导致 IPRangeVerifier 验证失败时，后面的 DNSVerifier 仍然可以通过验证；
+// 通常把开销小的 IPRangeVerifier 放在 DNSVerifier 之前，IP 段命中时不再进行 DNS 查询
+func ChainVerifiers(verifiers ...BotVerifier) BotVerifier {
+	return verifierChain(verifiers)
+}
+
+type verifierChain []BotVerifier
+
+func (c verifierChain) VerifyBot(r *http.Request, bot string) VerifyResult {
+	result := VerifyUnsupported
+	for _, v := range c {
+		switch v.VerifyBot(r, bot) {
+		case VerifyPassed:
+			return VerifyPassed
+		case VerifyFailed:
+			result = VerifyFailed
+		}
+	}
+	return result
+}
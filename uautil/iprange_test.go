@@ -0,0 +1,174 @@
+package uautil
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const googlebotRanges = `{
+  "creationTime": "2024-01-01T00:00:00.000000",
+  "prefixes": [
+    {"ipv6Prefix": "2001:4860:4801:10::/64"},
+    {"ipv4Prefix": "66.249.64.0/27"},
+    {"ipv4Prefix": "66.249.66.0/27"}
+  ]
+}`
+
+func TestIPRangeVerifier(t *testing.T) {
+	v := NewIPRangeVerifier()
+	if err := v.LoadJSON("Googlebot", strings.NewReader(googlebotRanges)); err != nil {
+		t.Fatalf("LoadJSON() err = %v", err)
+	}
+	if err := v.LoadJSON("duckduckbot", strings.NewReader(`["20.191.45.212", "40.88.21.235/32"]`)); err != nil {
+		t.Fatalf("LoadJSON() err = %v", err)
+	}
+
+	tests := []struct {
+		name string
+		ip   string
+		bot  string
+		want VerifyResult
+	}{
+		{"IPv4 段内", "66.249.66.1", "googlebot", VerifyPassed},
+		{"IPv4 段边界", "66.249.64.31", "googlebot", VerifyPassed},
+		{"IPv4 段外", "66.249.64.32", "googlebot", VerifyFailed},
+		{"IPv6 段内", "2001:4860:4801:10::abcd", "googlebot", VerifyPassed},
+		{"IPv6 段外", "2001:4860:4801:11::1", "googlebot", VerifyFailed},
+		{"IPv4 映射地址", "::ffff:66.249.66.1", "googlebot", VerifyPassed},
+		{"单个 IP", "20.191.45.212", "duckduckbot", VerifyPassed},
+		{"单个 IP 不匹配", "20.191.45.213", "duckduckbot", VerifyFailed},
+		{"无效 IP", "bad", "googlebot", VerifyFailed},
+		{"未加载的爬虫", "66.249.66.1", "bingbot", VerifyUnsupported},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.VerifyIP(tt.ip, tt.bot); got != tt.want {
+				t.Errorf("VerifyIP(%q, %q) = %v, want %v", tt.ip, tt.bot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPRangeVerifierLoadFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "bingbot.json")
+	if err := os.WriteFile(path, []byte(`{"prefixes":[{"ipv4Prefix":"157.55.39.0/24"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	v := NewIPRangeVerifier()
+	if err := v.LoadFile("bingbot", path); err != nil {
+		t.Fatalf("LoadFile() err = %v", err)
+	}
+	if got := v.VerifyIP("157.55.39.1", "bingbot"); got != VerifyPassed {
+		t.Errorf("VerifyIP() = %v, want %v", got, VerifyPassed)
+	}
+
+	// 重新加载会替换原有 IP 段
+	if err := os.WriteFile(path, []byte(`{"prefixes":[{"ipv4Prefix":"207.46.13.0/24"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.LoadFile("bingbot", path); err != nil {
+		t.Fatalf("LoadFile() err = %v", err)
+	}
+	if got := v.VerifyIP("157.55.39.1", "bingbot"); got != VerifyFailed {
+		t.Errorf("重新加载后 VerifyIP() = %v, want %v", got, VerifyFailed)
+	}
+
+	// 多个文件的 IP 段合并在一起
+	special := filepath.Join(dir, "special.json")
+	if err := os.WriteFile(special, []byte(`["157.55.39.0/24"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.LoadFile("bingbot", path, special); err != nil {
+		t.Fatalf("LoadFile() err = %v", err)
+	}
+	for _, ip := range []string{"157.55.39.1", "207.46.13.1"} {
+		if got := v.VerifyIP(ip, "bingbot"); got != VerifyPassed {
+			t.Errorf("加载多个文件后 VerifyIP(%q) = %v, want %v", ip, got, VerifyPassed)
+		}
+	}
+
+	if err := v.LoadFile("bingbot", filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("文件不存在时应该返回错误")
+	}
+	if err := v.LoadJSON("bingbot", strings.NewReader(`{"prefixes":[{"ipv4Prefix":"300.0.0.0/8"}]}`)); err == nil {
+		t.Error("无效 IP 段应该返回错误")
+	}
+	if err := v.LoadJSON("bingbot", strings.NewReader(`{`)); err == nil {
+		t.Error("无效 JSON 应该返回错误")
+	}
+}
+
+func TestIPRangeVerifierAddPrefix(t *testing.T) {
+	v := NewIPRangeVerifier()
+	if err := v.AddPrefix("gptbot", "20.171.206.0/24", "52.230.152.0/24"); err != nil {
+		t.Fatalf("AddPrefix() err = %v", err)
+	}
+	if err := v.AddPrefix("gptbot", "not-a-cidr"); err == nil {
+		t.Error("无效 CIDR 应该返回错误")
+	}
+
+	if got := v.VerifyIP("52.230.152.10", "gptbot"); got != VerifyPassed {
+		t.Errorf("VerifyIP() = %v, want %v", got, VerifyPassed)
+	}
+}
+
+func TestChainVerifiers(t *testing.T) {
+	ranges := NewIPRangeVerifier()
+	if err := ranges.LoadJSON("googlebot", strings.NewReader(googlebotRanges)); err != nil {
+		t.Fatal(err)
+	}
+	if err := ranges.AddPrefix("applebot", "17.241.0.0/16"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ranges.AddPrefix("gptbot", "20.171.206.0/24"); err != nil {
+		t.Fatal(err)
+	}
+
+	resolver := newFakeResolver()
+	resolver.ptr["66.249.70.1"] = []string{"crawl-66-249-70-1.googlebot.com."}
+	resolver.hosts["crawl-66-249-70-1.googlebot.com"] = []string{"66.249.70.1"}
+	chain := ChainVerifiers(ranges, NewDNSVerifier(resolver, time.Minute))
+
+	restore := SetBotVerifier(chain)
+	defer restore()
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		remoteAddr string
+		want       bool
+	}{
+		{"IP 段内的 Googlebot", "Googlebot/2.1", "66.249.64.5:1234", false},
+		{"IP 段外的 Googlebot", "Googlebot/2.1", "203.0.113.20:1234", true},
+		{"IP 段列表过时时由 DNS 验证", "Googlebot/2.1", "66.249.70.1:1234", false},
+		{"Bingbot 由 DNS 验证", "bingbot/2.0", "157.55.39.1:1234", false},
+		{"冒充 Bingbot", "bingbot/2.0", "198.51.100.10:1234", true},
+		{"IP 段内的 Applebot", "Mozilla/5.0 (compatible; Applebot/0.1; +http://www.apple.com/go/applebot)", "17.241.1.1:1234", false},
+		{"IP 段内的 GPTBot", "Mozilla/5.0 (compatible; GPTBot/1.2; +https://openai.com/gptbot)", "20.171.206.5:1234", false},
+		{"IP 段外的 GPTBot", "Mozilla/5.0 (compatible; GPTBot/1.2; +https://openai.com/gptbot)", "203.0.113.20:1234", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.RemoteAddr = tt.remoteAddr
+
+			if got := IsBot(req, true); got != tt.want {
+				t.Errorf("IsBot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// Bingbot 两次，IP 段外的 Googlebot 两次；IP 段内的 Googlebot 不应查询 DNS
+	if resolver.lookups != 4 {
+		t.Errorf("lookups = %d, want 4", resolver.lookups)
+	}
+}
@@ -0,0 +1,217 @@
+package uautil
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Recorder 记录检测和拦截的指标
+// Metrics 以 Prometheus 文本格式导出指标；使用 OpenTelemetry 等系统时可以自行实现该接口
+type Recorder interface {
+	// OnDecision 记录中间件的一个决定，包括处理方式、机器人类别和检测耗时
+	DecisionHook
+	// RecordVerifyCache 记录 DNSVerifier 对爬虫 bot 的一次缓存查询，hit 表示命中
+	RecordVerifyCache(bot string, hit bool)
+}
+
+// 当前使用的指标记录器
+var metricsRecorder Recorder
+
+// SetRecorder 设置指标记录器，应在处理请求之前调用
+// 设置后所有中间件的决定和 DNSVerifier 的缓存查询都会被记录，返回的函数可用于恢复之前的记录器
+func SetRecorder(r Recorder) func() {
+	previous := metricsRecorder
+	metricsRecorder = r
+
+	return func() {
+		metricsRecorder = previous
+	}
+}
+
+// DefaultLatencyBuckets 是检测耗时直方图默认的桶上限（秒）
+var DefaultLatencyBuckets = []float64{0.00001, 0.00005, 0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+// Metrics 是不依赖第三方库的指标记录器，通过 ServeHTTP 以 Prometheus 文本格式导出以下指标：
+//   - uautil_decisions_total{middleware,action,report_only}：各中间件放行、拒绝、挑战和限流的请求数
+//   - uautil_classified_requests_total{category}：按机器人类别统计的请求数，非机器人为 "none"
+//   - uautil_verify_cache_requests_total{bot,result}：验证结果缓存的命中（hit）和未命中（miss）次数
+//   - uautil_detection_duration_seconds{middleware}：检测耗时直方图
+//
+// Metrics 可以被多个 goroutine 同时使用
+type Metrics struct {
+	mu         sync.Mutex
+	buckets    []float64
+	decisions  map[decisionLabels]uint64
+	categories map[string]uint64
+	cache      map[cacheLabels]uint64
+	latency    map[string]*histogram
+}
+
+type decisionLabels struct {
+	middleware string
+	action     string
+	reportOnly bool
+}
+
+type cacheLabels struct {
+	bot string
+	hit bool
+}
+
+type histogram struct {
+	counts []uint64 // 每个桶的数量（不累计），最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics 创建指标记录器，buckets 为检测耗时直方图的桶上限（秒），为空时使用 DefaultLatencyBuckets
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Metrics{
+		buckets:    buckets,
+		decisions:  make(map[decisionLabels]uint64),
+		categories: make(map[string]uint64),
+		cache:      make(map[cacheLabels]uint64),
+		latency:    make(map[string]*histogram),
+	}
+}
+
+// OnDecision 记录一个决定
+// 没有命中机器人特征的决定（如被放行的请求）只按 User-Agent 分类（见 ClassifyBot），不会触发机器人验证的 DNS 查询
+func (m *Metrics) OnDecision(r *http.Request, d Decision) {
+	category := string(d.Bot.Category)
+	if category == "" {
+		if sig, ok := ClassifyBot(d.UserAgent.Raw); ok {
+			category = string(sig.Category)
+		} else {
+			category = "none"
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.decisions[decisionLabels{d.Middleware, d.Action.String(), d.ReportOnly}]++
+	m.categories[category]++
+
+	h := m.latency[d.Middleware]
+	if h == nil {
+		h = &histogram{counts: make([]uint64, len(m.buckets)+1)}
+		m.latency[d.Middleware] = h
+	}
+	seconds := d.Latency.Seconds()
+	h.counts[sort.SearchFloat64s(m.buckets, seconds)]++
+	h.sum += seconds
+	h.count++
+}
+
+// RecordVerifyCache 记录一次验证结果缓存查询
+func (m *Metrics) RecordVerifyCache(bot string, hit bool) {
+	m.mu.Lock()
+	m.cache[cacheLabels{bot, hit}]++
+	m.mu.Unlock()
+}
+
+// ServeHTTP 以 Prometheus 文本格式返回指标
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// WriteTo 以 Prometheus 文本格式写入指标
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	m.mu.Lock()
+
+	writeHeader(&b, "uautil_decisions_total", "counter", "Requests handled by uautil middlewares by action.")
+	decisions := make([]decisionLabels, 0, len(m.decisions))
+	for k := range m.decisions {
+		decisions = append(decisions, k)
+	}
+	sort.Slice(decisions, func(i, j int) bool {
+		a, c := decisions[i], decisions[j]
+		if a.middleware != c.middleware {
+			return a.middleware < c.middleware
+		}
+		if a.action != c.action {
+			return a.action < c.action
+		}
+		return !a.reportOnly && c.reportOnly
+	})
+	for _, k := range decisions {
+		fmt.Fprintf(&b, "uautil_decisions_total{middleware=%s,action=%s,report_only=\"%t\"} %d\n",
+			labelValue(k.middleware), labelValue(k.action), k.reportOnly, m.decisions[k])
+	}
+
+	writeHeader(&b, "uautil_classified_requests_total", "counter", "Requests classified by bot category.")
+	for _, category := range sortedKeys(m.categories) {
+		fmt.Fprintf(&b, "uautil_classified_requests_total{category=%s} %d\n", labelValue(category), m.categories[category])
+	}
+
+	writeHeader(&b, "uautil_verify_cache_requests_total", "counter", "Bot verifier cache lookups by result.")
+	cache := make([]cacheLabels, 0, len(m.cache))
+	for k := range m.cache {
+		cache = append(cache, k)
+	}
+	sort.Slice(cache, func(i, j int) bool {
+		if cache[i].bot != cache[j].bot {
+			return cache[i].bot < cache[j].bot
+		}
+		return cache[i].hit && !cache[j].hit
+	})
+	for _, k := range cache {
+		result := "miss"
+		if k.hit {
+			result = "hit"
+		}
+		fmt.Fprintf(&b, "uautil_verify_cache_requests_total{bot=%s,result=\"%s\"} %d\n", labelValue(k.bot), result, m.cache[k])
+	}
+
+	writeHeader(&b, "uautil_detection_duration_seconds", "histogram", "Time spent classifying requests.")
+	for _, middleware := range sortedKeys(m.latency) {
+		h := m.latency[middleware]
+		label := labelValue(middleware)
+		var cumulative uint64
+		for i, upper := range m.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "uautil_detection_duration_seconds_bucket{middleware=%s,le=\"%s\"} %d\n",
+				label, strconv.FormatFloat(upper, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "uautil_detection_duration_seconds_bucket{middleware=%s,le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(&b, "uautil_detection_duration_seconds_sum{middleware=%s} %s\n", label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "uautil_detection_duration_seconds_count{middleware=%s} %d\n", label, h.count)
+	}
+
+	m.mu.Unlock()
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// writeHeader 写入指标的 HELP 和 TYPE 行
+func writeHeader(b *strings.Builder, name, typ, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+// labelValue 返回加引号并转义的标签值
+func labelValue(v string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v) + `"`
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package uautil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetrics(t *testing.T) {
+	m := NewMetrics(0.001, 1)
+	restore := SetRecorder(m)
+	defer restore()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	block := BlockBotMiddleware(true)(handler)
+	report := BlockBotMiddlewareWithOptions(true, WithReportOnly(func(r *http.Request, d Decision) {}))(handler)
+
+	curl := httptest.NewRequest("GET", "/", nil)
+	curl.Header.Set("User-Agent", "curl/8.0")
+	gptbot := httptest.NewRequest("GET", "/", nil)
+	gptbot.Header.Set("User-Agent", "Mozilla/5.0 (compatible; GPTBot/1.0; +https://openai.com/gptbot)")
+
+	block.ServeHTTP(httptest.NewRecorder(), curl)
+	block.ServeHTTP(httptest.NewRecorder(), curl)
+	block.ServeHTTP(httptest.NewRecorder(), browserRequest(chrome120UA))
+	report.ServeHTTP(httptest.NewRecorder(), gptbot)
+
+	v := NewDNSVerifier(newFakeResolver(), time.Minute)
+	v.VerifyIP(context.Background(), "66.249.66.1", "googlebot")
+	v.VerifyIP(context.Background(), "66.249.66.1", "googlebot")
+	NewDNSVerifier(newFakeResolver(), 0).VerifyIP(context.Background(), "66.249.66.1", "googlebot")
+
+	rr := httptest.NewRecorder()
+	m.ServeHTTP(rr, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rr.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	body := rr.Body.String()
+
+	tests := []struct {
+		name string
+		line string
+	}{
+		{"拒绝计数", `uautil_decisions_total{middleware="BlockBotMiddleware",action="block",report_only="false"} 2`},
+		{"放行计数", `uautil_decisions_total{middleware="BlockBotMiddleware",action="allow",report_only="false"} 1`},
+		{"仅报告计数", `uautil_decisions_total{middleware="BlockBotMiddleware",action="block",report_only="true"} 1`},
+		{"HTTP库类别", `uautil_classified_requests_total{category="http_library"} 2`},
+		{"AI爬虫类别", `uautil_classified_requests_total{category="ai_crawler"} 1`},
+		{"非机器人", `uautil_classified_requests_total{category="none"} 1`},
+		{"缓存命中", `uautil_verify_cache_requests_total{bot="googlebot",result="hit"} 1`},
+		{"缓存未命中", `uautil_verify_cache_requests_total{bot="googlebot",result="miss"} 1`},
+		{"直方图桶", `uautil_detection_duration_seconds_bucket{middleware="BlockBotMiddleware",le="1"} 4`},
+		{"直方图总数", `uautil_detection_duration_seconds_bucket{middleware="BlockBotMiddleware",le="+Inf"} 4`},
+		{"直方图计数", `uautil_detection_duration_seconds_count{middleware="BlockBotMiddleware"} 4`},
+		{"类型", "# TYPE uautil_detection_duration_seconds histogram"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(body, tt.line+"\n") {
+				t.Errorf("指标中缺少 %q:\n%s", tt.line, body)
+			}
+		})
+	}
+}
+
+func TestMetricsSkipsVerification(t *testing.T) {
+	resolver := newFakeResolver()
+	restore := SetBotVerifier(NewDNSVerifier(resolver, 0))
+	defer restore()
+
+	m := NewMetrics()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	req.RemoteAddr = "66.249.66.1:1234"
+	m.OnDecision(req, newDecision(req, "BrowserOnlyMiddleware", ActionAllow))
+
+	if resolver.lookups != 0 {
+		t.Errorf("lookups = %d, want 0", resolver.lookups)
+	}
+	var buf strings.Builder
+	m.WriteTo(&buf)
+	if line := `uautil_classified_requests_total{category="search_engine"} 1`; !strings.Contains(buf.String(), line+"\n") {
+		t.Errorf("指标中缺少 %q:\n%s", line, buf.String())
+	}
+}
+
+func TestLabelValue(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  string
+	}{
+		{"普通值", "block", `"block"`},
+		{"引号和反斜杠", `a"b\c`, `"a\"b\\c"`},
+		{"换行", "a\nb", `"a\nb"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := labelValue(tt.value); got != tt.want {
+				t.Errorf("labelValue(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,263 @@
+package uautil
+
+import (
+	"container/heap"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// offenderSlots 是滑动窗口划分的时间片数量
+const offenderSlots = 10
+
+// Offender 是请求最多的客户端 IP 或 User-Agent
+// 计数使用 Space-Saving 算法估算，实际数量在 Count-Error 和 Count 之间
+type Offender struct {
+	Key    string `json:"key"`    // 客户端 IP 或规范化后的 User-Agent
+	Count  uint64 `json:"count"`  // 请求数（可能偏大）
+	Error  uint64 `json:"error"`  // 计数的最大误差
+	Denied uint64 `json:"denied"` // 其中未被放行的请求数（可能偏大）
+}
+
+// TopOffenders 按客户端 IP 和规范化的 User-Agent 统计请求最多的客户端
+// 每个时间片使用容量固定的 Space-Saving 摘要，内存占用与流量无关；查询时合并窗口内的时间片
+// TopOffenders 实现了 DecisionHook，通过 AddDecisionHook 或 WithDecisionHook 接收中间件的决定；可以被多个 goroutine 同时使用
+type TopOffenders struct {
+	mu       sync.Mutex
+	capacity int
+	slot     time.Duration
+	ips      [offenderSlots]*spaceSaving
+	uas      [offenderSlots]*spaceSaving
+	starts   [offenderSlots]time.Time
+	now      func() time.Time
+}
+
+// NewTopOffenders 创建请求最多客户端的统计
+// capacity 为每个时间片最多记录的 IP 和 User-Agent 数量，默认 1000；window 为可查询的最长时间窗口，默认 5 分钟
+func NewTopOffenders(capacity int, window time.Duration) *TopOffenders {
+	if capacity <= 0 {
+		capacity = 1000
+	}
+	if window <= 0 {
+		window = 5 * time.Minute
+	}
+	slot := window / offenderSlots
+	if slot <= 0 {
+		slot = 1
+	}
+	return &TopOffenders{capacity: capacity, slot: slot, now: time.Now}
+}
+
+// OnDecision 记录一个决定的客户端 IP 和 User-Agent
+// 每个决定计一次：通过 AddDecisionHook 全局注册时，经过 N 个中间件的请求会被计 N 次；
+// 需要每个请求只计一次时，只在最外层的一个中间件上使用 WithDecisionHook
+func (t *TopOffenders) OnDecision(r *http.Request, d Decision) {
+	t.Record(d.ClientIP, NormalizeUserAgent(d.UserAgent.Raw), d.Action != ActionAllow && !d.ReportOnly)
+}
+
+// Record 记录一次请求，userAgent 应先经过 NormalizeUserAgent 规范化，denied 表示请求未被放行
+func (t *TopOffenders) Record(ip, userAgent string, denied bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := t.now().UnixNano() / int64(t.slot)
+	start := time.Unix(0, n*int64(t.slot))
+	i := int(n % offenderSlots)
+	if t.ips[i] == nil || !t.starts[i].Equal(start) {
+		t.ips[i] = newSpaceSaving(t.capacity)
+		t.uas[i] = newSpaceSaving(t.capacity)
+		t.starts[i] = start
+	}
+	if ip != "" {
+		t.ips[i].add(ip, denied)
+	}
+	t.uas[i].add(userAgent, denied)
+}
+
+// TopIPs 返回最近 window 内请求最多的 n 个客户端 IP，包含与窗口有重叠的时间片，window 为 0 或超过最长窗口时使用最长窗口
+func (t *TopOffenders) TopIPs(n int, window time.Duration) []Offender {
+	return t.top(n, window, func(i int) *spaceSaving { return t.ips[i] })
+}
+
+// TopUserAgents 返回最近 window 内请求最多的 n 个规范化 User-Agent
+func (t *TopOffenders) TopUserAgents(n int, window time.Duration) []Offender {
+	return t.top(n, window, func(i int) *spaceSaving { return t.uas[i] })
+}
+
+func (t *TopOffenders) top(n int, window time.Duration, summary func(i int) *spaceSaving) []Offender {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if window <= 0 || window > t.slot*offenderSlots {
+		window = t.slot * offenderSlots
+	}
+	// 包含与 [now-window, now] 有重叠的时间片
+	since := t.now().Add(-window)
+
+	merged := make(map[string]*Offender)
+	for i := range t.starts {
+		s := summary(i)
+		if s == nil || !t.starts[i].Add(t.slot).After(since) {
+			continue
+		}
+		for _, e := range s.entries {
+			o := merged[e.key]
+			if o == nil {
+				o = &Offender{Key: e.key}
+				merged[e.key] = o
+			}
+			o.Count += e.count
+			o.Error += e.error
+			o.Denied += e.denied
+		}
+	}
+
+	result := make([]Offender, 0, len(merged))
+	for _, o := range merged {
+		result = append(result, *o)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].Key < result[j].Key
+	})
+	if n > 0 && len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
+
+// ServeHTTP 以 JSON 返回请求最多的客户端 IP 和 User-Agent，用于调试
+// 查询参数 n 为返回的数量（默认 10），window 为时间窗口（如 "1m"，默认为最长窗口）
+func (t *TopOffenders) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := 10
+	if v := r.URL.Query().Get("n"); v != "" {
+		parsed, err := strconv.Atoi(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "invalid n", http.StatusBadRequest)
+			return
+		}
+		n = parsed
+	}
+	window := t.slot * offenderSlots
+	if v := r.URL.Query().Get("window"); v != "" {
+		parsed, err := time.ParseDuration(v)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "invalid window", http.StatusBadRequest)
+			return
+		}
+		if parsed < window {
+			window = parsed
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(struct {
+		Window     string     `json:"window"`
+		IPs        []Offender `json:"ips"`
+		UserAgents []Offender `json:"user_agents"`
+	}{window.String(), t.TopIPs(n, window), t.TopUserAgents(n, window)})
+}
+
+// NormalizeUserAgent 把 User-Agent 规范化为用于统计的名称，使同一客户端的不同版本归为一类
+// 命中机器人特征时返回机器人名称，识别出浏览器时返回浏览器、主版本号和操作系统（如 "Chrome 120 / Windows"），
+// 空 User-Agent 返回 "(empty)"，其他返回截断到 128 字节以内的原始值，不会截断多字节的 UTF-8 字符
+func NormalizeUserAgent(userAgent string) string {
+	userAgent = strings.TrimSpace(userAgent)
+	if userAgent == "" {
+		return "(empty)"
+	}
+	if sig, ok := ClassifyBot(userAgent); ok {
+		return sig.Name
+	}
+	if ua := Parse(userAgent); ua.Browser != "" {
+		name := ua.Browser
+		if major, _, _ := strings.Cut(ua.BrowserVersion, "."); major != "" {
+			name += " " + major
+		}
+		if ua.OS != "" {
+			name += " / " + ua.OS
+		}
+		return name
+	}
+	if len(userAgent) > 128 {
+		cut := 128
+		for cut > 0 && !utf8.RuneStart(userAgent[cut]) {
+			cut--
+		}
+		userAgent = userAgent[:cut]
+	}
+	return userAgent
+}
+
+// spaceSaving 是 Space-Saving 算法的摘要：最多记录 capacity 个键，
+// 已满时新键替换计数最小的键并继承其计数作为误差
+type spaceSaving struct {
+	capacity int
+	index    map[string]*ssEntry
+	entries  ssHeap
+}
+
+type ssEntry struct {
+	key    string
+	count  uint64
+	error  uint64
+	denied uint64
+	pos    int
+}
+
+func newSpaceSaving(capacity int) *spaceSaving {
+	return &spaceSaving{capacity: capacity, index: make(map[string]*ssEntry)}
+}
+
+func (s *spaceSaving) add(key string, denied bool) {
+	e := s.index[key]
+	switch {
+	case e != nil:
+	case len(s.entries) < s.capacity:
+		e = &ssEntry{key: key}
+		s.index[key] = e
+		heap.Push(&s.entries, e)
+	default:
+		e = s.entries[0]
+		delete(s.index, e.key)
+		e.key, e.error, e.denied = key, e.count, 0
+		s.index[key] = e
+	}
+
+	e.count++
+	if denied {
+		e.denied++
+	}
+	heap.Fix(&s.entries, e.pos)
+}
+
+// ssHeap 是按计数排序的最小堆
+type ssHeap []*ssEntry
+
+func (h ssHeap) Len() int           { return len(h) }
+func (h ssHeap) Less(i, j int) bool { return h[i].count < h[j].count }
+func (h ssHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].pos, h[j].pos = i, j
+}
+
+func (h *ssHeap) Push(x any) {
+	e := x.(*ssEntry)
+	e.pos = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *ssHeap) Pop() any {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,151 @@
+package uautil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpaceSaving(t *testing.T) {
+	s := newSpaceSaving(3)
+	for i := 0; i < 100; i++ {
+		s.add("heavy", true)
+		s.add(fmt.Sprintf("noise-%d", i), false)
+	}
+
+	var heavy *ssEntry
+	for _, e := range s.entries {
+		if e.key == "heavy" {
+			heavy = e
+		}
+	}
+	if len(s.entries) != 3 {
+		t.Errorf("entries = %d, want 3", len(s.entries))
+	}
+	if heavy == nil || heavy.count < 100 || heavy.count-heavy.error > 100 || heavy.denied != 100 {
+		t.Errorf("heavy = %+v, want count >= 100 with error bound", heavy)
+	}
+}
+
+func TestTopOffenders(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	top := NewTopOffenders(100, 10*time.Minute)
+	top.now = func() time.Time { return now }
+
+	for i := 0; i < 5; i++ {
+		top.Record("203.0.113.9", "curl", true)
+	}
+	top.Record("192.0.2.1", "Chrome 120 / Windows", false)
+
+	now = now.Add(5 * time.Minute)
+	for i := 0; i < 3; i++ {
+		top.Record("198.51.100.7", "python-requests", true)
+	}
+	top.Record("192.0.2.1", "Chrome 120 / Windows", false)
+
+	tests := []struct {
+		name   string
+		n      int
+		window time.Duration
+		ips    bool
+		want   []Offender
+	}{
+		{"最长窗口", 2, 0, true, []Offender{{Key: "203.0.113.9", Count: 5, Denied: 5}, {Key: "198.51.100.7", Count: 3, Denied: 3}}},
+		{"最近一分钟", 0, time.Minute, true, []Offender{{Key: "198.51.100.7", Count: 3, Denied: 3}, {Key: "192.0.2.1", Count: 1}}},
+		{"User-Agent", 1, 0, false, []Offender{{Key: "curl", Count: 5, Denied: 5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := top.TopUserAgents(tt.n, tt.window)
+			if tt.ips {
+				got = top.TopIPs(tt.n, tt.window)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("top = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+
+	now = now.Add(11 * time.Minute)
+	if got := top.TopIPs(10, 0); len(got) != 0 {
+		t.Errorf("窗口过期后 top = %+v, want empty", got)
+	}
+}
+
+func TestTopOffendersHandler(t *testing.T) {
+	top := NewTopOffenders(0, 0)
+	remove := AddDecisionHook(top)
+	defer remove()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "curl/8.4.0")
+	req.RemoteAddr = "203.0.113.9:1234"
+	BlockBotMiddleware(true)(handler).ServeHTTP(httptest.NewRecorder(), req)
+
+	tests := []struct {
+		name       string
+		query      string
+		wantStatus int
+	}{
+		{"默认参数", "", http.StatusOK},
+		{"指定数量和窗口", "?n=5&window=1m", http.StatusOK},
+		{"无效数量", "?n=abc", http.StatusBadRequest},
+		{"无效窗口", "?window=-1s", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			top.ServeHTTP(rr, httptest.NewRequest("GET", "/debug/offenders"+tt.query, nil))
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("status code = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var body struct {
+				Window     string     `json:"window"`
+				IPs        []Offender `json:"ips"`
+				UserAgents []Offender `json:"user_agents"`
+			}
+			if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if len(body.IPs) != 1 || body.IPs[0].Key != "203.0.113.9" || body.IPs[0].Denied != 1 {
+				t.Errorf("ips = %+v", body.IPs)
+			}
+			if len(body.UserAgents) != 1 || body.UserAgents[0].Key != "curl" {
+				t.Errorf("user_agents = %+v", body.UserAgents)
+			}
+		})
+	}
+}
+
+func TestNormalizeUserAgent(t *testing.T) {
+	tests := []struct {
+		name string
+		ua   string
+		want string
+	}{
+		{"空", "  ", "(empty)"},
+		{"机器人", "curl/8.4.0", "curl"},
+		{"浏览器", chrome120UA, "Chrome 120 / Windows"},
+		{"未知", "MyClient", "MyClient"},
+		{"截断时不拆分多字节字符", strings.Repeat("a", 127) + "中文客户端", strings.Repeat("a", 127)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeUserAgent(tt.ua); got != tt.want {
+				t.Errorf("NormalizeUserAgent(%q) = %q, want %q", tt.ua, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package uautil
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Option 配置 BlockBotMiddlewareWithOptions、BrowserOnlyMiddlewareWithOptions 等中间件拒绝请求的方式
+type Option func(*middlewareOptions)
+
+type middlewareOptions struct {
+	name           string
+	status         int
+	message        string
+	denyHandler    http.Handler
+	redirect       string
+	retryAfter     time.Duration
+	negotiate      bool
+	challenger     Challenger
+	minConsistency int
+	reportOnly     bool
+	report         func(r *http.Request, d Decision)
+	sampleRate     float64
+	hooks          []DecisionHook
+	tarpit         *Tarpit
+}
+
+// newMiddlewareOptions 返回中间件 name 默认返回 403 和 message 的配置
+func newMiddlewareOptions(name, message string, opts []Option) *middlewareOptions {
+	o := &middlewareOptions{name: name, status: http.StatusForbidden, message: message, sampleRate: 1}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// WithStatus 设置拒绝请求时的状态码，如 403、404、429、451，默认为 403
+// 与 WithRedirect 同时使用时，3xx 状态码作为重定向的状态码
+func WithStatus(code int) Option {
+	return func(o *middlewareOptions) {
+		if code > 0 {
+			o.status = code
+		}
+	}
+}
+
+// WithMessage 设置拒绝消息，为空时使用默认消息
+func WithMessage(message string) Option {
+	return func(o *middlewareOptions) {
+		if message != "" {
+			o.message = message
+		}
+	}
+}
+
+// WithDenyHandler 使用 h 处理被拒绝的请求，设置后忽略状态码、消息和重定向
+func WithDenyHandler(h http.Handler) Option {
+	return func(o *middlewareOptions) {
+		o.denyHandler = h
+	}
+}
+
+// WithRedirect 把被拒绝的请求重定向到 url，默认使用 302
+func WithRedirect(url string) Option {
+	return func(o *middlewareOptions) {
+		o.redirect = url
+	}
+}
+
+// WithRetryAfter 在拒绝响应中添加 Retry-After 请求头，通常与 WithStatus(429) 一起使用
+func WithRetryAfter(d time.Duration) Option {
+	return func(o *middlewareOptions) {
+		o.retryAfter = d
+	}
+}
+
+// WithContentNegotiation 根据 Accept 请求头返回 JSON、HTML 或纯文本的拒绝消息，默认总是返回纯文本
+// JSON 的格式为 {"error": "消息", "status": 403}
+func WithContentNegotiation() Option {
+	return func(o *middlewareOptions) {
+		o.negotiate = true
+	}
+}
+
+// WithChallenge 对被拒绝的请求发起挑战而不是直接拒绝，通过挑战的请求会被放行
+func WithChallenge(ch Challenger) Option {
+	return func(o *middlewareOptions) {
+		o.challenger = ch
+	}
+}
+
+// WithMinConsistency 要求浏览器的一致性得分（见 CheckConsistency）不低于 minScore，只对 BrowserOnlyMiddlewareWithOptions 有效
+func WithMinConsistency(minScore int) Option {
+	return func(o *middlewareOptions) {
+		o.minConsistency = minScore
+	}
+}
+
+// DetectMiddleware 创建一个自定义检测的中间件，detect 返回 true 的请求按 opts 配置的方式拒绝
+// 与内置中间件一样支持挑战、仅报告模式等选项，决定会通知决定钩子和指标记录器，用于在其他包中实现检测中间件
+// name 是 Decision 中的中间件名称，message 是默认的拒绝消息，describe 为被拒绝的请求填写决定详情，可以为 nil
+func DetectMiddleware(name, message string, detect func(r *http.Request) bool, describe func(r *http.Request, d *Decision), opts ...Option) func(http.Handler) http.Handler {
+	o := newMiddlewareOptions(name, message, opts)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			o.serve(w, r, next, detect, describe)
+		})
+	}
+}
+
+// serve 使用 detect 检测请求并放行或拒绝，describe 为被拒绝的请求填写决定的分类详情
+func (o *middlewareOptions) serve(w http.ResponseWriter, r *http.Request, next http.Handler, detect func(r *http.Request) bool, describe func(r *http.Request, d *Decision)) {
+	start := time.Now()
+	denied := detect(r)
+	latency := time.Since(start)
+
+	action := ActionAllow
+	if denied {
+		action = ActionBlock
+		if o.challenger != nil {
+			action = ActionChallenge
+			if !o.reportOnly && o.challenger.Passed(r) {
+				action = ActionAllow
+			}
+		}
+	}
+
+	o.record(r, action, denied, latency, func(d *Decision) {
+		if denied && describe != nil {
+			describe(r, d)
+		}
+	})
+
+	switch {
+	case action == ActionAllow || o.reportOnly:
+		next.ServeHTTP(w, r)
+	case action == ActionChallenge:
+		o.challenger.ServeChallenge(w, r)
+	default:
+		o.deny(w, r)
+	}
+}
+
+// record 报告本应拒绝的请求并通知决定钩子，denied 表示请求本应被拒绝，fill 填写决定的详情
+func (o *middlewareOptions) record(r *http.Request, action Action, denied bool, latency time.Duration, fill func(d *Decision)) {
+	report := denied && o.reportOnly && o.sampled()
+	if !report && !hasDecisionHooks(o.hooks) {
+		return
+	}
+
+	d := newDecision(r, o.name, action)
+	d.ReportOnly = denied && o.reportOnly
+	d.Latency = latency
+	fill(&d)
+
+	if report {
+		if o.report != nil {
+			o.report(r, d)
+		} else {
+			logDecision(r, d)
+		}
+	}
+	emitDecision(r, d, o.hooks)
+}
+
+// deny 按配置返回拒绝响应
+func (o *middlewareOptions) deny(w http.ResponseWriter, r *http.Request) {
+	if o.tarpit != nil && o.tarpit.serve(w, r) {
+		return
+	}
+	if o.retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int((o.retryAfter+time.Second-1)/time.Second)))
+	}
+	if o.denyHandler != nil {
+		o.denyHandler.ServeHTTP(w, r)
+		return
+	}
+	if o.redirect != "" {
+		code := http.StatusFound
+		if o.status >= 300 && o.status < 400 {
+			code = o.status
+		}
+		http.Redirect(w, r, o.redirect, code)
+		return
+	}
+	if !o.negotiate {
+		http.Error(w, o.message, o.status)
+		return
+	}
+
+	h := w.Header()
+	h.Set("X-Content-Type-Options", "nosniff")
+	switch negotiateFormat(r.Header.Get("Accept")) {
+	case "json":
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(o.status)
+		json.NewEncoder(w).Encode(struct {
+			Error  string `json:"error"`
+			Status int    `json:"status"`
+		}{o.message, o.status})
+	case "html":
+		h.Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(o.status)
+		msg := html.EscapeString(o.message)
+		w.Write([]byte("<!DOCTYPE html>\n<html><head><meta charset=\"utf-8\"><title>" + msg +
+			"</title></head><body><h1>" + strconv.Itoa(o.status) + " " + msg + "</h1></body></html>\n"))
+	default:
+		http.Error(w, o.message, o.status)
+	}
+}
+
+// negotiateFormat 根据 Accept 请求头选择 "json"、"html" 或 "text"，q 值相同时按出现顺序
+func negotiateFormat(accept string) string {
+	format, best := "text", 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(p), "q="); ok {
+				if f, err := strconv.ParseFloat(v, 64); err == nil {
+					q = f
+				}
+			}
+		}
+
+		var f string
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case "application/json", "application/problem+json":
+			f = "json"
+		case "text/html", "application/xhtml+xml":
+			f = "html"
+		case "text/plain":
+			f = "text"
+		default:
+			continue
+		}
+		if q > best {
+			format, best = f, q
+		}
+	}
+	return format
+}
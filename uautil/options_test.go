@@ -0,0 +1,109 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBlockBotMiddlewareWithOptions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	bot := func(accept string) *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", "curl/8.0")
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		return req
+	}
+
+	tests := []struct {
+		name            string
+		opts            []Option
+		request         *http.Request
+		wantStatusCode  int
+		wantContentType string
+		wantBody        string
+		wantHeader      map[string]string
+	}{
+		{"默认", nil, bot(""), http.StatusForbidden, "text/plain; charset=utf-8", "Bot access denied\n", nil},
+		{"放行浏览器", []Option{WithStatus(http.StatusNotFound)}, browserRequest(chrome120UA), http.StatusOK, "", "", nil},
+		{"自定义状态码和消息", []Option{WithStatus(http.StatusNotFound), WithMessage("Not Found")}, bot(""), http.StatusNotFound, "text/plain; charset=utf-8", "Not Found\n", nil},
+		{"Retry-After", []Option{WithStatus(http.StatusTooManyRequests), WithRetryAfter(90 * time.Second)}, bot(""), http.StatusTooManyRequests, "", "", map[string]string{"Retry-After": "90"}},
+		{"重定向", []Option{WithRedirect("/blocked")}, bot(""), http.StatusFound, "", "", map[string]string{"Location": "/blocked"}},
+		{"永久重定向", []Option{WithRedirect("/blocked"), WithStatus(http.StatusMovedPermanently)}, bot(""), http.StatusMovedPermanently, "", "", map[string]string{"Location": "/blocked"}},
+		{"自定义处理器", []Option{WithDenyHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnavailableForLegalReasons)
+		}))}, bot(""), http.StatusUnavailableForLegalReasons, "", "", nil},
+		{"协商JSON", []Option{WithContentNegotiation()}, bot("application/json"), http.StatusForbidden, "application/json; charset=utf-8", `{"error":"Bot access denied","status":403}` + "\n", nil},
+		{"协商HTML", []Option{WithContentNegotiation(), WithMessage("<blocked>")}, bot("text/html,application/xhtml+xml;q=0.9,*/*;q=0.8"), http.StatusForbidden, "text/html; charset=utf-8", "", nil},
+		{"按q值协商", []Option{WithContentNegotiation()}, bot("text/html;q=0.5, application/json"), http.StatusForbidden, "application/json; charset=utf-8", "", nil},
+		{"无法协商时返回纯文本", []Option{WithContentNegotiation()}, bot("image/png"), http.StatusForbidden, "text/plain; charset=utf-8", "Bot access denied\n", nil},
+		{"挑战", []Option{WithChallenge(cookieChallenger{})}, bot(""), http.StatusServiceUnavailable, "", "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			BlockBotMiddlewareWithOptions(true, tt.opts...)(handler).ServeHTTP(rr, tt.request)
+
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+			if tt.wantContentType != "" && rr.Header().Get("Content-Type") != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", rr.Header().Get("Content-Type"), tt.wantContentType)
+			}
+			if tt.wantBody != "" && rr.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", rr.Body.String(), tt.wantBody)
+			}
+			for k, v := range tt.wantHeader {
+				if got := rr.Header().Get(k); got != v {
+					t.Errorf("%s = %q, want %q", k, got, v)
+				}
+			}
+			if strings.Contains(rr.Body.String(), "<blocked>") {
+				t.Error("HTML 响应应转义消息")
+			}
+		})
+	}
+}
+
+func TestBrowserOnlyMiddlewareWithOptions(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	spoofed := func() *http.Request {
+		req := httptest.NewRequest("GET", "https://example.com/", nil)
+		req.Header.Set("User-Agent", "Mozilla/5.0")
+		return req
+	}
+	passed := spoofed()
+	passed.AddCookie(&http.Cookie{Name: "passed", Value: "1"})
+
+	tests := []struct {
+		name           string
+		opts           []Option
+		request        *http.Request
+		wantStatusCode int
+	}{
+		{"默认放行伪装请求", nil, spoofed(), http.StatusOK},
+		{"一致性得分过低", []Option{WithMinConsistency(70)}, spoofed(), http.StatusForbidden},
+		{"真实浏览器", []Option{WithMinConsistency(70)}, browserRequest(chrome120UA), http.StatusOK},
+		{"非浏览器返回404", []Option{WithStatus(http.StatusNotFound)}, httptest.NewRequest("GET", "/", nil), http.StatusNotFound},
+		{"通过挑战", []Option{WithMinConsistency(70), WithChallenge(cookieChallenger{})}, passed, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			BrowserOnlyMiddlewareWithOptions(tt.opts...)(handler).ServeHTTP(rr, tt.request)
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,266 @@
+package uautil
+
+import (
+	"net/http"
+	"strings"
+)
+
+// 浏览器名称
+const (
+	BrowserChrome    = "Chrome"
+	BrowserEdge      = "Edge"
+	BrowserFirefox   = "Firefox"
+	BrowserSafari    = "Safari"
+	BrowserOpera     = "Opera"
+	BrowserBrave     = "Brave"
+	BrowserVivaldi   = "Vivaldi"
+	BrowserYandex    = "Yandex"
+	BrowserSamsung   = "Samsung Internet"
+	BrowserIE        = "IE"
+	BrowserAndroid   = "Android Browser"
+	BrowserWeChat    = "WeChat"
+	BrowserQQ        = "QQ"
+	BrowserQQBrowser = "QQ Browser"
+	BrowserAlipay    = "Alipay"
+	BrowserDouyin    = "Douyin"
+	BrowserInstagram = "Instagram"
+	BrowserFacebook  = "Facebook"
+	BrowserUC        = "UC Browser"
+	BrowserQuark     = "Quark"
+	Browser360       = "360 Browser"
+	BrowserSogou     = "Sogou Browser"
+	BrowserBaidu     = "Baidu"
+)
+
+// 渲染引擎名称
+const (
+	EngineBlink    = "Blink"
+	EngineWebKit   = "WebKit"
+	EngineGecko    = "Gecko"
+	EngineTrident  = "Trident"
+	EngineEdgeHTML = "EdgeHTML"
+	EnginePresto   = "Presto"
+)
+
+// 操作系统名称
+const (
+	OSWindows      = "Windows"
+	OSWindowsPhone = "Windows Phone"
+	OSMacOS        = "macOS"
+	OSIOS          = "iOS"
+	OSAndroid      = "Android"
+	OSHarmonyOS    = "HarmonyOS"
+	OSChromeOS     = "ChromeOS"
+	OSLinux        = "Linux"
+	OSTizen        = "Tizen"
+	OSWebOS        = "webOS"
+	OSPlayStation  = "PlayStation"
+)
+
+// UserAgent 是解析 User-Agent 后得到的结构化信息
+// 无法识别的字段保持为空字符串
+type UserAgent struct {
+	Raw            string     // 原始 User-Agent
+	Browser        string     // 浏览器或应用内浏览器名称，如 Chrome、WeChat
+	BrowserVersion string     // 浏览器版本，如 "91.0.4472.124"
+	Engine         string     // 渲染引擎，如 Blink、WebKit、Gecko
+	EngineVersion  string     // 渲染引擎版本
+	OS             string     // 操作系统，如 Windows、iOS
+	OSVersion      string     // 操作系统版本，如 "10"、"14.6"
+	Device         DeviceType // 设备类型
+	Model          string     // 设备型号，仅来自 Sec-CH-UA-Model
+	Arch           string     // CPU 架构，仅来自 Sec-CH-UA-Arch
+	Bot            bool       // 是否为机器人（等同于 IsBotUserAgent(ua, false)）
+}
+
+// browserRule 描述一种浏览器的识别规则
+// 规则按顺序匹配，越具体的规则越靠前
+type browserRule struct {
+	name     string
+	match    func(ua string) bool
+	versions []string // 依次尝试提取版本号的特征
+}
+
+// 浏览器识别规则，顺序即优先级：
+//  1. 应用内浏览器（其 UA 中通常还包含 Chrome、Safari、MQQBrowser 等特征）
+//  2. 国产浏览器（多基于 Chromium 或 WebKit 内核）
+//  3. Chromium 衍生浏览器（Edge、Opera、Brave、Vivaldi 等，UA 中同样包含 chrome/）
+//  4. Firefox、Chrome、IE、Safari 等基础浏览器
+var browserRules = []browserRule{
+	// 应用内浏览器
+	{BrowserWeChat, containsAny("micromessenger/"), []string{"micromessenger/"}},
+	{BrowserAlipay, containsAny("alipayclient/"), []string{"alipayclient/"}},
+	{BrowserDouyin, containsAny("aweme_", "aweme/", "bytedancewebview/"), []string{"aweme_", "aweme/"}},
+	{BrowserInstagram, containsAny("instagram "), []string{"instagram "}},
+	{BrowserFacebook, containsAny("fban/", "fbav/", "fb_iab/"), []string{"fbav/"}},
+	{BrowserQQ, containsAny(" qq/"), []string{" qq/"}},
+
+	// 国产浏览器
+	{BrowserQQBrowser, containsAny("mqqbrowser/", "qqbrowser/"), []string{"mqqbrowser/", "qqbrowser/"}},
+	{BrowserUC, containsAny("ucbrowser/", "ubrowser/", "ucweb"), []string{"ucbrowser/", "ubrowser/"}},
+	{BrowserQuark, containsAny("quark/"), []string{"quark/"}},
+	{Browser360, containsAny("qihoobrowser/", "qhbrowser/", "360se", "360ee"), []string{"qihoobrowser/", "qhbrowser/"}},
+	{BrowserSogou, containsAny("metasr", "sogoumobilebrowser/"), []string{"sogoumobilebrowser/", "metasr "}},
+	{BrowserBaidu, containsAny("baiduboxapp/", "bidubrowser/", "baidubrowser/"), []string{"baiduboxapp/", "bidubrowser/", "baidubrowser/"}},
+
+	// Chromium 衍生浏览器
+	{BrowserSamsung, containsAny("samsungbrowser/"), []string{"samsungbrowser/"}},
+	{BrowserEdge, containsAny("edg/", "edga/", "edgios/", "edge/"), []string{"edg/", "edga/", "edgios/", "edge/"}},
+	{BrowserOpera, containsAny("opr/", "opt/", "opera"), []string{"opr/", "opt/", "version/", "opera/"}},
+	{BrowserYandex, containsAny("yabrowser/"), []string{"yabrowser/"}},
+	{BrowserVivaldi, containsAny("vivaldi/"), []string{"vivaldi/"}},
+	{BrowserBrave, containsAny("brave/"), []string{"brave/"}},
+
+	// 基础浏览器
+	{BrowserFirefox, containsAny("firefox/", "fxios/"), []string{"firefox/", "fxios/"}},
+	{BrowserChrome, containsAny("chrome/", "crios/"), []string{"chrome/", "crios/"}},
+	{BrowserIE, containsAny("msie ", "trident/"), []string{"msie ", "rv:"}},
+	{BrowserAndroid, func(ua string) bool {
+		return strings.Contains(ua, "android") && strings.Contains(ua, "version/") && strings.Contains(ua, "safari/")
+	}, []string{"version/"}},
+	{BrowserSafari, containsAny("safari/"), []string{"version/"}},
+}
+
+// Windows NT 内核版本与产品版本的对应关系
+var windowsVersions = map[string]string{
+	"10.0": "10",
+	"6.3":  "8.1",
+	"6.2":  "8",
+	"6.1":  "7",
+	"6.0":  "Vista",
+	"5.2":  "XP",
+	"5.1":  "XP",
+}
+
+// Parse 解析 User-Agent 字符串，返回浏览器、渲染引擎、操作系统和设备类型等信息
+func Parse(userAgent string) UserAgent {
+	result := UserAgent{Raw: userAgent}
+
+	ua := strings.ToLower(userAgent)
+	if ua == "" {
+		result.Bot = true
+		result.Device = DeviceBot
+		return result
+	}
+
+	result.Bot = IsBotUserAgent(userAgent, false)
+	result.Browser, result.BrowserVersion = parseBrowser(ua)
+	result.Engine, result.EngineVersion = parseEngine(ua)
+	result.OS, result.OSVersion = parseOS(ua)
+	result.Device = parseDevice(ua, result.OS, result.Bot)
+
+	return result
+}
+
+// ParseRequest 解析 HTTP 请求的 User-Agent
+// 请求带有 User-Agent Client Hints 时，使用 hints 中的真实版本号、平台和设备信息修正解析结果
+func ParseRequest(r *http.Request) UserAgent {
+	ua := Parse(r.UserAgent())
+	if ch, ok := ParseClientHints(r.Header); ok && !ua.Bot {
+		ua.mergeClientHints(ch)
+	}
+	return ua
+}
+
+// parseBrowser 按 browserRules 的优先级识别浏览器
+func parseBrowser(ua string) (string, string) {
+	for _, rule := range browserRules {
+		if !rule.match(ua) {
+			continue
+		}
+		for _, token := range rule.versions {
+			if v := versionAfter(ua, token); v != "" {
+				return rule.name, v
+			}
+		}
+		return rule.name, ""
+	}
+	return "", ""
+}
+
+// parseEngine 识别渲染引擎
+func parseEngine(ua string) (string, string) {
+	switch {
+	case containsAny("iphone", "ipad", "ipod")(ua):
+		// iOS 上的所有浏览器都必须使用 WebKit
+		return EngineWebKit, versionAfter(ua, "applewebkit/")
+	case strings.Contains(ua, "trident/"):
+		return EngineTrident, versionAfter(ua, "trident/")
+	case strings.Contains(ua, "edge/"):
+		return EngineEdgeHTML, versionAfter(ua, "edge/")
+	case strings.Contains(ua, "presto/"):
+		return EnginePresto, versionAfter(ua, "presto/")
+	case strings.Contains(ua, "chrome/"):
+		return EngineBlink, versionAfter(ua, "chrome/")
+	case strings.Contains(ua, "applewebkit/"):
+		return EngineWebKit, versionAfter(ua, "applewebkit/")
+	case strings.Contains(ua, "gecko/"):
+		return EngineGecko, versionAfter(ua, "rv:")
+	}
+	return "", ""
+}
+
+// parseOS 识别操作系统
+func parseOS(ua string) (string, string) {
+	switch {
+	case strings.Contains(ua, "windows phone"):
+		return OSWindowsPhone, versionAfter(ua, "windows phone ")
+	case strings.Contains(ua, "windows nt "):
+		return OSWindows, windowsVersions[versionAfter(ua, "windows nt ")]
+	case strings.Contains(ua, "iphone os "):
+		return OSIOS, versionAfter(ua, "iphone os ")
+	case containsAny("iphone", "ipad", "ipod")(ua):
+		return OSIOS, versionAfter(ua, "cpu os ")
+	case containsAny("harmonyos", "openharmony")(ua):
+		return OSHarmonyOS, versionAfter(ua, "openharmony ")
+	case strings.Contains(ua, "android"):
+		return OSAndroid, versionAfter(ua, "android ")
+	case strings.Contains(ua, "cros "):
+		return OSChromeOS, ""
+	case strings.Contains(ua, "mac os x"):
+		return OSMacOS, versionAfter(ua, "mac os x ")
+	case strings.Contains(ua, "playstation"):
+		return OSPlayStation, versionAfter(ua, "playstation ")
+	case strings.Contains(ua, "tizen"):
+		return OSTizen, versionAfter(ua, "tizen ")
+	case containsAny("web0s", "webos")(ua):
+		return OSWebOS, ""
+	case strings.Contains(ua, "linux"):
+		return OSLinux, ""
+	}
+	return "", ""
+}
+
+// versionAfter 提取 token 之后紧跟的版本号，下划线会被转换为点
+// 例如 versionAfter("iphone os 14_6 like", "iphone os ") 返回 "14.6"
+func versionAfter(ua, token string) string {
+	idx := strings.Index(ua, token)
+	if idx < 0 {
+		return ""
+	}
+
+	rest := ua[idx+len(token):]
+	end := 0
+	for end < len(rest) {
+		c := rest[end]
+		if (c < '0' || c > '9') && c != '.' && c != '_' {
+			break
+		}
+		end++
+	}
+
+	version := strings.Trim(strings.ReplaceAll(rest[:end], "_", "."), ".")
+	return version
+}
+
+// containsAny 返回一个判断字符串是否包含任一特征的函数
+func containsAny(patterns ...string) func(string) bool {
+	return func(s string) bool {
+		for _, p := range patterns {
+			if strings.Contains(s, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
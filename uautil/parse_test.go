@@ -0,0 +1,380 @@
+package uautil
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name           string
+		userAgent      string
+		browser        string
+		browserVersion string
+		engine         string
+		os             string
+		osVersion      string
+		device         DeviceType
+	}{
+		{
+			name:           "Chrome Windows",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+			browser:        BrowserChrome,
+			browserVersion: "91.0.4472.124",
+			engine:         EngineBlink,
+			os:             OSWindows,
+			osVersion:      "10",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Edge Chromium",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59",
+			browser:        BrowserEdge,
+			browserVersion: "91.0.864.59",
+			engine:         EngineBlink,
+			os:             OSWindows,
+			osVersion:      "10",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Legacy Edge",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19582",
+			browser:        BrowserEdge,
+			browserVersion: "18.19582",
+			engine:         EngineEdgeHTML,
+			os:             OSWindows,
+			osVersion:      "10",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Opera Chromium",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 OPR/77.0.4054.203",
+			browser:        BrowserOpera,
+			browserVersion: "77.0.4054.203",
+			engine:         EngineBlink,
+			os:             OSWindows,
+			osVersion:      "10",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Legacy Opera Presto",
+			userAgent:      "Opera/9.80 (Windows NT 6.1; U; en) Presto/2.12.388 Version/12.16",
+			browser:        BrowserOpera,
+			browserVersion: "12.16",
+			engine:         EnginePresto,
+			os:             OSWindows,
+			osVersion:      "7",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Vivaldi",
+			userAgent:      "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/92.0.4515.131 Safari/537.36 Vivaldi/4.1.2369.21",
+			browser:        BrowserVivaldi,
+			browserVersion: "4.1.2369.21",
+			engine:         EngineBlink,
+			os:             OSLinux,
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Firefox macOS",
+			userAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:89.0) Gecko/20100101 Firefox/89.0",
+			browser:        BrowserFirefox,
+			browserVersion: "89.0",
+			engine:         EngineGecko,
+			os:             OSMacOS,
+			osVersion:      "10.15",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Safari macOS",
+			userAgent:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Safari/605.1.15",
+			browser:        BrowserSafari,
+			browserVersion: "14.1.1",
+			engine:         EngineWebKit,
+			os:             OSMacOS,
+			osVersion:      "10.15.7",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "Safari iPhone",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Mobile/15E148 Safari/604.1",
+			browser:        BrowserSafari,
+			browserVersion: "14.1.1",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "14.6",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "Chrome iOS 使用 WebKit",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 14_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/91.0.4472.80 Mobile/15E148 Safari/604.1",
+			browser:        BrowserChrome,
+			browserVersion: "91.0.4472.80",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "14.6",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "Safari iPad",
+			userAgent:      "Mozilla/5.0 (iPad; CPU OS 12_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/12.1 Mobile/15E148 Safari/604.1",
+			browser:        BrowserSafari,
+			browserVersion: "12.1",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "12.2",
+			device:         DeviceTablet,
+		},
+		{
+			name:           "Chrome Android 手机",
+			userAgent:      "Mozilla/5.0 (Linux; Android 11; Pixel 5) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Mobile Safari/537.36",
+			browser:        BrowserChrome,
+			browserVersion: "91.0.4472.120",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "11",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "Chrome Android 平板",
+			userAgent:      "Mozilla/5.0 (Linux; Android 11; SM-T870) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.120 Safari/537.36",
+			browser:        BrowserChrome,
+			browserVersion: "91.0.4472.120",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "11",
+			device:         DeviceTablet,
+		},
+		{
+			name:           "Samsung Internet",
+			userAgent:      "Mozilla/5.0 (Linux; Android 11; SM-G991B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/14.2 Chrome/87.0.4280.141 Mobile Safari/537.36",
+			browser:        BrowserSamsung,
+			browserVersion: "14.2",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "11",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "IE 11",
+			userAgent:      "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			browser:        BrowserIE,
+			browserVersion: "11.0",
+			engine:         EngineTrident,
+			os:             OSWindows,
+			osVersion:      "7",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "微信 Android",
+			userAgent:      "Mozilla/5.0 (Linux; Android 10; V1838A Build/QP1A.190711.020; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/86.0.4240.99 XWEB/4317 MMWEBSDK/20220903 Mobile Safari/537.36 MMWEBID/4309 MicroMessenger/8.0.28.2240(0x28001C57) WeChat/arm64 Weixin NetType/WIFI Language/zh_CN ABI/arm64",
+			browser:        BrowserWeChat,
+			browserVersion: "8.0.28.2240",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "10",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "微信 iOS",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 15_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 MicroMessenger/8.0.20(0x18001442) NetType/WIFI Language/zh_CN",
+			browser:        BrowserWeChat,
+			browserVersion: "8.0.20",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "15.4",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "QQ 内置浏览器",
+			userAgent:      "Mozilla/5.0 (Linux; Android 12; M2102J2SC Build/SKQ1.211006.001; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/89.0.4389.72 MQQBrowser/6.2 TBS/046141 Mobile Safari/537.36 V1_AND_SQ_8.8.88_2770_YYB_D A_8088800 QQ/8.8.88.7830 NetType/WIFI WebP/0.3.0 Pixel/1080",
+			browser:        BrowserQQ,
+			browserVersion: "8.8.88.7830",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "12",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "QQ 浏览器",
+			userAgent:      "Mozilla/5.0 (Linux; U; Android 11; zh-cn; PDEM30 Build/RKQ1.200903.002) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/89.0.4389.72 MQQBrowser/12.1 Mobile Safari/537.36",
+			browser:        BrowserQQBrowser,
+			browserVersion: "12.1",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "11",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "支付宝",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 15_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 ChannelId(2) Ariver/1.1.0 AliApp(AP/10.2.70.6000) Nebula WK RVKType(0) AlipayDefined(nt:WIFI,ws:390|780|3.0) AlipayClient/10.2.70.6000 Language/zh-Hans Region/CN",
+			browser:        BrowserAlipay,
+			browserVersion: "10.2.70.6000",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "15.5",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "抖音",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 16_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 aweme_23.5.0 JsSdk/2.0 NetType/WIFI Channel/App Store ByteLocale/zh Region/CN",
+			browser:        BrowserDouyin,
+			browserVersion: "23.5.0",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "16.1",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "Instagram",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 16_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 253.0.0.23.114 (iPhone14,5; iOS 16_0; en_US; en-US; scale=3.00; 1170x2532; 399403987)",
+			browser:        BrowserInstagram,
+			browserVersion: "253.0.0.23.114",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "16.0",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "Facebook",
+			userAgent:      "Mozilla/5.0 (iPhone; CPU iPhone OS 15_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBDV/iPhone13,2;FBMD/iPhone;FBSN/iOS;FBSV/15.6;FBSS/3;FBID/phone;FBLC/en_US;FBOP/5;FBAV/382.0.0.43.111]",
+			browser:        BrowserFacebook,
+			browserVersion: "382.0.0.43.111",
+			engine:         EngineWebKit,
+			os:             OSIOS,
+			osVersion:      "15.6",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "UC 浏览器",
+			userAgent:      "Mozilla/5.0 (Linux; U; Android 10; zh-CN; V1916A Build/QP1A.190711.020) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/78.0.3904.108 UCBrowser/13.4.0.1306 Mobile Safari/537.36",
+			browser:        BrowserUC,
+			browserVersion: "13.4.0.1306",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "10",
+			device:         DeviceMobile,
+		},
+		{
+			name:           "夸克",
+			userAgent:      "Mozilla/5.0 (Linux; U; Android 12; zh-CN; 2201123C Build/SKQ1.211006.001) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/100.0.4896.58 Quark/6.2.2.246 Mobile Safari/537.36",
+			browser:        BrowserQuark,
+			browserVersion: "6.2.2.246",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "12",
+			device:         DeviceMobile,
+		},
+		{
+			name:      "360 浏览器",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.198 Safari/537.36 QIHU 360SE",
+			browser:   Browser360,
+			engine:    EngineBlink,
+			os:        OSWindows,
+			osVersion: "10",
+			device:    DeviceDesktop,
+		},
+		{
+			name:           "搜狗浏览器",
+			userAgent:      "Mozilla/5.0 (Windows NT 10.0; WOW64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/86.0.4240.198 Safari/537.36 SE 2.X MetaSr 1.0",
+			browser:        BrowserSogou,
+			browserVersion: "1.0",
+			engine:         EngineBlink,
+			os:             OSWindows,
+			osVersion:      "10",
+			device:         DeviceDesktop,
+		},
+		{
+			name:           "百度 App",
+			userAgent:      "Mozilla/5.0 (Linux; Android 10; ELE-AL00 Build/HUAWEIELE-AL0001; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/76.0.3809.89 Mobile Safari/537.36 T7/12.16 SP-engine/2.28.0 baiduboxapp/12.16.0.11 (Baidu; P1 10)",
+			browser:        BrowserBaidu,
+			browserVersion: "12.16.0.11",
+			engine:         EngineBlink,
+			os:             OSAndroid,
+			osVersion:      "10",
+			device:         DeviceMobile,
+		},
+		{
+			name:      "Smart TV",
+			userAgent: "Mozilla/5.0 (SMART-TV; LINUX; Tizen 6.0) AppleWebKit/537.36 (KHTML, like Gecko) 85.0.4183.93/6.0 TV Safari/537.36",
+			browser:   BrowserSafari,
+			engine:    EngineWebKit,
+			os:        OSTizen,
+			osVersion: "6.0",
+			device:    DeviceTV,
+		},
+		{
+			name:      "PlayStation",
+			userAgent: "Mozilla/5.0 (PlayStation 5 3.11) AppleWebKit/605.1.15 (KHTML, like Gecko)",
+			engine:    EngineWebKit,
+			os:        OSPlayStation,
+			osVersion: "5",
+			device:    DeviceConsole,
+		},
+		{
+			name:      "curl",
+			userAgent: "curl/7.68.0",
+			device:    DeviceBot,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Parse(tt.userAgent)
+			if got.Browser != tt.browser || got.BrowserVersion != tt.browserVersion {
+				t.Errorf("Browser = %q %q, want %q %q", got.Browser, got.BrowserVersion, tt.browser, tt.browserVersion)
+			}
+			if got.Engine != tt.engine {
+				t.Errorf("Engine = %q, want %q", got.Engine, tt.engine)
+			}
+			if got.OS != tt.os || got.OSVersion != tt.osVersion {
+				t.Errorf("OS = %q %q, want %q %q", got.OS, got.OSVersion, tt.os, tt.osVersion)
+			}
+			if got.Device != tt.device {
+				t.Errorf("Device = %q, want %q", got.Device, tt.device)
+			}
+		})
+	}
+}
+
+func TestParseBot(t *testing.T) {
+	if !Parse("").Bot {
+		t.Error("空 User-Agent 应该被标记为机器人")
+	}
+	if !Parse("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)").Bot {
+		t.Error("Googlebot 应该被标记为机器人")
+	}
+	if Parse("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36").Bot {
+		t.Error("Chrome 不应该被标记为机器人")
+	}
+}
+
+// 识别出浏览器的 UA 必须同时被 IsBrowserUserAgent 认为是浏览器
+func TestParseConsistentWithBrowserPatterns(t *testing.T) {
+	userAgents := []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36 Edg/91.0.864.59",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 15_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 MicroMessenger/8.0.20(0x18001442) NetType/WIFI Language/zh_CN",
+		"Opera/9.80 (Windows NT 6.1; U; en) Presto/2.12.388 Version/12.16",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0",
+	}
+
+	for _, ua := range userAgents {
+		info := Parse(ua)
+		if info.Browser == "" {
+			t.Errorf("Parse(%q) 未识别出浏览器", ua)
+		}
+		if !IsBrowserUserAgent(ua) {
+			t.Errorf("IsBrowserUserAgent(%q) = false, 但 Parse 识别为 %s", ua, info.Browser)
+		}
+	}
+}
+
+func TestParseRequest(t *testing.T) {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:89.0) Gecko/20100101 Firefox/89.0")
+
+	got := ParseRequest(req)
+	if got.Browser != BrowserFirefox || got.OS != OSLinux {
+		t.Errorf("ParseRequest() = %+v", got)
+	}
+}
@@ -0,0 +1,330 @@
+package uautil
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// RiskFactor 是风险评分的组成部分
+type RiskFactor string
+
+const (
+	// RiskBot UA 命中机器人特征，或冒充合法爬虫
+	RiskBot RiskFactor = "bot"
+	// RiskInconsistency UA 声称的浏览器与请求头不一致，按 CheckConsistency 扣除的分值折算
+	RiskInconsistency RiskFactor = "inconsistency"
+	// RiskAutomation 请求带有自动化工具的特征，按 DetectAutomation 的分值折算
+	RiskAutomation RiskFactor = "automation"
+	// RiskDatacenter 客户端 IP 属于数据中心（见 iputil.IsDatacenterIP）
+	RiskDatacenter RiskFactor = "datacenter"
+	// RiskForwarding 客户端 IP 请求头可疑（见 iputil.CheckForwarding）
+	RiskForwarding RiskFactor = "forwarding"
+	// RiskRate 客户端 IP 的请求频率超过限制
+	RiskRate RiskFactor = "rate"
+)
+
+// RiskWeights 是各风险因素的最高分值，为 0 时不计算该因素
+type RiskWeights struct {
+	Bot           int
+	Inconsistency int
+	Automation    int
+	Datacenter    int
+	Forwarding    int
+	Rate          int
+}
+
+// DefaultRiskWeights 是默认的风险因素分值
+var DefaultRiskWeights = RiskWeights{
+	Bot:           60,
+	Inconsistency: 40,
+	Automation:    40,
+	Datacenter:    25,
+	Forwarding:    20,
+	Rate:          30,
+}
+
+// RiskResult 是风险评分的结果
+type RiskResult struct {
+	Score   int                // 0-100，越高越可能是机器人
+	Factors map[RiskFactor]int // 各风险因素贡献的分值，只包含大于 0 的因素
+}
+
+func (r *RiskResult) add(factor RiskFactor, score int) {
+	if score <= 0 {
+		return
+	}
+	if r.Factors == nil {
+		r.Factors = make(map[RiskFactor]int)
+	}
+	r.Factors[factor] = score
+	r.Score += score
+	if r.Score > 100 {
+		r.Score = 100
+	}
+}
+
+// RiskScorer 把 UA、请求头、IP 和请求频率等信号合并为 0-100 的风险评分
+// RiskScorer 可以被多个 goroutine 同时使用
+type RiskScorer struct {
+	weights RiskWeights
+	rate    *rateCounter
+	trusted []string
+}
+
+// NewRiskScorer 创建风险评分器
+// 同一客户端 IP 在 window 内的请求超过 rateLimit 次时计入 RiskRate，rateLimit 为 0 时不统计请求频率
+func NewRiskScorer(weights RiskWeights, rateLimit int, window time.Duration) *RiskScorer {
+	s := &RiskScorer{weights: weights}
+	if rateLimit > 0 && window > 0 && weights.Rate > 0 {
+		s.rate = newRateCounter(rateLimit, window)
+	}
+	return s
+}
+
+// TrustForwardingHeaders 设置部署中可信的客户端 IP 请求头，RiskForwarding 只检查并比较这些请求头（见 iputil.CheckForwarding）
+// 未设置时只检查 iputil.GetClientIP 实际使用的请求头；应在处理请求之前调用
+func (s *RiskScorer) TrustForwardingHeaders(headers ...string) {
+	s.trusted = append([]string(nil), headers...)
+}
+
+// riskHeaderSignals 是 CheckConsistency 同样会检查的缺少请求头的自动化特征
+// 计算了 RiskInconsistency 时 RiskAutomation 不再计入这些特征，避免同一个缺少的请求头被扣两次分
+var riskHeaderSignals = map[AutomationSignal]bool{
+	SignalMissingAccept:         true,
+	SignalGenericAccept:         true,
+	SignalMissingAcceptLanguage: true,
+	SignalMissingAcceptEncoding: true,
+	SignalMissingSecFetch:       true,
+	SignalMissingClientHints:    true,
+}
+
+// Score 计算请求的风险评分
+// 通过 SetBotVerifier 设置的验证器确认的合法爬虫不计算机器人、一致性、自动化和数据中心因素，只计算请求头和频率；
+// 没有验证器或验证器无法判断时按普通请求计算，避免冒充 Googlebot 的请求得到 0 分
+func (s *RiskScorer) Score(r *http.Request) RiskResult {
+	var result RiskResult
+	w := s.weights
+	ip := iputil.GetClientIP(r)
+
+	legitimate := false
+	if bot := matchLegitimateBot(strings.ToLower(r.UserAgent())); bot != "" {
+		legitimate = botVerifier != nil && botVerifier.VerifyBot(r, bot) == VerifyPassed
+	}
+
+	if !legitimate {
+		if w.Bot > 0 && IsBot(r, true) {
+			result.add(RiskBot, w.Bot)
+		}
+		consistency := w.Inconsistency > 0 && IsBrowser(r)
+		if consistency {
+			result.add(RiskInconsistency, w.Inconsistency*(100-CheckConsistency(r).Score)/100)
+		}
+		if w.Automation > 0 {
+			result.add(RiskAutomation, w.Automation*automationRisk(DetectAutomation(r), consistency)/100)
+		}
+		if w.Datacenter > 0 && iputil.IsDatacenterIP(ip) {
+			result.add(RiskDatacenter, w.Datacenter)
+		}
+	}
+
+	if w.Forwarding > 0 && len(iputil.CheckForwarding(r, s.trusted...)) > 0 {
+		result.add(RiskForwarding, w.Forwarding)
+	}
+	if s.rate != nil {
+		if ok, _ := s.rate.allow(ip); !ok {
+			result.add(RiskRate, w.Rate)
+		}
+	}
+
+	return result
+}
+
+// automationRisk 返回自动化检测的分值，skipHeaders 为 true 时不计入 riskHeaderSignals 中的特征
+func automationRisk(result AutomationResult, skipHeaders bool) int {
+	if !skipHeaders {
+		return result.Score
+	}
+	score := 0
+	for _, signal := range result.Signals {
+		if !riskHeaderSignals[signal] {
+			score += automationWeights[signal]
+		}
+	}
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
+
+// Challenger 向可疑的客户端发起挑战（如 JavaScript 挑战、验证码）
+type Challenger interface {
+	// Passed 报告请求是否已经通过挑战
+	Passed(r *http.Request) bool
+	// ServeChallenge 向客户端返回挑战页面
+	ServeChallenge(w http.ResponseWriter, r *http.Request)
+}
+
+// RiskPolicy 根据风险评分决定如何处理请求
+// 评分不低于某个阈值时执行对应的处理方式，同时满足多个阈值时执行阈值最高的处理方式，阈值为 0 表示不启用
+type RiskPolicy struct {
+	Challenge int // 发起挑战的阈值
+	Throttle  int // 限制请求频率的阈值
+	Block     int // 拒绝请求的阈值
+
+	// Challenger 用于发起挑战，为 nil 时需要挑战的请求会被拒绝
+	Challenger Challenger
+	// ThrottleLimit 和 ThrottleWindow 是被限流的客户端 IP 的频率上限，默认每分钟 10 次
+	ThrottleLimit  int
+	ThrottleWindow time.Duration
+	// Message 是拒绝请求时返回的消息，默认为 "Access denied"
+	Message string
+}
+
+// DefaultRiskPolicy 是默认的风险处理策略
+var DefaultRiskPolicy = RiskPolicy{Challenge: 40, Throttle: 60, Block: 80}
+
+// Decide 返回风险评分对应的处理方式
+func (p RiskPolicy) Decide(score int) Action {
+	action, threshold := ActionAllow, 0
+	for _, t := range []struct {
+		action    Action
+		threshold int
+	}{
+		{ActionChallenge, p.Challenge},
+		{ActionThrottle, p.Throttle},
+		{ActionBlock, p.Block},
+	} {
+		if t.threshold > 0 && score >= t.threshold && t.threshold >= threshold {
+			action, threshold = t.action, t.threshold
+		}
+	}
+	return action
+}
+
+type riskKey struct{}
+
+// RiskFromContext 返回 RiskMiddleware 计算的风险评分
+func RiskFromContext(ctx context.Context) (RiskResult, bool) {
+	result, ok := ctx.Value(riskKey{}).(RiskResult)
+	return result, ok
+}
+
+// RiskMiddleware 创建一个中间件，按风险评分放行、挑战、限流或拒绝请求
+// 限流的请求超过频率上限时返回 429 和 Retry-After，被拒绝的请求返回 403
+// 放行的请求可以通过 RiskFromContext 获取风险评分，每个决定都会通知 AddDecisionHook 添加的钩子
+// opts 配置拒绝的方式（见 Option），WithChallenge 覆盖 policy.Challenger；限流的请求同样按 opts 处理，但状态码为 429，消息为 "Too many requests"
+func RiskMiddleware(scorer *RiskScorer, policy RiskPolicy, opts ...Option) func(http.Handler) http.Handler {
+	defaults := []Option{WithMessage(policy.Message)}
+	if policy.Challenger != nil {
+		defaults = append(defaults, WithChallenge(policy.Challenger))
+	}
+	o := newMiddlewareOptions("RiskMiddleware", "Access denied", append(defaults, opts...))
+
+	limit, window := policy.ThrottleLimit, policy.ThrottleWindow
+	if limit <= 0 {
+		limit = 10
+	}
+	if window <= 0 {
+		window = time.Minute
+	}
+	throttle := newRateCounter(limit, window)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			result := scorer.Score(r)
+			action := policy.Decide(result.Score)
+			latency := time.Since(start)
+
+			// 没有挑战方式时，需要挑战的请求被拒绝
+			if action == ActionChallenge && o.challenger == nil {
+				action = ActionBlock
+			}
+			denied := action != ActionAllow
+			if action == ActionChallenge && !o.reportOnly && o.challenger.Passed(r) {
+				action = ActionAllow
+			}
+
+			limited, retry := false, time.Duration(0)
+			if action == ActionThrottle {
+				var ok bool
+				ok, retry = throttle.allow(iputil.GetClientIP(r))
+				limited = !ok
+			}
+
+			o.record(r, action, denied, latency, func(d *Decision) {
+				d.Reason = "risk score " + strconv.Itoa(result.Score)
+				d.Risk = &result
+			})
+
+			switch {
+			case action == ActionAllow || o.reportOnly || (action == ActionThrottle && !limited):
+				next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), riskKey{}, result)))
+			case action == ActionChallenge:
+				o.challenger.ServeChallenge(w, r)
+			case action == ActionThrottle:
+				t := *o
+				t.status, t.message, t.retryAfter = http.StatusTooManyRequests, "Too many requests", retry
+				t.deny(w, r)
+			default:
+				o.deny(w, r)
+			}
+		})
+	}
+}
+
+// rateCounterMaxKeys 是 rateCounter 最多记录的客户端数量，超过时清理过期的记录
+const rateCounterMaxKeys = 65536
+
+// rateCounter 是按客户端 IP 计数的固定窗口频率限制器
+type rateCounter struct {
+	mu     sync.Mutex
+	limit  int
+	window time.Duration
+	counts map[string]*rateWindow
+	now    func() time.Time
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+func newRateCounter(limit int, window time.Duration) *rateCounter {
+	return &rateCounter{
+		limit:  limit,
+		window: window,
+		counts: make(map[string]*rateWindow),
+		now:    time.Now,
+	}
+}
+
+// allow 记录一次请求，报告 key 在当前窗口内是否仍未超过限制；超过时同时返回距离窗口结束的时间
+func (c *rateCounter) allow(key string) (bool, time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	w := c.counts[key]
+	if w == nil || now.Sub(w.start) >= c.window {
+		if w == nil && len(c.counts) >= rateCounterMaxKeys {
+			evictOldest(c.counts, rateCounterMaxKeys, now, func(w *rateWindow) time.Time {
+				return w.start.Add(c.window)
+			})
+		}
+		w = &rateWindow{start: now}
+		c.counts[key] = w
+	}
+	w.count++
+
+	if w.count > c.limit {
+		return false, c.window - now.Sub(w.start)
+	}
+	return true, 0
+}
@@ -0,0 +1,288 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+func TestRiskScorer(t *testing.T) {
+	if err := iputil.AddDatacenterRanges("test-cloud", "198.51.100.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	defer iputil.RemoveDatacenterRanges("test-cloud")
+
+	tests := []struct {
+		name        string
+		request     func() *http.Request
+		wantScore   int
+		wantFactors []RiskFactor
+	}{
+		{"真实Chrome", func() *http.Request { return browserRequest(chrome120UA) }, 0, nil},
+		{"curl", func() *http.Request {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", "curl/8.0")
+			return req
+		}, 60, []RiskFactor{RiskBot}},
+		{"伪装成浏览器的脚本", func() *http.Request {
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Header.Set("User-Agent", "Mozilla/5.0")
+			req.Header.Set("Accept", "*/*")
+			return req
+		}, 38, []RiskFactor{RiskInconsistency}},
+		{"数据中心的Chrome", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.RemoteAddr = "198.51.100.7:1234"
+			return req
+		}, 25, []RiskFactor{RiskDatacenter}},
+		{"伪造的转发请求头", func() *http.Request {
+			req := browserRequest(chrome120UA)
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			return req
+		}, 20, []RiskFactor{RiskForwarding}},
+		{"数据中心的未验证爬虫", func() *http.Request {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", "Googlebot/2.1")
+			req.RemoteAddr = "198.51.100.7:1234"
+			return req
+		}, 25, []RiskFactor{RiskDatacenter}},
+		{"缺少的请求头不重复计入自动化", func() *http.Request {
+			// 自动化因素只计入 HTTP/1.1 over TLS
+			req := httptest.NewRequest("GET", "https://example.com/", nil)
+			req.Header.Set("User-Agent", chrome120UA)
+			return req
+		}, 48, []RiskFactor{RiskInconsistency, RiskAutomation}},
+		{"数据中心的curl", func() *http.Request {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", "curl/8.0")
+			req.Header.Set("X-Real-IP", "198.51.100.7")
+			req.Header.Set("X-Forwarded-For", "10.0.0.1")
+			return req
+		}, 85, []RiskFactor{RiskBot, RiskDatacenter}},
+	}
+
+	scorer := NewRiskScorer(DefaultRiskWeights, 0, 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := scorer.Score(tt.request())
+			if got.Score != tt.wantScore {
+				t.Errorf("Score = %d, want %d (factors %v)", got.Score, tt.wantScore, got.Factors)
+			}
+			if len(got.Factors) != len(tt.wantFactors) {
+				t.Fatalf("Factors = %v, want %v", got.Factors, tt.wantFactors)
+			}
+			for _, f := range tt.wantFactors {
+				if got.Factors[f] == 0 {
+					t.Errorf("Factors = %v, want %s", got.Factors, f)
+				}
+			}
+		})
+	}
+}
+
+func TestRiskScorerTrustForwardingHeaders(t *testing.T) {
+	req := browserRequest(chrome120UA)
+	req.Header.Set("CF-Connecting-IP", "203.0.113.1")
+	req.Header.Set("X-Real-IP", "172.70.1.1")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	tests := []struct {
+		name    string
+		trusted []string
+		want    int
+	}{
+		{"默认只检查实际使用的请求头", nil, 0},
+		{"可信请求头一致", []string{"CF-Connecting-IP"}, 0},
+		{"可信请求头互相矛盾", []string{"CF-Connecting-IP", "X-Forwarded-For"}, DefaultRiskWeights.Forwarding},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scorer := NewRiskScorer(DefaultRiskWeights, 0, 0)
+			scorer.TrustForwardingHeaders(tt.trusted...)
+			if got := scorer.Score(req).Factors[RiskForwarding]; got != tt.want {
+				t.Errorf("Factors[RiskForwarding] = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRiskScorerFakeCrawler(t *testing.T) {
+	ranges := NewIPRangeVerifier()
+	if err := ranges.AddPrefix("googlebot", "66.249.64.0/19"); err != nil {
+		t.Fatal(err)
+	}
+	restore := SetBotVerifier(ranges)
+	defer restore()
+
+	scorer := NewRiskScorer(DefaultRiskWeights, 0, 0)
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Googlebot/2.1")
+
+	req.RemoteAddr = "66.249.66.1:1234"
+	if got := scorer.Score(req); got.Score != 0 {
+		t.Errorf("经过验证的爬虫 Score = %d, want 0", got.Score)
+	}
+
+	req.RemoteAddr = "203.0.113.5:1234"
+	if got := scorer.Score(req); got.Factors[RiskBot] != DefaultRiskWeights.Bot {
+		t.Errorf("冒充的爬虫 Factors = %v, want %s", got.Factors, RiskBot)
+	}
+
+	// 验证器无法判断时按普通请求计算
+	req.Header.Set("User-Agent", "Bingbot/2.0")
+	if err := iputil.AddDatacenterRanges("test-cloud", "203.0.113.0/24"); err != nil {
+		t.Fatal(err)
+	}
+	defer iputil.RemoveDatacenterRanges("test-cloud")
+	if got := scorer.Score(req); got.Factors[RiskDatacenter] != DefaultRiskWeights.Datacenter {
+		t.Errorf("未经验证的爬虫 Factors = %v, want %s", got.Factors, RiskDatacenter)
+	}
+}
+
+func TestRiskScorerRate(t *testing.T) {
+	scorer := NewRiskScorer(RiskWeights{Rate: 30}, 2, time.Minute)
+	now := time.Now()
+	scorer.rate.now = func() time.Time { return now }
+
+	req := browserRequest(chrome120UA)
+	for i, want := range []int{0, 0, 30, 30} {
+		if got := scorer.Score(req).Score; got != want {
+			t.Errorf("第 %d 次请求 Score = %d, want %d", i+1, got, want)
+		}
+	}
+
+	// 窗口结束后重新计数
+	now = now.Add(time.Minute)
+	if got := scorer.Score(req).Score; got != 0 {
+		t.Errorf("新窗口 Score = %d, want 0", got)
+	}
+}
+
+func TestRiskPolicyDecide(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy RiskPolicy
+		score  int
+		want   Action
+	}{
+		{"低于所有阈值", DefaultRiskPolicy, 39, ActionAllow},
+		{"挑战", DefaultRiskPolicy, 40, ActionChallenge},
+		{"限流", DefaultRiskPolicy, 75, ActionThrottle},
+		{"拒绝", DefaultRiskPolicy, 80, ActionBlock},
+		{"未启用挑战", RiskPolicy{Block: 50}, 45, ActionAllow},
+		{"挑战阈值高于拒绝", RiskPolicy{Challenge: 90, Block: 50}, 95, ActionChallenge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Decide(tt.score); got != tt.want {
+				t.Errorf("Decide(%d) = %v, want %v", tt.score, got, tt.want)
+			}
+		})
+	}
+}
+
+// cookieChallenger 在请求带有 passed Cookie 时视为通过挑战
+type cookieChallenger struct{}
+
+func (cookieChallenger) Passed(r *http.Request) bool {
+	_, err := r.Cookie("passed")
+	return err == nil
+}
+
+func (cookieChallenger) ServeChallenge(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, "challenge", http.StatusServiceUnavailable)
+}
+
+func TestRiskMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := RiskFromContext(r.Context()); !ok {
+			t.Error("RiskFromContext() 应返回风险评分")
+		}
+		w.WriteHeader(http.StatusOK)
+	})
+
+	curl := func() *http.Request {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", "curl/8.0")
+		return req
+	}
+	passed := func() *http.Request {
+		req := curl()
+		req.AddCookie(&http.Cookie{Name: "passed", Value: "1"})
+		return req
+	}
+	scorer := NewRiskScorer(DefaultRiskWeights, 0, 0)
+
+	tests := []struct {
+		name           string
+		policy         RiskPolicy
+		request        func() *http.Request
+		opts           []Option
+		wantStatusCode int
+	}{
+		{"放行浏览器", DefaultRiskPolicy, func() *http.Request { return browserRequest(chrome120UA) }, nil, http.StatusOK},
+		{"拒绝", RiskPolicy{Block: 50}, curl, nil, http.StatusForbidden},
+		{"没有Challenger时拒绝", RiskPolicy{Challenge: 50}, curl, nil, http.StatusForbidden},
+		{"发起挑战", RiskPolicy{Challenge: 50, Challenger: cookieChallenger{}}, curl, nil, http.StatusServiceUnavailable},
+		{"通过挑战", RiskPolicy{Challenge: 50, Challenger: cookieChallenger{}}, passed, nil, http.StatusOK},
+		{"选项中的挑战", RiskPolicy{Challenge: 50}, curl, []Option{WithChallenge(cookieChallenger{})}, http.StatusServiceUnavailable},
+		{"限流内放行", RiskPolicy{Throttle: 50}, curl, nil, http.StatusOK},
+		{"自定义状态码", RiskPolicy{Block: 50}, curl, []Option{WithStatus(http.StatusNotFound)}, http.StatusNotFound},
+		{"仅报告模式", RiskPolicy{Block: 50}, curl, []Option{WithReportOnly(func(*http.Request, Decision) {})}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			RiskMiddleware(scorer, tt.policy, tt.opts...)(handler).ServeHTTP(rr, tt.request())
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
+
+func TestRiskMiddlewareThrottle(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := RiskMiddleware(NewRiskScorer(DefaultRiskWeights, 0, 0), RiskPolicy{Throttle: 50, ThrottleLimit: 2, ThrottleWindow: time.Minute})(handler)
+
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("User-Agent", "curl/8.0")
+		rr := httptest.NewRecorder()
+		mw.ServeHTTP(rr, req)
+		if rr.Code != want {
+			t.Errorf("第 %d 次请求 status code = %d, want %d", i+1, rr.Code, want)
+		}
+		if want == http.StatusTooManyRequests && rr.Header().Get("Retry-After") == "" {
+			t.Error("限流响应应包含 Retry-After")
+		}
+	}
+}
+
+func TestRiskMiddlewareHooks(t *testing.T) {
+	var decisions []Decision
+	hook := WithDecisionHook(DecisionHookFunc(func(r *http.Request, d Decision) { decisions = append(decisions, d) }))
+	var reports []Decision
+	report := WithReportOnly(func(r *http.Request, d Decision) { reports = append(reports, d) })
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	mw := RiskMiddleware(NewRiskScorer(DefaultRiskWeights, 0, 0), RiskPolicy{Block: 50}, hook, report)(handler)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	mw.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(decisions) != 1 || decisions[0].Action != ActionBlock || !decisions[0].ReportOnly || decisions[0].Risk == nil {
+		t.Errorf("decisions = %+v", decisions)
+	}
+	if len(reports) != 1 {
+		t.Errorf("reports = %d, want 1", len(reports))
+	}
+}
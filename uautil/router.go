@@ -0,0 +1,232 @@
+package uautil
+
+import (
+	"net"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// MatchMode 是 PolicyRouter 选择路由的方式
+type MatchMode int
+
+const (
+	// FirstMatch 使用第一个匹配的路由
+	FirstMatch MatchMode = iota
+	// MostSpecific 使用最具体的匹配路由，同样具体时使用靠前的路由
+	// 具体程度依次比较：路径中非通配符的字符数、路径是否不含通配符、是否限定主机（精确主机优先于通配主机）、是否限定方法
+	MostSpecific
+)
+
+// Route 是 PolicyRouter 中的一条路由
+type Route struct {
+	// Path 为路径规则，为空时匹配所有路径
+	//   - 以 "/" 结尾时按前缀匹配，如 "/api/" 匹配 "/api/users"
+	//   - 不含通配符时匹配该路径及其子路径，如 "/checkout" 匹配 "/checkout" 和 "/checkout/pay"，不匹配 "/checkouts"
+	//   - 含通配符时按 glob 匹配整个路径："*" 匹配除 "/" 外的任意字符，"**" 匹配任意字符，"?" 匹配除 "/" 外的单个字符
+	Path string
+	// Methods 为请求方法，为空时匹配所有方法
+	Methods []string
+	// Host 为主机名（不含端口），"*.example.com" 匹配所有子域名，为空时匹配所有主机
+	Host string
+	// Middleware 为匹配的请求使用的中间件，如 BlockBotMiddleware(true)，为 nil 时直接放行
+	Middleware func(http.Handler) http.Handler
+}
+
+// PolicyRouter 按路径、方法和主机为请求选择不同的机器人、浏览器策略
+type PolicyRouter struct {
+	mode   MatchMode
+	routes []Route
+}
+
+// NewPolicyRouter 创建策略路由
+func NewPolicyRouter(mode MatchMode, routes ...Route) *PolicyRouter {
+	return &PolicyRouter{mode: mode, routes: append([]Route(nil), routes...)}
+}
+
+// Match 返回请求匹配的路由，没有匹配的路由时返回 false
+func (p *PolicyRouter) Match(r *http.Request) (Route, bool) {
+	if i := p.match(r); i >= 0 {
+		return p.routes[i], true
+	}
+	return Route{}, false
+}
+
+// match 返回匹配路由的下标，没有匹配时返回 -1
+func (p *PolicyRouter) match(r *http.Request) int {
+	best, bestScore := -1, routeScore{}
+	for i, route := range p.routes {
+		if !route.matches(r) {
+			continue
+		}
+		if p.mode == FirstMatch {
+			return i
+		}
+		if score := route.specificity(); best < 0 || score.greater(bestScore) {
+			best, bestScore = i, score
+		}
+	}
+	return best
+}
+
+// Middleware 创建一个中间件，对每个请求使用匹配路由的中间件，没有匹配的路由时直接放行
+func (p *PolicyRouter) Middleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		handlers := make([]http.Handler, len(p.routes))
+		for i, route := range p.routes {
+			handlers[i] = next
+			if route.Middleware != nil {
+				handlers[i] = route.Middleware(next)
+			}
+		}
+
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if i := p.match(r); i >= 0 {
+				handlers[i].ServeHTTP(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// PolicyRouterMiddleware 创建按路由选择策略的中间件，相当于 NewPolicyRouter(mode, routes...).Middleware()
+func PolicyRouterMiddleware(mode MatchMode, routes ...Route) func(http.Handler) http.Handler {
+	return NewPolicyRouter(mode, routes...).Middleware()
+}
+
+// matches 报告请求是否匹配路由
+func (route Route) matches(r *http.Request) bool {
+	if len(route.Methods) > 0 {
+		found := false
+		for _, m := range route.Methods {
+			if strings.EqualFold(m, r.Method) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if route.Host != "" {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if !matchHost(strings.ToLower(route.Host), strings.ToLower(host)) {
+			return false
+		}
+	}
+
+	return route.Path == "" || matchPath(route.Path, cleanPath(r.URL.Path))
+}
+
+// cleanPath 规范化请求路径，去掉 "//"、"." 和 ".."，保留末尾的 "/"
+// 避免 "//api/x"、"/docs/../api/x" 之类的路径绕过 "/api/" 的路由
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	if p[0] != '/' {
+		p = "/" + p
+	}
+	cleaned := path.Clean(p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// matchHost 匹配主机名，"*.example.com" 匹配所有子域名但不匹配 example.com
+func matchHost(pattern, host string) bool {
+	if suffix, ok := strings.CutPrefix(pattern, "*."); ok {
+		return strings.HasSuffix(host, "."+suffix)
+	}
+	return pattern == host
+}
+
+// matchPath 按 Route.Path 的规则匹配路径
+func matchPath(pattern, path string) bool {
+	if strings.ContainsAny(pattern, "*?") {
+		return matchGlob(pattern, path)
+	}
+	if strings.HasSuffix(pattern, "/") {
+		return strings.HasPrefix(path, pattern)
+	}
+	return path == pattern || strings.HasPrefix(path, pattern+"/")
+}
+
+// matchGlob 匹配 glob："*" 匹配除 "/" 外的任意字符，"**" 匹配任意字符，"?" 匹配除 "/" 外的单个字符
+func matchGlob(pattern, s string) bool {
+	for len(pattern) > 0 {
+		switch {
+		case strings.HasPrefix(pattern, "**"):
+			rest := strings.TrimLeft(pattern, "*")
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(rest, s[i:]) {
+					return true
+				}
+			}
+			return false
+		case pattern[0] == '*':
+			rest := pattern[1:]
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(rest, s[i:]) {
+					return true
+				}
+				if i < len(s) && s[i] == '/' {
+					break
+				}
+			}
+			return false
+		case len(s) == 0:
+			return false
+		case pattern[0] == '?':
+			if s[0] == '/' {
+				return false
+			}
+		case pattern[0] != s[0]:
+			return false
+		}
+		pattern, s = pattern[1:], s[1:]
+	}
+	return len(s) == 0
+}
+
+// routeScore 是路由的具体程度
+type routeScore struct {
+	literal int  // 路径中非通配符的字符数
+	exact   bool // 路径不含通配符
+	host    int  // 0 不限定，1 通配主机，2 精确主机
+	method  bool // 限定了方法
+}
+
+func (route Route) specificity() routeScore {
+	s := routeScore{
+		literal: len(route.Path) - strings.Count(route.Path, "*") - strings.Count(route.Path, "?"),
+		exact:   !strings.ContainsAny(route.Path, "*?"),
+		method:  len(route.Methods) > 0,
+	}
+	switch {
+	case strings.HasPrefix(route.Host, "*."):
+		s.host = 1
+	case route.Host != "":
+		s.host = 2
+	}
+	return s
+}
+
+func (s routeScore) greater(o routeScore) bool {
+	if s.literal != o.literal {
+		return s.literal > o.literal
+	}
+	if s.exact != o.exact {
+		return s.exact
+	}
+	if s.host != o.host {
+		return s.host > o.host
+	}
+	return s.method && !o.method
+}
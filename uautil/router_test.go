@@ -0,0 +1,153 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		{"/robots.txt", "/robots.txt", true},
+		{"/robots.txt", "/robots.txt.bak", false},
+		{"/checkout", "/checkout", true},
+		{"/checkout", "/checkout/pay", true},
+		{"/checkout", "/checkouts", false},
+		{"/api/", "/api/users", true},
+		{"/api/", "/api", false},
+		{"/docs/*/api", "/docs/v1/api", true},
+		{"/docs/*/api", "/docs/v1/v2/api", false},
+		{"/docs/**", "/docs/v1/v2/api", true},
+		{"/**/*.json", "/a/b/c.json", true},
+		{"/**/*.json", "/a/b/c.xml", false},
+		{"/sitemap*.xml", "/sitemap-1.xml", true},
+		{"/sitemap?.xml", "/sitemap1.xml", true},
+		{"/sitemap?.xml", "/sitemap/.xml", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.path, func(t *testing.T) {
+			if got := matchPath(tt.pattern, tt.path); got != tt.want {
+				t.Errorf("matchPath(%q, %q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCleanPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"", "/"},
+		{"/", "/"},
+		{"//api/x", "/api/x"},
+		{"/docs/../api/x", "/api/x"},
+		{"/api/./docs/", "/api/docs/"},
+		{"api", "/api"},
+		{"/../..", "/"},
+	}
+
+	for _, tt := range tests {
+		if got := cleanPath(tt.path); got != tt.want {
+			t.Errorf("cleanPath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestPolicyRouterMatch(t *testing.T) {
+	routes := []Route{
+		{Path: "/", Middleware: BlockBotMiddleware(true)},
+		{Path: "/api/"},
+		{Path: "/api/docs/", Methods: []string{"GET"}},
+		{Path: "/api/docs/", Methods: []string{"GET"}, Host: "*.example.com"},
+		{Path: "/api/docs/", Methods: []string{"GET"}, Host: "docs.example.com"},
+		{Path: "/**/*.xml"},
+	}
+
+	tests := []struct {
+		name     string
+		mode     MatchMode
+		method   string
+		target   string
+		wantPath string
+		wantHost string
+	}{
+		{"第一个匹配", FirstMatch, "GET", "http://docs.example.com/api/docs/x", "/", ""},
+		{"最长前缀", MostSpecific, "POST", "http://example.com/api/users", "/api/", ""},
+		{"方法不匹配", MostSpecific, "POST", "http://docs.example.com/api/docs/x", "/api/", ""},
+		{"精确主机优先", MostSpecific, "GET", "http://docs.example.com:8080/api/docs/x", "/api/docs/", "docs.example.com"},
+		{"通配主机", MostSpecific, "GET", "http://www.example.com/api/docs/x", "/api/docs/", "*.example.com"},
+		{"通配主机不匹配顶级域名", MostSpecific, "GET", "http://example.com/api/docs/x", "/api/docs/", ""},
+		{"glob", MostSpecific, "GET", "http://example.com/feeds/sitemap.xml", "/**/*.xml", ""},
+		{"重复的斜杠", MostSpecific, "POST", "http://example.com//api/users", "/api/", ""},
+		{"上级目录", MostSpecific, "POST", "http://example.com/docs/../api/users", "/api/", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			route, ok := NewPolicyRouter(tt.mode, routes...).Match(req)
+			if !ok {
+				t.Fatal("Match() 应匹配路由")
+			}
+			if route.Path != tt.wantPath || route.Host != tt.wantHost {
+				t.Errorf("Match() = {Path: %q, Host: %q}, want {Path: %q, Host: %q}", route.Path, route.Host, tt.wantPath, tt.wantHost)
+			}
+		})
+	}
+
+	if _, ok := NewPolicyRouter(FirstMatch, Route{Path: "/api/"}).Match(httptest.NewRequest("GET", "/", nil)); ok {
+		t.Error("没有匹配的路由时应返回 false")
+	}
+}
+
+func TestPolicyRouterMiddleware(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	mw := PolicyRouterMiddleware(MostSpecific,
+		Route{Path: "/", Middleware: BlockBotMiddleware(true)},
+		Route{Path: "/robots.txt"},
+		Route{Path: "/sitemap.xml"},
+		Route{Path: "/.well-known/"},
+		Route{Path: "/api/docs/", Methods: []string{"GET", "HEAD"}},
+		Route{Path: "/api/", Middleware: BlockBotMiddleware(false)},
+		Route{Path: "/checkout", Middleware: BrowserOnlyMiddleware()},
+	)(handler)
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		userAgent      string
+		wantStatusCode int
+	}{
+		{"机器人访问robots.txt", "GET", "/robots.txt", "curl/8.0", http.StatusOK},
+		{"机器人访问sitemap", "GET", "/sitemap.xml", "python-requests/2.31", http.StatusOK},
+		{"机器人访问well-known", "GET", "/.well-known/security.txt", "curl/8.0", http.StatusOK},
+		{"机器人访问API文档", "GET", "/api/docs/index.html", "curl/8.0", http.StatusOK},
+		{"机器人提交API文档", "POST", "/api/docs/index.html", "curl/8.0", http.StatusForbidden},
+		{"搜索引擎访问API", "GET", "/api/users", "Googlebot/2.1", http.StatusForbidden},
+		{"搜索引擎访问页面", "GET", "/about", "Googlebot/2.1", http.StatusOK},
+		{"机器人访问页面", "GET", "/about", "curl/8.0", http.StatusForbidden},
+		{"搜索引擎访问结账", "GET", "/checkout/pay", "Googlebot/2.1", http.StatusForbidden},
+		{"浏览器访问结账", "GET", "/checkout/pay", chrome120UA, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rr := httptest.NewRecorder()
+			mw.ServeHTTP(rr, req)
+			if rr.Code != tt.wantStatusCode {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,567 @@
+package uautil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// RuleSetVersion 是当前支持的规则文件版本
+const RuleSetVersion = 1
+
+// MatchType 是规则的匹配方式，匹配时均不区分大小写
+type MatchType string
+
+// 匹配方式
+const (
+	MatchContains MatchType = "contains" // 包含（默认）
+	MatchPrefix   MatchType = "prefix"   // 前缀
+	MatchExact    MatchType = "exact"    // 完全相同
+	MatchRegex    MatchType = "regex"    // 正则表达式
+)
+
+// RuleKind 是规则的种类
+type RuleKind string
+
+// 规则种类
+const (
+	RuleKindBot     RuleKind = "bot"     // 机器人特征（默认）
+	RuleKindBrowser RuleKind = "browser" // 浏览器特征
+)
+
+// RuleMode 是规则集与内置特征的合并方式
+type RuleMode string
+
+// 合并方式
+const (
+	// RuleModeMerge 先匹配规则集，再匹配内置特征（默认）
+	RuleModeMerge RuleMode = "merge"
+	// RuleModeReplace 只使用规则集，忽略全部内置特征和通过 Add* 函数添加的特征
+	RuleModeReplace RuleMode = "replace"
+)
+
+// Rule 是规则集中的一条规则
+//
+// 机器人规则的 Action 为 "allow" 时表示合法爬虫（相当于 AddLegitimateBot），
+// 为 "deny" 或 "block" 时表示需要拦截的机器人（相当于 AddCustomBotPattern），默认为 "deny"。
+// 浏览器规则的 Action 为 "deny" 或 "block" 时表示命中的 UA 不是浏览器，默认为 "allow"。
+// Disabled 为 true 时，规则不生效，并且会禁用 Pattern 相同的内置特征。
+type Rule struct {
+	Name     string      `json:"name,omitempty"`
+	Pattern  string      `json:"pattern"`
+	Match    MatchType   `json:"match,omitempty"`
+	Kind     RuleKind    `json:"kind,omitempty"`
+	Category BotCategory `json:"category,omitempty"`
+	Action   string      `json:"action,omitempty"`
+	URL      string      `json:"url,omitempty"`
+	Disabled bool        `json:"disabled,omitempty"`
+}
+
+// RuleSet 是可以从 JSON 或 YAML 文件加载的规则集
+type RuleSet struct {
+	Version int      `json:"version"`
+	Mode    RuleMode `json:"mode,omitempty"`
+	Rules   []Rule   `json:"rules"`
+}
+
+// RuleError 是规则集的校验错误
+type RuleError struct {
+	Line  int    // 所在行，未知时为 0
+	Field string // 出错的字段，如 "rules[3].match"
+	Msg   string
+}
+
+func (e *RuleError) Error() string {
+	var b strings.Builder
+	if e.Line > 0 {
+		fmt.Fprintf(&b, "line %d: ", e.Line)
+	}
+	if e.Field != "" {
+		b.WriteString(e.Field)
+		b.WriteString(": ")
+	}
+	b.WriteString(e.Msg)
+	return b.String()
+}
+
+// ruleLocation 记录规则及其字段所在的行
+type ruleLocation struct {
+	line   int
+	fields map[string]int
+}
+
+func (l ruleLocation) lineOf(field string) int {
+	if n, ok := l.fields[field]; ok {
+		return n
+	}
+	return l.line
+}
+
+// ParseRuleSet 解析并校验 JSON 或 YAML 格式的规则集，以 "{" 开头的内容按 JSON 解析
+func ParseRuleSet(data []byte) (*RuleSet, error) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return parseRuleSetJSON(data)
+	}
+	return parseRuleSetYAML(data)
+}
+
+// LoadRuleSetFile 从文件加载并校验规则集，错误信息中包含文件名和行号
+func LoadRuleSetFile(path string) (*RuleSet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rs, err := ParseRuleSet(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return rs, nil
+}
+
+// Validate 校验规则集
+func (rs *RuleSet) Validate() error {
+	return rs.validate(0, nil)
+}
+
+func (rs *RuleSet) validate(versionLine int, locs []ruleLocation) error {
+	switch {
+	case rs.Version == 0:
+		return &RuleError{Line: versionLine, Field: "version", Msg: "required"}
+	case rs.Version < 0 || rs.Version > RuleSetVersion:
+		return &RuleError{Line: versionLine, Field: "version", Msg: fmt.Sprintf("unsupported version %d", rs.Version)}
+	}
+	switch rs.Mode {
+	case "", RuleModeMerge, RuleModeReplace:
+	default:
+		return &RuleError{Field: "mode", Msg: fmt.Sprintf("unknown mode %q", rs.Mode)}
+	}
+
+	for i, rule := range rs.Rules {
+		var loc ruleLocation
+		if i < len(locs) {
+			loc = locs[i]
+		}
+		if field, msg := rule.check(); field != "" {
+			return &RuleError{Line: loc.lineOf(field), Field: fmt.Sprintf("rules[%d].%s", i, field), Msg: msg}
+		}
+	}
+	return nil
+}
+
+// check 校验单条规则，返回出错的字段和原因
+func (r Rule) check() (field, msg string) {
+	if strings.TrimSpace(r.Pattern) == "" {
+		return "pattern", "required"
+	}
+	switch r.Match {
+	case "", MatchContains, MatchPrefix, MatchExact:
+	case MatchRegex:
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return "pattern", err.Error()
+		}
+	default:
+		return "match", fmt.Sprintf("unknown match type %q", r.Match)
+	}
+	switch r.Kind {
+	case "", RuleKindBot, RuleKindBrowser:
+	default:
+		return "kind", fmt.Sprintf("unknown kind %q", r.Kind)
+	}
+	if r.Category != "" && !isValidCategory(r.Category) {
+		return "category", fmt.Sprintf("unknown category %q", r.Category)
+	}
+	switch r.Action {
+	case "", "allow", "deny", "block":
+	default:
+		return "action", fmt.Sprintf("unknown action %q, want \"allow\", \"deny\" or \"block\"", r.Action)
+	}
+	return "", ""
+}
+
+func parseRuleSetJSON(data []byte) (*RuleSet, error) {
+	// 先检查语法，json.SyntaxError 中的偏移量是相对于整个文档的
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var se *json.SyntaxError
+		if errors.As(err, &se) {
+			return nil, &RuleError{Line: lineAt(data, se.Offset), Msg: strings.TrimPrefix(err.Error(), "json: ")}
+		}
+		return nil, &RuleError{Msg: err.Error()}
+	}
+	if _, ok := raw.(map[string]interface{}); !ok {
+		return nil, &RuleError{Line: 1, Msg: "rule set must be an object"}
+	}
+
+	rs := &RuleSet{}
+	var (
+		versionLine int
+		locs        []ruleLocation
+	)
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	dec.Token() // {
+	for dec.More() {
+		tok, _ := dec.Token()
+		key, _ := tok.(string)
+		keyLine := lineAt(data, dec.InputOffset())
+
+		var err error
+		switch key {
+		case "version":
+			versionLine = keyLine
+			err = decodeJSONField(dec, data, &rs.Version, "version")
+		case "mode":
+			err = decodeJSONField(dec, data, &rs.Mode, "mode")
+		case "rules":
+			if tok, _ := dec.Token(); tok != json.Delim('[') {
+				return nil, &RuleError{Line: keyLine, Field: "rules", Msg: "must be an array"}
+			}
+			for i := 0; dec.More(); i++ {
+				var rule Rule
+				start := skipJSONSeparators(data, dec.InputOffset())
+				if err := decodeJSONField(dec, data, &rule, fmt.Sprintf("rules[%d]", i)); err != nil {
+					return nil, err
+				}
+				rs.Rules = append(rs.Rules, rule)
+				locs = append(locs, ruleLocation{line: lineAt(data, start)})
+			}
+			dec.Token() // ]
+		default:
+			return nil, &RuleError{Line: keyLine, Field: key, Msg: "unknown field"}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := rs.validate(versionLine, locs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+// decodeJSONField 解码下一个值，把解码错误转换为带行号的 RuleError
+func decodeJSONField(dec *json.Decoder, data []byte, v interface{}, field string) error {
+	start := skipJSONSeparators(data, dec.InputOffset())
+	err := dec.Decode(v)
+	if err == nil {
+		return nil
+	}
+
+	var te *json.UnmarshalTypeError
+	if errors.As(err, &te) {
+		if te.Field != "" {
+			field += "." + te.Field
+		}
+		return &RuleError{Line: lineAt(data, start+te.Offset), Field: field, Msg: fmt.Sprintf("cannot use %s as %s", te.Value, te.Type)}
+	}
+	return &RuleError{Line: lineAt(data, start), Field: field, Msg: strings.TrimPrefix(err.Error(), "json: ")}
+}
+
+// skipJSONSeparators 跳过 offset 处的空白、逗号和冒号，返回下一个值的起始位置
+func skipJSONSeparators(data []byte, offset int64) int64 {
+	for offset < int64(len(data)) && strings.IndexByte(" \t\r\n,:", data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// lineAt 返回 offset 所在的行号（从 1 开始）
+func lineAt(data []byte, offset int64) int {
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+	return bytes.Count(data[:offset], []byte("\n")) + 1
+}
+
+func parseRuleSetYAML(data []byte) (*RuleSet, error) {
+	root, err := parseYAML(data)
+	if err != nil {
+		var ye *yamlError
+		if errors.As(err, &ye) {
+			return nil, &RuleError{Line: ye.Line, Msg: ye.Msg}
+		}
+		return nil, err
+	}
+	if root.kind != yamlMap {
+		return nil, &RuleError{Line: root.line, Msg: "rule set must be a mapping"}
+	}
+
+	rs := &RuleSet{}
+	var locs []ruleLocation
+	for _, key := range root.keys {
+		node, line := root.fields[key], root.lines[key]
+		switch key {
+		case "version":
+			v, err := strconv.Atoi(node.value)
+			if node.kind != yamlScalar || err != nil {
+				return nil, &RuleError{Line: line, Field: "version", Msg: "must be an integer"}
+			}
+			rs.Version = v
+		case "mode":
+			if node.kind != yamlScalar {
+				return nil, &RuleError{Line: line, Field: "mode", Msg: "must be a string"}
+			}
+			rs.Mode = RuleMode(node.value)
+		case "rules":
+			if node.kind == yamlScalar && node.value == "" {
+				continue
+			}
+			if node.kind != yamlSeq {
+				return nil, &RuleError{Line: line, Field: "rules", Msg: "must be a list"}
+			}
+			for i, item := range node.items {
+				rule, loc, err := yamlRule(item, i)
+				if err != nil {
+					return nil, err
+				}
+				rs.Rules = append(rs.Rules, rule)
+				locs = append(locs, loc)
+			}
+		default:
+			return nil, &RuleError{Line: line, Field: key, Msg: "unknown field"}
+		}
+	}
+
+	if err := rs.validate(root.lines["version"], locs); err != nil {
+		return nil, err
+	}
+	return rs, nil
+}
+
+func yamlRule(node *yamlNode, i int) (Rule, ruleLocation, error) {
+	var rule Rule
+	loc := ruleLocation{line: node.line, fields: node.lines}
+	if node.kind != yamlMap {
+		return rule, loc, &RuleError{Line: node.line, Field: fmt.Sprintf("rules[%d]", i), Msg: "must be a mapping"}
+	}
+
+	for _, key := range node.keys {
+		field := fmt.Sprintf("rules[%d].%s", i, key)
+		value, err := node.fields[key].str()
+		if err != nil {
+			return rule, loc, &RuleError{Line: node.lines[key], Field: field, Msg: "must be a scalar"}
+		}
+		switch key {
+		case "name":
+			rule.Name = value
+		case "pattern":
+			rule.Pattern = value
+		case "match":
+			rule.Match = MatchType(value)
+		case "kind":
+			rule.Kind = RuleKind(value)
+		case "category":
+			rule.Category = BotCategory(value)
+		case "action":
+			rule.Action = value
+		case "url":
+			rule.URL = value
+		case "disabled":
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return rule, loc, &RuleError{Line: node.lines[key], Field: field, Msg: fmt.Sprintf("invalid boolean %q", value)}
+			}
+			rule.Disabled = b
+		default:
+			return rule, loc, &RuleError{Line: node.lines[key], Field: field, Msg: "unknown field"}
+		}
+	}
+	return rule, loc, nil
+}
+
+// WriteJSON 把规则集以 JSON 格式写入 w
+func (rs *RuleSet) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(rs)
+}
+
+// WriteYAML 把规则集以 YAML 格式写入 w，输出可以被 ParseRuleSet 读取
+func (rs *RuleSet) WriteYAML(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "version: %d\n", rs.Version)
+	if rs.Mode != "" {
+		fmt.Fprintf(bw, "mode: %s\n", rs.Mode)
+	}
+	bw.WriteString("rules:\n")
+	for _, r := range rs.Rules {
+		prefix := "  - "
+		field := func(key, value string) {
+			if value == "" {
+				return
+			}
+			fmt.Fprintf(bw, "%s%s: %s\n", prefix, key, strconv.Quote(value))
+			prefix = "    "
+		}
+		field("name", r.Name)
+		field("pattern", r.Pattern)
+		field("match", string(r.Match))
+		field("kind", string(r.Kind))
+		field("category", string(r.Category))
+		field("action", r.Action)
+		field("url", r.URL)
+		if r.Disabled {
+			field("disabled", "true")
+		}
+	}
+	return bw.Flush()
+}
+
+// compiledRule 是编译后的规则
+type compiledRule struct {
+	Rule
+	pattern string // 小写的特征
+	re      *regexp.Regexp
+	allow   bool
+}
+
+func (c *compiledRule) match(ua string) bool {
+	switch c.Match {
+	case MatchPrefix:
+		return strings.HasPrefix(ua, c.pattern)
+	case MatchExact:
+		return ua == c.pattern
+	case MatchRegex:
+		return c.re.MatchString(ua)
+	}
+	return strings.Contains(ua, c.pattern)
+}
+
+// verifyKey 返回用于 BotVerifier 的爬虫名称
+func (c *compiledRule) verifyKey() string {
+	if c.Match == "" || c.Match == MatchContains {
+		return c.pattern
+	}
+	return strings.ToLower(c.Name)
+}
+
+// ruleTable 是当前生效的规则集
+type ruleTable struct {
+	set      *RuleSet
+	replace  bool
+	bots     []*compiledRule
+	browsers []*compiledRule
+	disabled map[string]bool // 被禁用的内置特征
+}
+
+var activeRules atomic.Pointer[ruleTable]
+
+// ApplyRuleSet 校验并启用规则集，替换之前启用的规则集
+// 返回的函数可用于恢复之前的规则集
+func ApplyRuleSet(rs *RuleSet) (func(), error) {
+	if err := rs.Validate(); err != nil {
+		return nil, err
+	}
+
+	t := &ruleTable{set: rs, replace: rs.Mode == RuleModeReplace, disabled: make(map[string]bool)}
+	for _, r := range rs.Rules {
+		if r.Disabled {
+			t.disabled[strings.ToLower(r.Pattern)] = true
+			continue
+		}
+
+		c := &compiledRule{Rule: r, pattern: strings.ToLower(r.Pattern)}
+		if r.Match == MatchRegex {
+			c.re = regexp.MustCompile("(?i)" + r.Pattern)
+		}
+		if r.Kind == RuleKindBrowser {
+			c.allow = r.Action != "deny" && r.Action != "block"
+			t.browsers = append(t.browsers, c)
+		} else {
+			c.allow = r.Action == "allow"
+			t.bots = append(t.bots, c)
+		}
+	}
+
+	prev := activeRules.Swap(t)
+	return func() {
+		activeRules.CompareAndSwap(t, prev)
+	}, nil
+}
+
+// matchBotRule 返回规则集中第一条命中小写 UA 的机器人规则
+func matchBotRule(ua string) *compiledRule {
+	if t := activeRules.Load(); t != nil {
+		for _, c := range t.bots {
+			if c.match(ua) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// matchBrowserRule 返回规则集中第一条命中小写 UA 的浏览器规则
+func matchBrowserRule(ua string) *compiledRule {
+	if t := activeRules.Load(); t != nil {
+		for _, c := range t.browsers {
+			if c.match(ua) {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+// builtinEnabled 报告内置特征是否生效
+func builtinEnabled(pattern string) bool {
+	t := activeRules.Load()
+	return t == nil || (!t.replace && !t.disabled[pattern])
+}
+
+// EffectiveRules 导出当前生效的规则：已启用的规则集，以及 IsBot、IsBrowser 使用的仍然生效的内置特征
+// 返回的规则集为 replace 模式，用 ApplyRuleSet 启用后 IsBot、IsBrowser 对这些特征的判断与当前一致
+// 规则无法表示的内置数据不会导出：需要验证才放行的爬虫（如 gptbot）、AI 爬虫特征和只用于分类的机器人特征，
+// 启用导出的规则集后它们不再生效，MatchAICrawler、ClassifyBot 和 SetBotVerifier 对这些爬虫的结果会改变
+func EffectiveRules() *RuleSet {
+	rs := &RuleSet{Version: RuleSetVersion, Mode: RuleModeReplace}
+
+	if t := activeRules.Load(); t != nil {
+		for _, r := range t.set.Rules {
+			if !r.Disabled {
+				rs.Rules = append(rs.Rules, r)
+			}
+		}
+	}
+
+	categories := make(map[string]BotSignature, len(botSignatures))
+	for _, sig := range botSignatures {
+		categories[sig.Pattern] = sig
+	}
+	builtin := func(pattern, action string, kind RuleKind) {
+		if !builtinEnabled(pattern) {
+			return
+		}
+		r := Rule{Pattern: pattern, Kind: kind, Action: action}
+		if kind == RuleKindBot {
+			r.Name, r.Category = pattern, CategoryUnknown
+			if sig, ok := categories[pattern]; ok {
+				r.Name, r.Category = sig.Name, sig.Category
+			}
+		}
+		rs.Rules = append(rs.Rules, r)
+	}
+
+	// 与 IsBot 的判断顺序一致：先合法爬虫，后其他机器人
+	for _, p := range legitimateBotPatterns {
+		builtin(p, "allow", RuleKindBot)
+	}
+	for _, p := range commonBotPatterns {
+		builtin(p, "deny", RuleKindBot)
+	}
+	for _, p := range browserPatterns {
+		builtin(p, "allow", RuleKindBrowser)
+	}
+	return rs
+}
@@ -0,0 +1,259 @@
+package uautil
+
+import (
+	"bytes"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const testRulesJSON = `{
+  "version": 1,
+  "mode": "merge",
+  "rules": [
+    {"name": "Acme Monitor", "pattern": "acmemonitor", "category": "monitoring", "action": "allow"},
+    {"name": "Evil Fetcher", "pattern": "^evil-[0-9]+", "match": "regex", "category": "http_library"},
+    {"pattern": "curl", "disabled": true},
+    {"name": "Kiosk", "pattern": "kioskbrowser/", "kind": "browser"}
+  ]
+}`
+
+const testRulesYAML = `# 自定义规则
+version: 1
+mode: merge
+rules:
+  - name: Acme Monitor
+    pattern: acmemonitor
+    category: monitoring
+    action: allow
+  - name: "Evil Fetcher"
+    pattern: '^evil-[0-9]+'
+    match: regex
+    category: http_library
+  - pattern: curl
+    disabled: true
+  - name: Kiosk
+    pattern: kioskbrowser/  # 内部信息亭
+    kind: browser
+`
+
+func TestParseRuleSet(t *testing.T) {
+	for name, data := range map[string]string{"JSON": testRulesJSON, "YAML": testRulesYAML} {
+		t.Run(name, func(t *testing.T) {
+			rs, err := ParseRuleSet([]byte(data))
+			if err != nil {
+				t.Fatalf("ParseRuleSet() error = %v", err)
+			}
+			if rs.Version != 1 || rs.Mode != RuleModeMerge || len(rs.Rules) != 4 {
+				t.Fatalf("ParseRuleSet() = %+v", rs)
+			}
+			want := Rule{Name: "Evil Fetcher", Pattern: "^evil-[0-9]+", Match: MatchRegex, Category: CategoryHTTPLibrary}
+			if rs.Rules[1] != want {
+				t.Errorf("Rules[1] = %+v, want %+v", rs.Rules[1], want)
+			}
+			if !rs.Rules[2].Disabled || rs.Rules[3].Kind != RuleKindBrowser {
+				t.Errorf("Rules = %+v", rs.Rules)
+			}
+		})
+	}
+}
+
+func TestParseRuleSetErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		data  string
+		line  int
+		field string
+	}{
+		{"缺少版本", `{"rules": []}`, 0, "version"},
+		{"不支持的版本", "{\n  \"version\": 2,\n  \"rules\": []\n}", 2, "version"},
+		{"未知匹配方式", "{\n  \"version\": 1,\n  \"rules\": [\n    {\"pattern\": \"a\"},\n    {\"pattern\": \"b\", \"match\": \"glob\"}\n  ]\n}", 5, "rules[1].match"},
+		{"无效正则", "{\"version\": 1, \"rules\": [{\"pattern\": \"(\", \"match\": \"regex\"}]}", 1, "rules[0].pattern"},
+		{"字段类型错误", "{\"version\": 1,\n\"rules\": [\n{\"pattern\": \"a\", \"disabled\": \"yes\"}]}", 3, "rules[0].disabled"},
+		{"未知字段", "{\"version\": 1,\n\"rules\": [\n{\"pattern\": \"a\", \"regex\": true}]}", 3, "rules[0]"},
+		{"JSON语法错误", "{\n  \"version\": 1,\n  \"rules\": [,]\n}", 3, ""},
+		{"YAML缺少pattern", "version: 1\nrules:\n  - pattern: a\n  - name: b\n    category: seo_tool\n", 4, "rules[1].pattern"},
+		{"YAML未知类别", "version: 1\nrules:\n  - pattern: a\n    category: seo\n", 4, "rules[0].category"},
+		{"YAML未知动作", "version: 1\nrules:\n  - pattern: a\n\n    action: maybe\n", 5, "rules[0].action"},
+		{"YAML布尔值错误", "version: 1\nrules:\n  - pattern: a\n    disabled: sometimes\n", 4, "rules[0].disabled"},
+		{"YAML缩进错误", "version: 1\nrules:\n  - pattern: a\n      kind: bot\n", 4, ""},
+		{"YAML未知字段", "version: 1\nrule:\n  - pattern: a\n", 2, "rule"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseRuleSet([]byte(tt.data))
+			var re *RuleError
+			if !errors.As(err, &re) {
+				t.Fatalf("ParseRuleSet() error = %v, want *RuleError", err)
+			}
+			if re.Line != tt.line || re.Field != tt.field {
+				t.Errorf("RuleError = %q (line %d, field %q), want line %d, field %q", re, re.Line, re.Field, tt.line, tt.field)
+			}
+		})
+	}
+}
+
+func TestLoadRuleSetFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "rules.yaml")
+	if err := os.WriteFile(path, []byte("version: 1\nrules:\n  - pattern: a\n    match: glob\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := LoadRuleSetFile(path)
+	if err == nil || !strings.Contains(err.Error(), "rules.yaml: line 4: rules[0].match") {
+		t.Errorf("LoadRuleSetFile() error = %v", err)
+	}
+}
+
+func TestApplyRuleSet(t *testing.T) {
+	rs, err := ParseRuleSet([]byte(testRulesJSON))
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore, err := ApplyRuleSet(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name          string
+		userAgent     string
+		wantBot       bool // IsBotUserAgent(ua, true)
+		wantBrowser   bool
+		wantCategory  BotCategory
+		wantClassify  bool
+		wantStrictBot bool // IsBotUserAgent(ua, false)
+	}{
+		{"自定义合法爬虫", "AcmeMonitor/1.0", false, false, CategoryMonitoring, true, true},
+		{"正则规则", "Evil-42 fetcher", true, false, CategoryHTTPLibrary, true, true},
+		{"禁用内置curl", "curl/7.68.0", false, false, "", false, false},
+		{"自定义浏览器", "KioskBrowser/3.1", false, true, "", false, false},
+		{"内置特征仍然生效", "python-requests/2.25.1", true, false, CategoryHTTPLibrary, true, true},
+		{"内置合法爬虫仍然生效", "Mozilla/5.0 (compatible; Googlebot/2.1)", false, false, CategorySearchEngine, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsBotUserAgent(tt.userAgent, true); got != tt.wantBot {
+				t.Errorf("IsBotUserAgent(true) = %v, want %v", got, tt.wantBot)
+			}
+			if got := IsBotUserAgent(tt.userAgent, false); got != tt.wantStrictBot {
+				t.Errorf("IsBotUserAgent(false) = %v, want %v", got, tt.wantStrictBot)
+			}
+			if got := IsBrowserUserAgent(tt.userAgent); got != tt.wantBrowser {
+				t.Errorf("IsBrowserUserAgent() = %v, want %v", got, tt.wantBrowser)
+			}
+			sig, ok := ClassifyBot(tt.userAgent)
+			if ok != tt.wantClassify || sig.Category != tt.wantCategory {
+				t.Errorf("ClassifyBot() = %q %v, want %q %v", sig.Category, ok, tt.wantCategory, tt.wantClassify)
+			}
+
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			if got := IsBot(req, true); got != tt.wantBot {
+				t.Errorf("IsBot(true) = %v, want %v", got, tt.wantBot)
+			}
+		})
+	}
+
+	restore()
+	if !IsBotUserAgent("curl/7.68.0", true) {
+		t.Error("恢复后 curl 应该被识别为机器人")
+	}
+	if IsBrowserUserAgent("KioskBrowser/3.1") {
+		t.Error("恢复后自定义浏览器规则应该失效")
+	}
+}
+
+func TestApplyRuleSetReplace(t *testing.T) {
+	restore, err := ApplyRuleSet(&RuleSet{
+		Version: 1,
+		Mode:    RuleModeReplace,
+		Rules:   []Rule{{Pattern: "badbot", Category: CategoryUnknown}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restore()
+
+	if !IsBotUserAgent("BadBot/1.0", true) {
+		t.Error("规则集中的机器人应该被识别")
+	}
+	if IsBotUserAgent("python-requests/2.25.1", true) || IsBotUserAgent("GoodBot/1.0", false) {
+		t.Error("replace 模式下内置特征不应该生效")
+	}
+	if !IsBotUserAgent("", true) {
+		t.Error("空 UA 始终是机器人")
+	}
+	if IsBrowserUserAgent("Mozilla/5.0 Chrome/120.0") {
+		t.Error("replace 模式下内置浏览器特征不应该生效")
+	}
+
+	if _, err := ApplyRuleSet(&RuleSet{Version: 1, Rules: []Rule{{Pattern: ""}}}); err == nil {
+		t.Error("无效规则集应该返回错误")
+	}
+}
+
+func TestEffectiveRulesRoundTrip(t *testing.T) {
+	removeBot := AddCustomBotPattern("roundtripfetch")
+	defer removeBot()
+	rs, _ := ParseRuleSet([]byte(testRulesYAML))
+	restore, err := ApplyRuleSet(rs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	effective := EffectiveRules()
+	for _, r := range effective.Rules {
+		if r.Pattern == "curl" {
+			t.Error("被禁用的内置特征不应该导出")
+		}
+	}
+
+	userAgents := []string{
+		"AcmeMonitor/1.0", "Evil-42", "curl/7.68.0", "KioskBrowser/3.1", "RoundTripFetch/1.0",
+		"Mozilla/5.0 (compatible; Googlebot/2.1)", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/120.0 Safari/537.36",
+		"SomeCrawler/1.0", "python-requests/2.25.1",
+	}
+	want := make(map[string][3]bool)
+	for _, ua := range userAgents {
+		want[ua] = [3]bool{IsBotUserAgent(ua, true), IsBotUserAgent(ua, false), IsBrowserUserAgent(ua)}
+	}
+
+	for name, write := range map[string]func(*RuleSet, *bytes.Buffer) error{
+		"JSON": func(rs *RuleSet, b *bytes.Buffer) error { return rs.WriteJSON(b) },
+		"YAML": func(rs *RuleSet, b *bytes.Buffer) error { return rs.WriteYAML(b) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := write(effective, &buf); err != nil {
+				t.Fatal(err)
+			}
+			parsed, err := ParseRuleSet(buf.Bytes())
+			if err != nil {
+				t.Fatalf("重新解析导出的规则失败: %v\n%s", err, buf.String())
+			}
+			if len(parsed.Rules) != len(effective.Rules) {
+				t.Fatalf("规则数 = %d, want %d", len(parsed.Rules), len(effective.Rules))
+			}
+
+			undo, err := ApplyRuleSet(parsed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer undo()
+			for _, ua := range userAgents {
+				got := [3]bool{IsBotUserAgent(ua, true), IsBotUserAgent(ua, false), IsBrowserUserAgent(ua)}
+				if got != want[ua] {
+					t.Errorf("%q: 导入导出规则后 = %v, want %v", ua, got, want[ua])
+				}
+			}
+		})
+	}
+	restore()
+}
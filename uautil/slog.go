@@ -0,0 +1,67 @@
+package uautil
+
+import (
+	"log/slog"
+	"net/http"
+)
+
+// SlogHook 把中间件的决定记录为 log/slog 结构化日志
+type SlogHook struct {
+	logger *slog.Logger
+}
+
+// NewSlogHook 创建记录决定的 slog 钩子，logger 为 nil 时使用 slog.Default()
+// 放行的请求使用 Debug 级别，仅报告模式的决定使用 Info 级别，其余使用 Warn 级别
+func NewSlogHook(logger *slog.Logger) *SlogHook {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &SlogHook{logger: logger}
+}
+
+// OnDecision 记录一条决定
+func (h *SlogHook) OnDecision(r *http.Request, d Decision) {
+	level := slog.LevelWarn
+	switch {
+	case d.ReportOnly:
+		level = slog.LevelInfo
+	case d.Action == ActionAllow:
+		level = slog.LevelDebug
+	}
+
+	ctx := r.Context()
+	if !h.logger.Enabled(ctx, level) {
+		return
+	}
+	h.logger.LogAttrs(ctx, level, "uautil decision", decisionAttrs(d)...)
+}
+
+// decisionAttrs 返回决定的日志属性，省略为空的字段
+func decisionAttrs(d Decision) []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("middleware", d.Middleware),
+		slog.String("action", d.Action.String()),
+		slog.Bool("report_only", d.ReportOnly),
+		slog.String("client_ip", d.ClientIP),
+		slog.String("method", d.Method),
+		slog.String("path", d.Path),
+		slog.String("user_agent", d.UserAgent.Raw),
+		slog.Duration("latency", d.Latency),
+	}
+	if d.Reason != "" {
+		attrs = append(attrs, slog.String("reason", d.Reason))
+	}
+	if d.Rule != "" {
+		attrs = append(attrs, slog.String("rule", d.Rule))
+	}
+	if d.Bot.Name != "" {
+		attrs = append(attrs, slog.String("bot", d.Bot.Name), slog.String("category", string(d.Bot.Category)))
+	}
+	if d.Consistency != nil {
+		attrs = append(attrs, slog.Int("consistency", d.Consistency.Score))
+	}
+	if d.Risk != nil {
+		attrs = append(attrs, slog.Int("risk", d.Risk.Score))
+	}
+	return attrs
+}
@@ -0,0 +1,74 @@
+package uautil
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlogHook(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	curl := httptest.NewRequest("GET", "/admin", nil)
+	curl.Header.Set("User-Agent", "curl/8.0")
+	curl.RemoteAddr = "203.0.113.9:1234"
+
+	tests := []struct {
+		name      string
+		options   []Option
+		request   *http.Request
+		wantLevel string
+		want      map[string]any
+	}{
+		{"拒绝使用Warn", nil, curl, "WARN", map[string]any{
+			"middleware": "BlockBotMiddleware", "action": "block", "report_only": false, "reason": "bot",
+			"rule": "curl", "client_ip": "203.0.113.9", "method": "GET", "path": "/admin", "user_agent": "curl/8.0",
+			"bot": "curl", "category": string(CategoryHTTPLibrary),
+		}},
+		{"仅报告使用Info", []Option{WithReportOnly(func(r *http.Request, d Decision) {})}, curl, "INFO", map[string]any{
+			"action": "block", "report_only": true,
+		}},
+		{"放行使用Debug", nil, browserRequest(chrome120UA), "DEBUG", map[string]any{
+			"action": "allow", "client_ip": "192.0.2.1",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+			opts := append([]Option{WithDecisionHook(NewSlogHook(logger))}, tt.options...)
+			BlockBotMiddlewareWithOptions(true, opts...)(handler).ServeHTTP(httptest.NewRecorder(), tt.request)
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("log = %q: %v", buf.String(), err)
+			}
+			if record["level"] != tt.wantLevel {
+				t.Errorf("level = %v, want %v", record["level"], tt.wantLevel)
+			}
+			if _, ok := record["latency"]; !ok {
+				t.Error("缺少 latency 字段")
+			}
+			for key, want := range tt.want {
+				if record[key] != want {
+					t.Errorf("%s = %v, want %v", key, record[key], want)
+				}
+			}
+		})
+	}
+}
+
+func TestSlogHookLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	BlockBotMiddlewareWithOptions(true, WithDecisionHook(NewSlogHook(logger)))(handler).ServeHTTP(httptest.NewRecorder(), browserRequest(chrome120UA))
+
+	if buf.Len() != 0 {
+		t.Errorf("log = %q, want nothing below Info", buf.String())
+	}
+}
@@ -0,0 +1,110 @@
+package uautil
+
+import (
+	"bytes"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// TarpitConfig 配置 Tarpit，为 0 的字段使用默认值
+type TarpitConfig struct {
+	// Interval 是两次写入之间的间隔，默认 1 秒
+	Interval time.Duration
+	// ChunkSize 是每次写入的字节数，默认 1
+	ChunkSize int
+	// MaxDuration 是单个连接最长保持的时间，默认 30 秒
+	MaxDuration time.Duration
+	// MaxConns 是同时处于 tarpit 的连接上限，默认 100；达到上限时按普通方式拒绝
+	MaxConns int
+	// Status 是返回的状态码，默认 200，使抓取工具难以察觉已被拦截
+	Status int
+}
+
+// Tarpit 以极慢的速度返回响应，拖住被拒绝的抓取工具，使其无法立即换一个身份重试
+// 同一个 Tarpit 可以在多个中间件间共享，连接数上限对所有中间件生效；Tarpit 可以被多个 goroutine 同时使用
+type Tarpit struct {
+	cfg    TarpitConfig
+	chunk  []byte
+	active atomic.Int64
+}
+
+// NewTarpit 创建 tarpit
+func NewTarpit(cfg TarpitConfig) *Tarpit {
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.ChunkSize <= 0 {
+		cfg.ChunkSize = 1
+	}
+	if cfg.MaxDuration <= 0 {
+		cfg.MaxDuration = 30 * time.Second
+	}
+	if cfg.MaxConns <= 0 {
+		cfg.MaxConns = 100
+	}
+	if cfg.Status <= 0 {
+		cfg.Status = http.StatusOK
+	}
+	return &Tarpit{cfg: cfg, chunk: bytes.Repeat([]byte(" "), cfg.ChunkSize)}
+}
+
+// Active 返回当前处于 tarpit 的连接数
+func (t *Tarpit) Active() int {
+	return int(t.active.Load())
+}
+
+// ServeHTTP 以 tarpit 方式返回响应，连接数达到上限时立即返回 429
+func (t *Tarpit) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !t.serve(w, r) {
+		http.Error(w, "Too many requests", http.StatusTooManyRequests)
+	}
+}
+
+// serve 每隔 Interval 写入 ChunkSize 个空白字符，直到客户端断开或超过 MaxDuration
+// 连接数达到上限时不写入任何内容并返回 false
+func (t *Tarpit) serve(w http.ResponseWriter, r *http.Request) bool {
+	if t.active.Add(1) > int64(t.cfg.MaxConns) {
+		t.active.Add(-1)
+		return false
+	}
+	defer t.active.Add(-1)
+
+	rc := http.NewResponseController(w)
+	// 连接保持时间超过服务器的 WriteTimeout 时需要延长写入期限，不支持时忽略
+	rc.SetWriteDeadline(time.Now().Add(t.cfg.MaxDuration + t.cfg.Interval))
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(t.cfg.Status)
+
+	ticker := time.NewTicker(t.cfg.Interval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(t.cfg.MaxDuration)
+	defer deadline.Stop()
+
+	for {
+		if _, err := w.Write(t.chunk); err != nil {
+			return true
+		}
+		if err := rc.Flush(); err != nil {
+			return true
+		}
+
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-deadline.C:
+			return true
+		case <-ticker.C:
+		}
+	}
+}
+
+// WithTarpit 使用 tarpit 响应被拒绝的请求，t 的连接数达到上限时按其他选项的方式拒绝
+func WithTarpit(t *Tarpit) Option {
+	return func(o *middlewareOptions) {
+		o.tarpit = t
+	}
+}
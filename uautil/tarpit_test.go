@@ -0,0 +1,99 @@
+package uautil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTarpit(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tarpit := NewTarpit(TarpitConfig{Interval: time.Millisecond, ChunkSize: 2, MaxDuration: 20 * time.Millisecond})
+
+	curl := httptest.NewRequest("GET", "/", nil)
+	curl.Header.Set("User-Agent", "curl/8.0")
+
+	tests := []struct {
+		name       string
+		request    *http.Request
+		wantStatus int
+		wantBody   bool
+	}{
+		{"机器人被拖住", curl, http.StatusOK, true},
+		{"浏览器不受影响", browserRequest(chrome120UA), http.StatusOK, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			start := time.Now()
+			BlockBotMiddlewareWithOptions(true, WithTarpit(tarpit))(handler).ServeHTTP(rr, tt.request)
+			elapsed := time.Since(start)
+
+			if rr.Code != tt.wantStatus {
+				t.Errorf("status code = %d, want %d", rr.Code, tt.wantStatus)
+			}
+			body := rr.Body.String()
+			if !tt.wantBody {
+				if body != "" {
+					t.Errorf("body = %q, want empty", body)
+				}
+				return
+			}
+			if elapsed < 20*time.Millisecond {
+				t.Errorf("elapsed = %v, want at least MaxDuration", elapsed)
+			}
+			if len(body) < 4 || len(body)%2 != 0 || strings.TrimSpace(body) != "" {
+				t.Errorf("body = %q, want whitespace chunks", body)
+			}
+			if !rr.Flushed {
+				t.Error("响应没有被刷新")
+			}
+		})
+	}
+}
+
+func TestTarpitMaxConns(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tarpit := NewTarpit(TarpitConfig{Interval: time.Millisecond, MaxDuration: time.Minute, MaxConns: 1})
+	mw := BlockBotMiddlewareWithOptions(true, WithTarpit(tarpit), WithStatus(http.StatusTooManyRequests))(handler)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	held := httptest.NewRequest("GET", "/", nil).WithContext(ctx)
+	held.Header.Set("User-Agent", "curl/8.0")
+	done := make(chan struct{})
+	go func() {
+		mw.ServeHTTP(httptest.NewRecorder(), held)
+		close(done)
+	}()
+
+	for deadline := time.Now().Add(time.Second); tarpit.Active() != 1; {
+		if time.Now().After(deadline) {
+			t.Fatal("tarpit 没有开始")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 达到上限时按其他选项的方式立即拒绝
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "curl/8.0")
+	rr := httptest.NewRecorder()
+	mw.ServeHTTP(rr, req)
+	if rr.Code != http.StatusTooManyRequests {
+		t.Errorf("status code = %d, want %d", rr.Code, http.StatusTooManyRequests)
+	}
+
+	// 客户端断开后释放连接
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("客户端断开后 tarpit 没有停止")
+	}
+	if tarpit.Active() != 0 {
+		t.Errorf("Active() = %d, want 0", tarpit.Active())
+	}
+}
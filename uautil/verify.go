@@ -0,0 +1,315 @@
+package uautil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/woodchen-ink/go-web-utils/iputil"
+)
+
+// VerifyResult 合法爬虫验证结果
+type VerifyResult int
+
+const (
+	// VerifyUnsupported 验证器没有该爬虫的验证数据，无法判断
+	VerifyUnsupported VerifyResult = iota
+	// VerifyPassed 请求确实来自该爬虫
+	VerifyPassed
+	// VerifyFailed 请求冒充了该爬虫
+	VerifyFailed
+)
+
+// String 返回验证结果的名称
+func (v VerifyResult) String() string {
+	switch v {
+	case VerifyPassed:
+		return "passed"
+	case VerifyFailed:
+		return "failed"
+	}
+	return "unsupported"
+}
+
+// BotVerifier 用于确认声称是合法爬虫的请求确实来自该爬虫
+// bot 为请求 UA 命中的合法爬虫特征，如 "googlebot"
+type BotVerifier interface {
+	VerifyBot(r *http.Request, bot string) VerifyResult
+}
+
+// Resolver 是 DNSVerifier 使用的 DNS 解析接口，*net.Resolver 实现了该接口
+type Resolver interface {
+	LookupAddr(ctx context.Context, addr string) ([]string, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+// 各搜索引擎爬虫反向解析结果允许的域名
+// 参见各搜索引擎关于验证爬虫的官方文档
+var crawlerDomains = map[string][]string{
+	// 不包含 googleusercontent.com：任何 Google Cloud 虚拟机都有该域名下正反向一致的 PTR 记录
+	"googlebot":   {"googlebot.com", "google.com"},
+	"bingbot":     {"search.msn.com"},
+	"slurp":       {"crawl.yahoo.net"},
+	"baiduspider": {"crawl.baidu.com", "crawl.baidu.jp"},
+	"yandexbot":   {"yandex.ru", "yandex.net", "yandex.com"},
+	"applebot":    {"applebot.apple.com"},
+}
+
+// DNSVerifier 通过正反向 DNS 解析（FCrDNS）验证搜索引擎爬虫：
+// 客户端 IP 的 PTR 记录必须属于该爬虫的域名，且该域名正向解析后必须包含客户端 IP
+type DNSVerifier struct {
+	resolver Resolver
+	mu       sync.RWMutex
+	domains  map[string][]string
+	cache    *verifyCache
+}
+
+// NewDNSVerifier 创建基于 DNS 的爬虫验证器
+// resolver 为 nil 时使用 net.DefaultResolver；ttl 为验证结果的缓存时间，0 表示不缓存
+func NewDNSVerifier(resolver Resolver, ttl time.Duration) *DNSVerifier {
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	domains := make(map[string][]string, len(crawlerDomains))
+	for bot, suffixes := range crawlerDomains {
+		domains[bot] = append([]string(nil), suffixes...)
+	}
+
+	return &DNSVerifier{
+		resolver: resolver,
+		domains:  domains,
+		cache:    newVerifyCache(ttl),
+	}
+}
+
+// AddDomain 为爬虫添加允许的反向解析域名，bot 为合法爬虫特征
+// 可以在处理请求的同时调用
+func (v *DNSVerifier) AddDomain(bot string, suffixes ...string) {
+	bot = strings.ToLower(bot)
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	// 复制后再追加，正在验证的请求持有的旧切片不受影响
+	list := v.domains[bot]
+	list = list[:len(list):len(list)]
+	for _, s := range suffixes {
+		list = append(list, strings.ToLower(strings.Trim(s, ".")))
+	}
+	v.domains[bot] = list
+}
+
+// VerifyBot 验证请求的客户端 IP（由 iputil.GetClientIP 获取）是否属于 bot
+func (v *DNSVerifier) VerifyBot(r *http.Request, bot string) VerifyResult {
+	return v.VerifyIP(r.Context(), iputil.GetClientIP(r), bot)
+}
+
+// VerifyIP 验证 IP 是否属于 bot
+func (v *DNSVerifier) VerifyIP(ctx context.Context, ip, bot string) VerifyResult {
+	bot = strings.ToLower(bot)
+	v.mu.RLock()
+	suffixes, ok := v.domains[bot]
+	v.mu.RUnlock()
+	if !ok {
+		return VerifyUnsupported
+	}
+
+	key := bot + "|" + ip
+	result, hit := v.cache.get(key)
+	if v.cache.ttl > 0 && metricsRecorder != nil {
+		metricsRecorder.RecordVerifyCache(bot, hit)
+	}
+	if hit {
+		return result
+	}
+
+	result, cacheable := v.lookup(ctx, ip, suffixes)
+	if cacheable {
+		v.cache.set(key, result)
+	}
+	return result
+}
+
+// lookup 执行正反向解析，第二个返回值表示结果是否可以缓存（临时性 DNS 错误不缓存）
+func (v *DNSVerifier) lookup(ctx context.Context, ip string, suffixes []string) (VerifyResult, bool) {
+	clientIP := net.ParseIP(ip)
+	if clientIP == nil {
+		return VerifyFailed, true
+	}
+
+	names, err := v.resolver.LookupAddr(ctx, ip)
+	if err != nil {
+		return VerifyFailed, isNotFound(err)
+	}
+
+	for _, name := range names {
+		name = strings.ToLower(strings.TrimSuffix(name, "."))
+		if !hasDomainSuffix(name, suffixes) {
+			continue
+		}
+
+		addrs, err := v.resolver.LookupHost(ctx, name)
+		if err != nil {
+			if !isNotFound(err) {
+				return VerifyFailed, false
+			}
+			continue
+		}
+		for _, addr := range addrs {
+			if clientIP.Equal(net.ParseIP(addr)) {
+				return VerifyPassed, true
+			}
+		}
+	}
+
+	return VerifyFailed, true
+}
+
+// hasDomainSuffix 判断域名是否等于或属于 suffixes 中的任一域名
+func hasDomainSuffix(name string, suffixes []string) bool {
+	for _, s := range suffixes {
+		if name == s || strings.HasSuffix(name, "."+s) {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotFound 判断是否为确定的“记录不存在”错误
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// 当前 IsBot 使用的合法爬虫验证器
+var botVerifier BotVerifier
+
+// SetBotVerifier 设置 IsBot 在 allowLegitimate 为 true 时使用的合法爬虫验证器
+// 设置后，UA 声称是合法爬虫但验证失败的请求会被视为机器人；验证器无法判断的爬虫仍按 UA 放行
+// 返回的函数可用于恢复之前的验证器
+func SetBotVerifier(v BotVerifier) func() {
+	previous := botVerifier
+	botVerifier = v
+
+	return func() {
+		botVerifier = previous
+	}
+}
+
+// IsVerifiedBot 检测请求是否来自经过验证的合法爬虫
+func IsVerifiedBot(r *http.Request, verifier BotVerifier) bool {
+	ua := strings.ToLower(r.UserAgent())
+	bot := matchLegitimateBot(ua)
+	if bot == "" {
+		bot = matchVerifiedOnlyBot(ua)
+	}
+	if bot == "" {
+		return false
+	}
+	return verifier.VerifyBot(r, bot) == VerifyPassed
+}
+
+// matchLegitimateBot 返回小写 UA 命中的第一个合法爬虫特征
+// 规则集中命中的第一条机器人规则优先，该规则不是合法爬虫时返回空字符串
+func matchLegitimateBot(ua string) string {
+	if rule := matchBotRule(ua); rule != nil {
+		if rule.allow {
+			return rule.verifyKey()
+		}
+		return ""
+	}
+
+	for _, pattern := range legitimateBotPatterns {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// matchVerifiedOnlyBot 返回小写 UA 命中的第一个需要验证才放行的爬虫特征，命中规则集中的规则时返回空字符串
+func matchVerifiedOnlyBot(ua string) string {
+	if matchBotRule(ua) != nil {
+		return ""
+	}
+	for _, pattern := range verifiedBotPatterns {
+		if strings.Contains(ua, pattern) && builtinEnabled(pattern) {
+			return pattern
+		}
+	}
+	return ""
+}
+
+// verifyCache 是带过期时间的验证结果缓存
+type verifyCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]verifyCacheEntry
+	now     func() time.Time
+}
+
+type verifyCacheEntry struct {
+	result  VerifyResult
+	expires time.Time
+}
+
+// 缓存条目上限，超过后先清理过期条目
+const maxVerifyCacheEntries = 10000
+
+func newVerifyCache(ttl time.Duration) *verifyCache {
+	return &verifyCache{
+		ttl:     ttl,
+		entries: make(map[string]verifyCacheEntry),
+		now:     time.Now,
+	}
+}
+
+func (c *verifyCache) get(key string) (VerifyResult, bool) {
+	if c.ttl <= 0 {
+		return VerifyUnsupported, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return VerifyUnsupported, false
+	}
+	if c.now().After(entry.expires) {
+		delete(c.entries, key)
+		return VerifyUnsupported, false
+	}
+	return entry.result, true
+}
+
+func (c *verifyCache) set(key string, result VerifyResult) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := c.now()
+	if len(c.entries) >= maxVerifyCacheEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// 仍然已满时随机淘汰一个条目
+		for k := range c.entries {
+			if len(c.entries) < maxVerifyCacheEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+
+	c.entries[key] = verifyCacheEntry{result: result, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,205 @@
+package uautil
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeResolver 是用于测试的 DNS 解析器
+type fakeResolver struct {
+	ptr     map[string][]string
+	hosts   map[string][]string
+	lookups int
+	err     error
+}
+
+func (f *fakeResolver) LookupAddr(ctx context.Context, addr string) ([]string, error) {
+	f.lookups++
+	if f.err != nil {
+		return nil, f.err
+	}
+	if names, ok := f.ptr[addr]; ok {
+		return names, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: addr, IsNotFound: true}
+}
+
+func (f *fakeResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func newFakeResolver() *fakeResolver {
+	return &fakeResolver{
+		ptr: map[string][]string{
+			"66.249.66.1":   {"crawl-66-249-66-1.googlebot.com."},
+			"157.55.39.1":   {"msnbot-157-55-39-1.search.msn.com."},
+			"203.0.113.10":  {"crawl-203-0-113-10.googlebot.com.evil.example."},
+			"203.0.113.20":  {"crawl-66-249-66-1.googlebot.com."},
+			"2001:db8::1":   {"crawl-yandex.yandex.ru."},
+			"198.51.100.10": {"host.example.com."},
+			"198.51.100.20": {"5.6.7.8.bc.googleusercontent.com."},
+		},
+		hosts: map[string][]string{
+			"crawl-66-249-66-1.googlebot.com":   {"66.249.66.1"},
+			"msnbot-157-55-39-1.search.msn.com": {"157.55.39.1"},
+			"crawl-yandex.yandex.ru":            {"2001:db8:0:0::1"},
+			"5.6.7.8.bc.googleusercontent.com":  {"198.51.100.20"},
+		},
+	}
+}
+
+func TestDNSVerifier(t *testing.T) {
+	tests := []struct {
+		name string
+		ip   string
+		bot  string
+		want VerifyResult
+	}{
+		{"真实Googlebot", "66.249.66.1", "googlebot", VerifyPassed},
+		{"真实Bingbot", "157.55.39.1", "bingbot", VerifyPassed},
+		{"IPv6 Yandex", "2001:db8::1", "yandexbot", VerifyPassed},
+		{"域名后缀伪造", "203.0.113.10", "googlebot", VerifyFailed},
+		{"PTR指向他人域名但正向解析不匹配", "203.0.113.20", "googlebot", VerifyFailed},
+		{"不属于爬虫域名", "198.51.100.10", "googlebot", VerifyFailed},
+		{"Google Cloud 虚拟机", "198.51.100.20", "googlebot", VerifyFailed},
+		{"没有PTR记录", "192.0.2.1", "googlebot", VerifyFailed},
+		{"爬虫与域名不符", "157.55.39.1", "googlebot", VerifyFailed},
+		{"无效IP", "not-an-ip", "googlebot", VerifyFailed},
+		{"不支持的爬虫", "66.249.66.1", "twitterbot", VerifyUnsupported},
+	}
+
+	v := NewDNSVerifier(newFakeResolver(), 0)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := v.VerifyIP(context.Background(), tt.ip, tt.bot); got != tt.want {
+				t.Errorf("VerifyIP(%q, %q) = %v, want %v", tt.ip, tt.bot, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDNSVerifierAddDomain(t *testing.T) {
+	resolver := newFakeResolver()
+	resolver.ptr["192.0.2.50"] = []string{"crawler.example.org."}
+	resolver.hosts["crawler.example.org"] = []string{"192.0.2.50"}
+
+	v := NewDNSVerifier(resolver, 0)
+	if got := v.VerifyIP(context.Background(), "192.0.2.50", "friendlybot"); got != VerifyUnsupported {
+		t.Errorf("添加域名前 = %v, want %v", got, VerifyUnsupported)
+	}
+
+	v.AddDomain("FriendlyBot", ".example.org")
+	if got := v.VerifyIP(context.Background(), "192.0.2.50", "friendlybot"); got != VerifyPassed {
+		t.Errorf("添加域名后 = %v, want %v", got, VerifyPassed)
+	}
+}
+
+func TestDNSVerifierAddDomainConcurrent(t *testing.T) {
+	v := NewDNSVerifier(newFakeResolver(), 0)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			v.AddDomain("otherbot", "example.net")
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		v.VerifyIP(context.Background(), "192.0.2.1", "unknownbot")
+	}
+	<-done
+}
+
+func TestDNSVerifierCache(t *testing.T) {
+	resolver := newFakeResolver()
+	v := NewDNSVerifier(resolver, time.Minute)
+
+	now := time.Now()
+	v.cache.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if got := v.VerifyIP(context.Background(), "66.249.66.1", "googlebot"); got != VerifyPassed {
+			t.Fatalf("VerifyIP() = %v, want %v", got, VerifyPassed)
+		}
+	}
+	if resolver.lookups != 1 {
+		t.Errorf("缓存有效期内 lookups = %d, want 1", resolver.lookups)
+	}
+
+	now = now.Add(2 * time.Minute)
+	v.VerifyIP(context.Background(), "66.249.66.1", "googlebot")
+	if resolver.lookups != 2 {
+		t.Errorf("缓存过期后 lookups = %d, want 2", resolver.lookups)
+	}
+
+	// 临时性 DNS 错误不缓存
+	resolver.err = errors.New("timeout")
+	v.VerifyIP(context.Background(), "192.0.2.99", "googlebot")
+	resolver.err = nil
+	resolver.ptr["192.0.2.99"] = []string{"crawl-66-249-66-1.googlebot.com."}
+	resolver.hosts["crawl-66-249-66-1.googlebot.com"] = append(resolver.hosts["crawl-66-249-66-1.googlebot.com"], "192.0.2.99")
+	if got := v.VerifyIP(context.Background(), "192.0.2.99", "googlebot"); got != VerifyPassed {
+		t.Errorf("临时错误后重新验证 = %v, want %v", got, VerifyPassed)
+	}
+}
+
+func TestIsBotWithVerifier(t *testing.T) {
+	const googlebotUA = "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)"
+
+	restore := SetBotVerifier(NewDNSVerifier(newFakeResolver(), time.Minute))
+	defer restore()
+
+	tests := []struct {
+		name       string
+		userAgent  string
+		remoteAddr string
+		want       bool
+	}{
+		{"真实Googlebot放行", googlebotUA, "66.249.66.1:12345", false},
+		{"冒充Googlebot拦截", googlebotUA, "198.51.100.10:12345", true},
+		{"无法验证的合法爬虫放行", "Twitterbot/1.0", "198.51.100.10:12345", false},
+		{"普通浏览器不受影响", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) Chrome/91.0", "198.51.100.10:12345", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			req.RemoteAddr = tt.remoteAddr
+
+			if got := IsBot(req, true); got != tt.want {
+				t.Errorf("IsBot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	restore()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", googlebotUA)
+	req.RemoteAddr = "198.51.100.10:12345"
+	if IsBot(req, true) {
+		t.Error("恢复验证器后应按 UA 放行合法爬虫")
+	}
+}
+
+func TestIsVerifiedBot(t *testing.T) {
+	v := NewDNSVerifier(newFakeResolver(), 0)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)")
+	req.Header.Set("X-Real-IP", "157.55.39.1")
+	if !IsVerifiedBot(req, v) {
+		t.Error("通过 X-Real-IP 获取的真实 Bingbot 应该通过验证")
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 Chrome/91.0")
+	if IsVerifiedBot(req, v) {
+		t.Error("非爬虫 UA 不应该通过验证")
+	}
+}
@@ -0,0 +1,194 @@
+package uautil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// VersionAction 指定浏览器版本不满足要求时的处理方式
+type VersionAction int
+
+const (
+	// VersionBlock 拒绝请求，返回升级提示
+	VersionBlock VersionAction = iota
+	// VersionRedirect 重定向到升级页面
+	VersionRedirect
+	// VersionAnnotate 仅在请求上下文中记录检查结果，由后续处理器自行决定
+	VersionAnnotate
+)
+
+// VersionPolicy 浏览器最低版本策略
+type VersionPolicy struct {
+	// Minimum 为各浏览器的最低版本，键为 Parse 返回的浏览器名称，如 uautil.BrowserChrome
+	Minimum map[string]string
+	// Blocked 中的浏览器无论版本一律不支持，如 uautil.BrowserIE
+	Blocked []string
+	// AllowUnknown 为 true 时放行未在 Minimum 中列出的浏览器，包括无法识别的浏览器和机器人
+	AllowUnknown bool
+	// BlockCrawlers 为 true 时合法爬虫（如 Googlebot、Bingbot）也按 AllowUnknown 处理
+	// 默认总是放行合法爬虫，避免搜索引擎无法抓取页面；这里只检查 UA，冒充爬虫的请求应由 BlockBotMiddleware 等拦截
+	BlockCrawlers bool
+
+	// Action 为版本不满足要求时的处理方式
+	Action VersionAction
+	// UpgradeURL 为 VersionRedirect 的跳转地址，可以是路径或绝对 URL，访问该地址本身的请求总是放行；为空时按 VersionBlock 处理
+	UpgradeURL string
+	// UpgradePage 为 VersionBlock 时返回的页面，为 nil 时返回纯文本的 Message
+	UpgradePage http.Handler
+	// Message 为 VersionBlock 且未设置 UpgradePage 时的拒绝消息
+	Message string
+}
+
+// VersionCheck 是浏览器版本检查的结果
+type VersionCheck struct {
+	Browser   string // 识别出的浏览器名称
+	Version   string // 识别出的浏览器版本
+	Minimum   string // 该浏览器要求的最低版本，未配置时为空
+	Supported bool   // 是否满足策略要求
+}
+
+type versionCheckKey struct{}
+
+// versionVaryFields 是影响版本检查结果的请求头，响应按它们区分缓存
+var versionVaryFields = []string{"User-Agent", HeaderSecCHUA, HeaderSecCHUAFullVersionList}
+
+// CheckBrowserVersion 按策略检查浏览器版本
+func CheckBrowserVersion(ua UserAgent, policy VersionPolicy) VersionCheck {
+	check := VersionCheck{Browser: ua.Browser, Version: ua.BrowserVersion}
+
+	if ua.Bot || ua.Browser == "" {
+		check.Supported = policy.AllowUnknown || !policy.BlockCrawlers && matchLegitimateBot(strings.ToLower(ua.Raw)) != ""
+		return check
+	}
+
+	for _, b := range policy.Blocked {
+		if b == ua.Browser {
+			return check
+		}
+	}
+
+	minimum, ok := policy.Minimum[ua.Browser]
+	if !ok {
+		check.Supported = policy.AllowUnknown
+		return check
+	}
+
+	check.Minimum = minimum
+	// 无法识别版本号时视为不满足要求
+	check.Supported = ua.BrowserVersion != "" && CompareVersions(ua.BrowserVersion, minimum) >= 0
+	return check
+}
+
+// VersionCheckFromContext 获取 MinVersionMiddleware 记录在请求上下文中的检查结果
+func VersionCheckFromContext(ctx context.Context) (VersionCheck, bool) {
+	check, ok := ctx.Value(versionCheckKey{}).(VersionCheck)
+	return check, ok
+}
+
+// MinVersionMiddleware 创建一个中间件,拒绝低于最低版本要求的浏览器
+// 检查结果总会记录在请求上下文中，可通过 VersionCheckFromContext 获取
+// 所有响应都会添加 User-Agent 和浏览器相关 Client Hints 的 Vary 头，避免共享缓存把放行的页面返回给不支持的浏览器
+// opts 可以覆盖 policy 中的拒绝方式，如 WithStatus、WithChallenge、WithReportOnly，VersionAnnotate 时不拒绝任何请求
+func MinVersionMiddleware(policy VersionPolicy, opts ...Option) func(http.Handler) http.Handler {
+	redirect := policy.Action == VersionRedirect && policy.UpgradeURL != ""
+	defaults := []Option{WithMessage(policy.Message)}
+	switch {
+	case redirect:
+		defaults = append(defaults, WithRedirect(policy.UpgradeURL))
+	case policy.UpgradePage != nil:
+		defaults = append(defaults, WithDenyHandler(policy.UpgradePage))
+	}
+	o := newMiddlewareOptions("MinVersionMiddleware", "Your browser is not supported, please upgrade to the latest version", append(defaults, opts...))
+	upgrade, err := url.Parse(policy.UpgradeURL)
+	if err != nil {
+		upgrade = &url.URL{Path: policy.UpgradeURL}
+	}
+
+	detect := func(r *http.Request) bool {
+		check, _ := VersionCheckFromContext(r.Context())
+		if check.Supported || policy.Action == VersionAnnotate {
+			return false
+		}
+		// 升级页面本身总是放行，避免重定向循环
+		return !redirect || !isUpgradePage(r, upgrade)
+	}
+	describe := func(r *http.Request, d *Decision) {
+		check, _ := VersionCheckFromContext(r.Context())
+		d.Reason = "unsupported browser"
+		switch {
+		case check.Minimum != "":
+			d.Rule = check.Browser + " >= " + check.Minimum
+		case check.Browser != "":
+			d.Rule = check.Browser
+		}
+		if sig, ok := ClassifyRequest(r); ok {
+			d.Bot = sig
+		}
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			AddVary(w.Header(), versionVaryFields...)
+			check := CheckBrowserVersion(ParseRequest(r), policy)
+			r = r.WithContext(context.WithValue(r.Context(), versionCheckKey{}, check))
+			o.serve(w, r, next, detect, describe)
+		})
+	}
+}
+
+// isUpgradePage 报告请求是否访问升级页面：比较路径，UpgradeURL 是绝对 URL 时同时比较主机名
+func isUpgradePage(r *http.Request, upgrade *url.URL) bool {
+	p := upgrade.Path
+	if p == "" {
+		p = "/"
+	}
+	if r.URL.Path != p {
+		return false
+	}
+	if upgrade.Host == "" {
+		return true
+	}
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.EqualFold(upgrade.Hostname(), host)
+}
+
+// CompareVersions 比较两个点分版本号，a < b 返回 -1，a == b 返回 0，a > b 返回 1
+// 缺少的段视为 0，因此 "14" 与 "14.0.0" 相等
+func CompareVersions(a, b string) int {
+	as := strings.Split(a, ".")
+	bs := strings.Split(b, ".")
+
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y int
+		if i < len(as) {
+			x = leadingInt(as[i])
+		}
+		if i < len(bs) {
+			y = leadingInt(bs[i])
+		}
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	return 0
+}
+
+// leadingInt 解析字符串开头的数字，如 "4472b" 返回 4472
+func leadingInt(s string) int {
+	end := 0
+	for end < len(s) && isDigit(s[end]) {
+		end++
+	}
+	n, _ := strconv.Atoi(s[:end])
+	return n
+}
@@ -0,0 +1,194 @@
+package uautil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCompareVersions(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"91.0.4472.124", "90", 1},
+		{"89.0", "90", -1},
+		{"14", "14.0.0", 0},
+		{"14.1.1", "14.1", 1},
+		{"10.15.7", "10.16", -1},
+		{"120.0.6099.130", "120.0.6099.71", 1},
+		{"4472b", "4472", 0},
+	}
+
+	for _, tt := range tests {
+		if got := CompareVersions(tt.a, tt.b); got != tt.want {
+			t.Errorf("CompareVersions(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCheckBrowserVersion(t *testing.T) {
+	policy := VersionPolicy{
+		Minimum: map[string]string{
+			BrowserChrome: "90",
+			BrowserSafari: "14",
+		},
+		Blocked: []string{BrowserIE},
+	}
+
+	tests := []struct {
+		name          string
+		userAgent     string
+		allowUnknown  bool
+		blockCrawlers bool
+		want          bool
+	}{
+		{
+			name:      "Chrome 91",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/91.0.4472.124 Safari/537.36",
+			want:      true,
+		},
+		{
+			name:      "Chrome 89",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/89.0.4389.90 Safari/537.36",
+			want:      false,
+		},
+		{
+			name:      "Safari 14.1",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/14.1.1 Safari/605.1.15",
+			want:      true,
+		},
+		{
+			name:      "Safari 13",
+			userAgent: "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_4) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/13.1 Safari/605.1.15",
+			want:      false,
+		},
+		{
+			name:         "IE 一律拒绝",
+			userAgent:    "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			allowUnknown: true,
+			want:         false,
+		},
+		{
+			name:      "未配置的 Firefox 默认拒绝",
+			userAgent: "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:89.0) Gecko/20100101 Firefox/89.0",
+			want:      false,
+		},
+		{
+			name:         "未配置的 Firefox 允许",
+			userAgent:    "Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:89.0) Gecko/20100101 Firefox/89.0",
+			allowUnknown: true,
+			want:         true,
+		},
+		{
+			name:         "机器人按未知浏览器处理",
+			userAgent:    "curl/7.68.0",
+			allowUnknown: true,
+			want:         true,
+		},
+		{
+			name:      "合法爬虫默认放行",
+			userAgent: "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			want:      true,
+		},
+		{
+			name:          "拦截合法爬虫",
+			userAgent:     "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)",
+			blockCrawlers: true,
+			want:          false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := policy
+			p.AllowUnknown = tt.allowUnknown
+			p.BlockCrawlers = tt.blockCrawlers
+			got := CheckBrowserVersion(Parse(tt.userAgent), p)
+			if got.Supported != tt.want {
+				t.Errorf("CheckBrowserVersion() = %+v, want Supported=%v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMinVersionMiddleware(t *testing.T) {
+	const (
+		oldChrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/80.0.3987.149 Safari/537.36"
+		newChrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"
+	)
+
+	base := VersionPolicy{Minimum: map[string]string{BrowserChrome: "90"}}
+
+	redirect := base
+	redirect.Action = VersionRedirect
+	redirect.UpgradeURL = "/upgrade"
+
+	absolute := base
+	absolute.Action = VersionRedirect
+	absolute.UpgradeURL = "https://example.com/upgrade?from=old"
+
+	otherHost := absolute
+	otherHost.UpgradeURL = "https://www.example.org/upgrade"
+
+	noURL := base
+	noURL.Action = VersionRedirect
+
+	annotate := base
+	annotate.Action = VersionAnnotate
+
+	page := base
+	page.UpgradePage = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUpgradeRequired)
+	})
+
+	tests := []struct {
+		name           string
+		policy         VersionPolicy
+		path           string
+		userAgent      string
+		wantStatusCode int
+		wantSupported  bool
+		opts           []Option
+	}{
+		{"新版本放行", base, "/", newChrome, http.StatusOK, true, nil},
+		{"旧版本拒绝", base, "/", oldChrome, http.StatusForbidden, false, nil},
+		{"旧版本重定向", redirect, "/", oldChrome, http.StatusFound, false, nil},
+		{"升级页面本身放行", redirect, "/upgrade", oldChrome, http.StatusOK, false, nil},
+		{"绝对地址的升级页面放行", absolute, "/upgrade", oldChrome, http.StatusOK, false, nil},
+		{"其他主机的同名路径重定向", otherHost, "/upgrade", oldChrome, http.StatusFound, false, nil},
+		{"未设置升级地址时拒绝", noURL, "/", oldChrome, http.StatusForbidden, false, nil},
+		{"仅标注", annotate, "/", oldChrome, http.StatusOK, false, nil},
+		{"自定义升级页面", page, "/", oldChrome, http.StatusUpgradeRequired, false, nil},
+		{"选项覆盖状态码", base, "/", oldChrome, http.StatusUpgradeRequired, false, []Option{WithStatus(http.StatusUpgradeRequired)}},
+		{"仅报告模式放行", base, "/", oldChrome, http.StatusOK, false, []Option{WithReportOnly(func(*http.Request, Decision) {})}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var check VersionCheck
+			handler := MinVersionMiddleware(tt.policy, tt.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				check, _ = VersionCheckFromContext(r.Context())
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			req := httptest.NewRequest("GET", tt.path, nil)
+			req.Header.Set("User-Agent", tt.userAgent)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatusCode {
+				t.Errorf("Status code = %v, want %v", rec.Code, tt.wantStatusCode)
+			}
+			if rec.Code == http.StatusFound && rec.Header().Get("Location") != tt.policy.UpgradeURL {
+				t.Errorf("Location = %q, want %q", rec.Header().Get("Location"), tt.policy.UpgradeURL)
+			}
+			if vary := rec.Header().Values("Vary"); len(vary) != len(versionVaryFields) || vary[0] != "User-Agent" {
+				t.Errorf("Vary = %v, want %v", vary, versionVaryFields)
+			}
+			if rec.Code == http.StatusOK && check.Supported != tt.wantSupported {
+				t.Errorf("VersionCheckFromContext().Supported = %v, want %v", check.Supported, tt.wantSupported)
+			}
+		})
+	}
+}
@@ -0,0 +1,327 @@
+package uautil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 这里实现了 YAML 的一个子集，足以读取规则文件和 device-detector 的 bots.yml：
+// 块映射、块序列（包括 "- key: value" 形式）、单引号/双引号/普通标量和 # 注释。
+// 不支持流式集合（[a, b]、{a: b}）、多行标量（|、>）、锚点和标签。
+
+type yamlKind int
+
+const (
+	yamlScalar yamlKind = iota
+	yamlMap
+	yamlSeq
+)
+
+// yamlNode 是 YAML 文档中的一个节点
+type yamlNode struct {
+	kind   yamlKind
+	line   int
+	value  string // 标量的值
+	quoted bool   // 标量是否带引号
+	keys   []string
+	lines  map[string]int // 映射中每个键所在的行
+	fields map[string]*yamlNode
+	items  []*yamlNode
+}
+
+// yamlError 是带行号的 YAML 解析错误
+type yamlError struct {
+	Line int
+	Msg  string
+}
+
+func (e *yamlError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Msg)
+}
+
+type yamlLine struct {
+	num    int
+	indent int
+	text   string
+}
+
+type yamlParser struct {
+	lines []yamlLine
+	pos   int
+}
+
+// parseYAML 解析 YAML 文档，空文档返回空映射
+func parseYAML(data []byte) (*yamlNode, error) {
+	p := &yamlParser{}
+	for i, raw := range strings.Split(string(data), "\n") {
+		raw = strings.TrimRight(raw, "\r")
+		text, err := stripYAMLComment(raw)
+		if err != nil {
+			return nil, &yamlError{Line: i + 1, Msg: err.Error()}
+		}
+		trimmed := strings.TrimLeft(text, " ")
+		if strings.TrimSpace(trimmed) == "" || trimmed == "---" || trimmed == "..." {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "\t") {
+			return nil, &yamlError{Line: i + 1, Msg: "tabs are not allowed for indentation"}
+		}
+		p.lines = append(p.lines, yamlLine{num: i + 1, indent: len(text) - len(trimmed), text: strings.TrimRight(trimmed, " \t")})
+	}
+
+	if len(p.lines) == 0 {
+		return &yamlNode{kind: yamlMap, line: 1, lines: map[string]int{}, fields: map[string]*yamlNode{}}, nil
+	}
+	node, err := p.parseBlock(p.lines[0].indent)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.lines) {
+		return nil, &yamlError{Line: p.lines[p.pos].num, Msg: "unexpected indentation"}
+	}
+	return node, nil
+}
+
+func (p *yamlParser) parseBlock(indent int) (*yamlNode, error) {
+	if isYAMLSeqItem(p.lines[p.pos].text) {
+		return p.parseSeq(indent)
+	}
+	return p.parseMap(indent)
+}
+
+func (p *yamlParser) parseSeq(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlSeq, line: p.lines[p.pos].num}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, &yamlError{Line: l.num, Msg: "unexpected indentation"}
+		}
+		if !isYAMLSeqItem(l.text) {
+			break
+		}
+
+		rest := strings.TrimLeft(l.text[1:], " ")
+		if rest == "" {
+			p.pos++
+			if p.pos < len(p.lines) && p.lines[p.pos].indent > indent {
+				item, err := p.parseBlock(p.lines[p.pos].indent)
+				if err != nil {
+					return nil, err
+				}
+				node.items = append(node.items, item)
+			} else {
+				node.items = append(node.items, &yamlNode{kind: yamlScalar, line: l.num})
+			}
+			continue
+		}
+
+		// "- key: value" 开始一个映射，映射的缩进为 key 所在的列
+		col := l.indent + len(l.text) - len(rest)
+		if _, _, ok, _ := splitYAMLKey(rest); ok || isYAMLSeqItem(rest) {
+			p.lines[p.pos] = yamlLine{num: l.num, indent: col, text: rest}
+			item, err := p.parseBlock(col)
+			if err != nil {
+				return nil, err
+			}
+			node.items = append(node.items, item)
+			continue
+		}
+
+		item, err := parseYAMLScalar(rest, l.num)
+		if err != nil {
+			return nil, err
+		}
+		node.items = append(node.items, item)
+		p.pos++
+	}
+	return node, nil
+}
+
+func (p *yamlParser) parseMap(indent int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlMap, line: p.lines[p.pos].num, lines: make(map[string]int), fields: make(map[string]*yamlNode)}
+	for p.pos < len(p.lines) {
+		l := p.lines[p.pos]
+		if l.indent < indent {
+			break
+		}
+		if l.indent > indent {
+			return nil, &yamlError{Line: l.num, Msg: "unexpected indentation"}
+		}
+		if isYAMLSeqItem(l.text) {
+			return nil, &yamlError{Line: l.num, Msg: "unexpected sequence item in mapping"}
+		}
+
+		key, rest, ok, err := splitYAMLKey(l.text)
+		if err != nil {
+			return nil, &yamlError{Line: l.num, Msg: err.Error()}
+		}
+		if !ok {
+			return nil, &yamlError{Line: l.num, Msg: fmt.Sprintf("expected \"key: value\", got %q", l.text)}
+		}
+		if _, dup := node.fields[key]; dup {
+			return nil, &yamlError{Line: l.num, Msg: fmt.Sprintf("duplicate key %q", key)}
+		}
+
+		var value *yamlNode
+		p.pos++
+		if rest == "" {
+			switch {
+			case p.pos < len(p.lines) && p.lines[p.pos].indent > indent:
+				value, err = p.parseBlock(p.lines[p.pos].indent)
+			case p.pos < len(p.lines) && p.lines[p.pos].indent == indent && isYAMLSeqItem(p.lines[p.pos].text):
+				// 序列可以与父级键对齐
+				value, err = p.parseSeq(indent)
+			default:
+				value = &yamlNode{kind: yamlScalar, line: l.num}
+			}
+		} else {
+			value, err = parseYAMLScalar(rest, l.num)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		node.keys = append(node.keys, key)
+		node.lines[key] = l.num
+		node.fields[key] = value
+	}
+	return node, nil
+}
+
+func isYAMLSeqItem(text string) bool {
+	return text == "-" || strings.HasPrefix(text, "- ")
+}
+
+// splitYAMLKey 把 "key: value" 拆分为键和值，不是映射项时 ok 为 false
+func splitYAMLKey(text string) (key, rest string, ok bool, err error) {
+	var end int
+	if text[0] == '"' || text[0] == '\'' {
+		n, err := yamlQuotedLen(text)
+		if err != nil {
+			return "", "", false, err
+		}
+		if n >= len(text) || text[n] != ':' {
+			return "", "", false, nil
+		}
+		k, err := parseYAMLScalar(text[:n], 0)
+		if err != nil {
+			return "", "", false, err
+		}
+		key, end = k.value, n
+	} else {
+		i := strings.Index(text, ": ")
+		switch {
+		case i >= 0:
+			end = i
+		case strings.HasSuffix(text, ":"):
+			end = len(text) - 1
+		default:
+			return "", "", false, nil
+		}
+		key = strings.TrimSpace(text[:end])
+	}
+
+	if end+1 < len(text) && text[end+1] != ' ' {
+		return "", "", false, nil
+	}
+	return key, strings.TrimSpace(text[end+1:]), true, nil
+}
+
+// parseYAMLScalar 解析单行标量
+func parseYAMLScalar(text string, line int) (*yamlNode, error) {
+	node := &yamlNode{kind: yamlScalar, line: line}
+	switch text[0] {
+	case '"', '\'':
+		n, err := yamlQuotedLen(text)
+		if err != nil {
+			return nil, &yamlError{Line: line, Msg: err.Error()}
+		}
+		if strings.TrimSpace(text[n:]) != "" {
+			return nil, &yamlError{Line: line, Msg: fmt.Sprintf("unexpected text after quoted string: %q", text[n:])}
+		}
+		node.quoted = true
+		if text[0] == '\'' {
+			node.value = strings.ReplaceAll(text[1:n-1], "''", "'")
+		} else {
+			v, err := strconv.Unquote(text[:n])
+			if err != nil {
+				return nil, &yamlError{Line: line, Msg: fmt.Sprintf("invalid double-quoted string %s", text[:n])}
+			}
+			node.value = v
+		}
+	case '[', '{':
+		return nil, &yamlError{Line: line, Msg: "flow collections are not supported"}
+	case '|', '>':
+		return nil, &yamlError{Line: line, Msg: "block scalars are not supported"}
+	case '&', '*', '!':
+		return nil, &yamlError{Line: line, Msg: "anchors, aliases and tags are not supported"}
+	default:
+		node.value = text
+		if text == "~" || text == "null" {
+			node.value = ""
+		}
+	}
+	return node, nil
+}
+
+// yamlQuotedLen 返回以引号开头的字符串（包括两端引号）的长度
+func yamlQuotedLen(text string) (int, error) {
+	q := text[0]
+	for i := 1; i < len(text); i++ {
+		switch {
+		case q == '"' && text[i] == '\\':
+			i++
+		case text[i] == q:
+			if q == '\'' && i+1 < len(text) && text[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated quoted string")
+}
+
+// stripYAMLComment 去掉引号外的 # 注释
+func stripYAMLComment(line string) (string, error) {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++
+		case quote != 0:
+			if c == quote {
+				if quote == '\'' && i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				quote = 0
+			}
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i], nil
+		case (c == '"' || c == '\'') && (i == 0 || strings.ContainsRune(" -:[{,", rune(line[i-1]))):
+			quote = c
+		}
+	}
+	return line, nil
+}
+
+// str 返回标量的值，节点为 nil 或不是标量时返回错误
+func (n *yamlNode) str() (string, error) {
+	if n == nil || n.kind != yamlScalar {
+		return "", &yamlError{Line: n.lineOr(0), Msg: "expected a scalar value"}
+	}
+	return n.value, nil
+}
+
+func (n *yamlNode) lineOr(def int) int {
+	if n == nil {
+		return def
+	}
+	return n.line
+}
@@ -0,0 +1,84 @@
+package uautil
+
+import "testing"
+
+func TestParseYAML(t *testing.T) {
+	data := `---
+# device-detector 风格
+- regex: 'Googlebot(?:-Mobile)?|Google Favicon'
+  name: 'Googlebot'
+  category: 'Search bot'
+  producer:
+    name: 'Google Inc.'
+    url: "http://www.google.com"
+- regex: 'it''s #1 bot'   # 注释
+  name: Plain value: with colon
+- - nested
+  - "quoted \"value\""
+-
+  name: empty-dash
+`
+	root, err := parseYAML([]byte(data))
+	if err != nil {
+		t.Fatalf("parseYAML() error = %v", err)
+	}
+	if root.kind != yamlSeq || len(root.items) != 4 {
+		t.Fatalf("root = kind %v, %d items", root.kind, len(root.items))
+	}
+
+	first := root.items[0]
+	if got := first.fields["regex"].value; got != "Googlebot(?:-Mobile)?|Google Favicon" {
+		t.Errorf("regex = %q", got)
+	}
+	if got := first.fields["producer"].fields["url"].value; got != "http://www.google.com" {
+		t.Errorf("producer.url = %q", got)
+	}
+	if first.lines["category"] != 5 {
+		t.Errorf("category line = %d, want 5", first.lines["category"])
+	}
+
+	second := root.items[1]
+	if got := second.fields["regex"].value; got != "it's #1 bot" {
+		t.Errorf("regex = %q", got)
+	}
+	if got := second.fields["name"].value; got != "Plain value: with colon" {
+		t.Errorf("name = %q", got)
+	}
+
+	nested := root.items[2]
+	if nested.kind != yamlSeq || len(nested.items) != 2 || nested.items[1].value != `quoted "value"` {
+		t.Errorf("nested = %+v", nested)
+	}
+	if got := root.items[3].fields["name"].value; got != "empty-dash" {
+		t.Errorf("name = %q", got)
+	}
+}
+
+func TestParseYAMLErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		line int
+	}{
+		{"流式集合", "a: [1, 2]\n", 1},
+		{"多行标量", "a: |\n  text\n", 1},
+		{"未闭合引号", "a: 'text\n", 1},
+		{"重复的键", "a: 1\nb: 2\na: 3\n", 3},
+		{"缩进错误", "a:\n  b: 1\n    c: 2\n", 3},
+		{"制表符缩进", "a:\n\tb: 1\n", 2},
+		{"不是映射项", "a: 1\njust text\n", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseYAML([]byte(tt.data))
+			ye, ok := err.(*yamlError)
+			if !ok {
+				t.Fatalf("parseYAML() error = %v, want *yamlError", err)
+			}
+			if ye.Line != tt.line {
+				t.Errorf("line = %d, want %d (%v)", ye.Line, tt.line, ye)
+			}
+		})
+	}
+}